@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/migrate"
+	"github.com/gjb1088/To-Do-list/migrations"
+)
+
+// migrateUp applies pending migrations and logs each one.
+func migrateUp(ctx context.Context, db *sqlx.DB) error {
+	runner, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ran, err := runner.Up(ctx)
+	for _, m := range ran {
+		logging.Infof("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+// runMigrate implements `todolist migrate up|down|status`.
+func runMigrate(ctx context.Context, db *sqlx.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: todolist migrate up|down|status")
+	}
+	runner, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := runner.Up(ctx)
+		for _, m := range ran {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Println("already up to date")
+		}
+		return err
+
+	case "down":
+		m, err := runner.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Println("nothing to revert")
+		} else {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d_%-30s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down or status)", args[0])
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// purgeInterval is how often the trash is checked for todos past their
+// retention period.
+const purgeInterval = time.Hour
+
+// startPurge empties the trash of todos deleted more than retention ago,
+// right away and then every purgeInterval, until the returned function is
+// called. That function waits for a purge in progress, so the store can be
+// closed after it returns. A retention of 0 purges nothing.
+func startPurge(store models.ToDoStore, retention time.Duration) (stop func()) {
+	if retention <= 0 {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for {
+			purged, err := store.PurgeDeleted(time.Now().Add(-retention))
+			switch {
+			case err != nil:
+				logging.Errorf("purging trash: %v", err)
+			case len(purged) > 0:
+				logging.Infof("Purged %d todos deleted more than %s ago", len(purged), retention)
+			}
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
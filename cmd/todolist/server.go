@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/config"
+	"github.com/gjb1088/To-Do-list/internal/logging"
+)
+
+// Server timeouts. WriteTimeout bounds a whole handler, so it is generous
+// enough for the slowest full-page render; ReadHeaderTimeout is what stops
+// slowloris clients from pinning connections.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+
+	// shutdownTimeout is how long in-flight requests get to finish after
+	// SIGINT/SIGTERM before their connections are closed.
+	shutdownTimeout = 20 * time.Second
+)
+
+// newServer wraps h in an http.Server with production timeouts.
+func newServer(cfg *config.Config, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           h,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// serve runs srv until it fails or the process receives SIGINT/SIGTERM, then
+// drains in-flight requests for up to shutdownTimeout.
+func serve(cfg *config.Config, srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSEnabled() {
+			logging.Infof("Starting server on https://%s", cfg.ListenAddr)
+			errc <- srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			logging.Infof("Starting server on http://%s", cfg.ListenAddr)
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal falls back to the default behaviour and kills us.
+	stop()
+
+	logging.Infof("Shutting down; draining requests for up to %s", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -1,10 +1,21 @@
 module github.com/gjb1088/To-Do-list
 
-go 1.21
+go 1.23.0
 
 require (
-    github.com/gorilla/sessions v1.2.0
-    golang.org/x/crypto v0.23.1-0.20240603234054-0b431c7de36a
-    github.com/jmoiron/sqlx v1.3.5
-    github.com/jackc/pgx/v5 v5.7.5
+	github.com/BurntSushi/toml v1.4.0
+	github.com/gorilla/sessions v1.2.0
+	github.com/jackc/pgx/v5 v5.7.5
+	github.com/jmoiron/sqlx v1.3.5
+	golang.org/x/crypto v0.37.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gorilla/securecookie v1.1.1 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
 )
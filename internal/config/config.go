@@ -0,0 +1,308 @@
+// Package config loads the server's runtime settings.
+//
+// Every setting can come from four places. Later sources override earlier
+// ones:
+//
+//  1. built-in defaults
+//  2. a YAML (.yaml/.yml) or TOML (.toml) file named by -config or TODO_CONFIG
+//  3. TODO_* environment variables
+//  4. command-line flags
+//
+// Load validates the merged result and reports every problem at once.
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+)
+
+// MinSessionSecretLen is the shortest session secret Load accepts.
+const MinSessionSecretLen = 32
+
+// exampleSessionSecret is the placeholder in todolist.example.yaml. Being
+// public, it is refused like a missing secret.
+const exampleSessionSecret = "change-me-to-at-least-32-random-bytes"
+
+// Config holds every runtime setting for cmd/todolist.
+type Config struct {
+	ListenAddr    string `yaml:"listen_addr" toml:"listen_addr"`
+	Store         string `yaml:"store" toml:"store"`
+	DatabaseURL   string `yaml:"database_url" toml:"database_url"`
+	AutoMigrate   bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+	SessionSecret string `yaml:"session_secret" toml:"session_secret"`
+	LogLevel      string `yaml:"log_level" toml:"log_level"`
+	StaticDir     string `yaml:"static_dir" toml:"static_dir"`
+	DevTemplates  bool   `yaml:"dev_templates" toml:"dev_templates"`
+	TemplatesDir  string `yaml:"templates_dir" toml:"templates_dir"`
+	TLSCertFile   string `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile    string `yaml:"tls_key_file" toml:"tls_key_file"`
+	// TrashRetention is how long deleted todos stay in the trash before
+	// they are purged; 0 keeps them until deleted by hand.
+	TrashRetention time.Duration `yaml:"trash_retention" toml:"trash_retention"`
+	// Admins are the usernames allowed to read everyone's audit log.
+	Admins []string `yaml:"admins" toml:"admins"`
+}
+
+// Defaults returns the settings used when nothing else is configured.
+func Defaults() Config {
+	return Config{
+		ListenAddr:     ":8080",
+		Store:          "postgres",
+		AutoMigrate:    true,
+		LogLevel:       "info",
+		TemplatesDir:   filepath.Join("internal", "templates"),
+		TrashRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// TLSEnabled reports whether the server should listen with HTTPS.
+func (c *Config) TLSEnabled() bool { return c.TLSCertFile != "" }
+
+// Level returns the parsed LogLevel. It is only valid after Load.
+func (c *Config) Level() logging.Level {
+	l, _ := logging.ParseLevel(c.LogLevel)
+	return l
+}
+
+// setting ties one Config field to its flag and environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{} // *string, *bool, *time.Duration or *[]string
+}
+
+var settings = []setting{
+	{"listen", "TODO_LISTEN_ADDR", "address to serve HTTP on", func(c *Config) interface{} { return &c.ListenAddr }},
+	{"store", "TODO_STORE", `storage backend: "postgres" or "memory"`, func(c *Config) interface{} { return &c.Store }},
+	{"database-url", "TODO_DATABASE_URL", "Postgres connection string", func(c *Config) interface{} { return &c.DatabaseURL }},
+	{"migrate", "TODO_AUTO_MIGRATE", "apply pending schema migrations on startup (postgres only)", func(c *Config) interface{} { return &c.AutoMigrate }},
+	{"session-secret", "TODO_SESSION_SECRET", fmt.Sprintf("key signing session cookies (at least %d bytes)", MinSessionSecretLen), func(c *Config) interface{} { return &c.SessionSecret }},
+	{"log-level", "TODO_LOG_LEVEL", "debug, info, warn or error", func(c *Config) interface{} { return &c.LogLevel }},
+	{"static-dir", "TODO_STATIC_DIR", "serve /static/ from this directory instead of the embedded files", func(c *Config) interface{} { return &c.StaticDir }},
+	{"dev-templates", "TODO_DEV_TEMPLATES", "re-read templates from -templates-dir on every request", func(c *Config) interface{} { return &c.DevTemplates }},
+	{"templates-dir", "TODO_TEMPLATES_DIR", "template directory used by -dev-templates", func(c *Config) interface{} { return &c.TemplatesDir }},
+	{"tls-cert", "TODO_TLS_CERT_FILE", "TLS certificate file; enables HTTPS together with -tls-key", func(c *Config) interface{} { return &c.TLSCertFile }},
+	{"tls-key", "TODO_TLS_KEY_FILE", "TLS private key file", func(c *Config) interface{} { return &c.TLSKeyFile }},
+	{"trash-retention", "TODO_TRASH_RETENTION", "how long deleted todos stay in the trash, e.g. 720h; 0 keeps them", func(c *Config) interface{} { return &c.TrashRetention }},
+	{"admins", "TODO_ADMINS", "comma-separated usernames that may read the audit log", func(c *Config) interface{} { return &c.Admins }},
+}
+
+// Load builds the configuration from args (without the program name),
+// getenv and the optional config file. It returns the remaining positional
+// arguments, e.g. a subcommand.
+func Load(args []string, getenv func(string) string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("todolist", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", "", "YAML or TOML config file (env TODO_CONFIG)")
+
+	// Flags parse into their own Config; only the ones actually given are
+	// copied over the file and environment values afterwards.
+	defaults := Defaults()
+	fromFlags := Defaults()
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		switch p := s.field(&fromFlags).(type) {
+		case *string:
+			fs.StringVar(p, s.flag, *p, usage)
+		case *bool:
+			fs.BoolVar(p, s.flag, *p, usage)
+		case *time.Duration:
+			fs.DurationVar(p, s.flag, *p, usage)
+		case *[]string:
+			fs.Func(s.flag, usage, func(raw string) error { return set(p, raw) })
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "Usage: todolist [flags] [migrate up|down|status]")
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return nil, nil, err
+	}
+
+	cfg := defaults
+	path := *configPath
+	if path == "" {
+		path = getenv("TODO_CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		v, ok := lookup(getenv, s.env)
+		if !ok {
+			continue
+		}
+		if err := set(s.field(&cfg), v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, s := range settings {
+		if !given[s.flag] {
+			continue
+		}
+		switch dst := s.field(&cfg).(type) {
+		case *string:
+			*dst = *s.field(&fromFlags).(*string)
+		case *bool:
+			*dst = *s.field(&fromFlags).(*bool)
+		case *time.Duration:
+			*dst = *s.field(&fromFlags).(*time.Duration)
+		case *[]string:
+			*dst = *s.field(&fromFlags).(*[]string)
+		}
+	}
+
+	// Only serving signs cookies; subcommands such as migrate don't need
+	// a session secret.
+	errs = append(errs, cfg.validate(fs.NArg() == 0)...)
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return &cfg, fs.Args(), nil
+}
+
+// lookup treats empty environment variables as unset.
+func lookup(getenv func(string) string, key string) (string, bool) {
+	v := getenv(key)
+	return v, v != ""
+}
+
+// set parses raw into the field pointer p.
+func set(p interface{}, raw string) error {
+	switch p := p.(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		*p = d
+	case *[]string:
+		*p = splitList(raw)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping blanks.
+func splitList(raw string) []string {
+	var out []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// loadFile decodes a YAML or TOML file over cfg, picking the format from the
+// file extension.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if undec := md.Undecoded(); len(undec) > 0 {
+			return fmt.Errorf("parse %s: unknown keys %v", path, undec)
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension (want .yaml, .yml or .toml)", path)
+	}
+	return nil
+}
+
+// validate checks the merged settings and fills in derived defaults. The
+// session secret is only checked when serving.
+func (c *Config) validate(serving bool) []error {
+	var errs []error
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen address must not be empty"))
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls cert and key files must be set together"))
+	}
+	if c.TrashRetention < 0 {
+		errs = append(errs, errors.New("trash retention must not be negative"))
+	}
+
+	switch c.Store {
+	case "postgres":
+		if c.DatabaseURL == "" {
+			errs = append(errs, errors.New("database url is required for the postgres store (set TODO_DATABASE_URL)"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("unknown store %q (want postgres or memory)", c.Store))
+	}
+	if !serving {
+		return errs
+	}
+
+	switch {
+	case c.SessionSecret == "" && c.Store == "memory":
+		// Nothing survives a restart in memory mode, so a throwaway
+		// secret is fine when none was configured.
+		c.SessionSecret = randomSecret()
+	case c.SessionSecret == "":
+		errs = append(errs, errors.New("session secret is required (set TODO_SESSION_SECRET)"))
+	case c.SessionSecret == exampleSessionSecret:
+		errs = append(errs, errors.New("session secret is the example placeholder; generate one, e.g. with openssl rand -hex 32"))
+	case len(c.SessionSecret) < MinSessionSecretLen:
+		errs = append(errs, fmt.Errorf("session secret must be at least %d bytes", MinSessionSecretLen))
+	}
+	return errs
+}
+
+func randomSecret() string {
+	b := make([]byte, MinSessionSecretLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
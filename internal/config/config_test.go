@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, "todo.yaml", `
+listen_addr: ":7000"
+log_level: warn
+database_url: postgres://file
+session_secret: `+secret+`
+`)
+	cfg, rest, err := Load(
+		[]string{"-config", file, "-listen", ":9000", "migrate", "status"},
+		env(map[string]string{
+			"TODO_LISTEN_ADDR":  ":8000",
+			"TODO_DATABASE_URL": "postgres://env",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ListenAddr != ":9000" {
+		t.Errorf("flag should beat env and file, got %q", cfg.ListenAddr)
+	}
+	if cfg.DatabaseURL != "postgres://env" {
+		t.Errorf("env should beat file, got %q", cfg.DatabaseURL)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("file should beat defaults, got %q", cfg.LogLevel)
+	}
+	if cfg.TemplatesDir != filepath.Join("internal", "templates") || !cfg.AutoMigrate {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+	if strings.Join(rest, " ") != "migrate status" {
+		t.Errorf("unexpected remaining args %q", rest)
+	}
+}
+
+func TestTOMLFileAndBoolFlag(t *testing.T) {
+	file := writeFile(t, "todo.toml", `
+store = "memory"
+auto_migrate = true
+`)
+	cfg, _, err := Load([]string{"-migrate=false"}, env(map[string]string{"TODO_CONFIG": file}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Store != "memory" || cfg.AutoMigrate {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if len(cfg.SessionSecret) < MinSessionSecretLen {
+		t.Errorf("memory store should get a generated session secret")
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	_, _, err := Load(
+		[]string{"-log-level", "loud", "-tls-cert", "cert.pem"},
+		env(map[string]string{"TODO_SESSION_SECRET": "short", "TODO_AUTO_MIGRATE": "maybe"}),
+	)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"TODO_AUTO_MIGRATE",
+		"unknown log level",
+		"tls cert and key",
+		"database url is required",
+		"at least 32 bytes",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestSessionSecret(t *testing.T) {
+	pg := env(map[string]string{"TODO_DATABASE_URL": "postgres://env"})
+	if _, _, err := Load(nil, pg); err == nil || !strings.Contains(err.Error(), "session secret is required") {
+		t.Errorf("serving without a secret: got %v", err)
+	}
+	if _, rest, err := Load([]string{"migrate", "up"}, pg); err != nil || len(rest) != 2 {
+		t.Errorf("migrate shouldn't need a secret: got %q, %v", rest, err)
+	}
+
+	// The example file's placeholder is commented out, and refused anyway.
+	if _, _, err := Load([]string{"-config", filepath.Join("..", "..", "todolist.example.yaml")}, env(nil)); err == nil ||
+		!strings.Contains(err.Error(), "session secret is required") {
+		t.Errorf("example config: got %v", err)
+	}
+	_, _, err := Load([]string{"-store", "memory", "-session-secret", exampleSessionSecret}, env(nil))
+	if err == nil || !strings.Contains(err.Error(), "placeholder") {
+		t.Errorf("placeholder secret: got %v", err)
+	}
+}
+
+func TestUnknownFileKey(t *testing.T) {
+	file := writeFile(t, "todo.yaml", "listen: \":1\"\n")
+	if _, _, err := Load([]string{"-config", file}, env(nil)); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	cfg, _, err := Load([]string{"-store", "memory"}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.TrashRetention != 30*24*time.Hour {
+		t.Errorf("default retention = %s, want 720h", cfg.TrashRetention)
+	}
+
+	for name, body := range map[string]string{
+		"todo.yaml": "store: memory\ntrash_retention: 48h\n",
+		"todo.toml": "store = \"memory\"\ntrash_retention = \"48h\"\n",
+	} {
+		cfg, _, err := Load([]string{"-config", writeFile(t, name, body)}, env(nil))
+		if err != nil {
+			t.Fatalf("%s: Load: %v", name, err)
+		}
+		if cfg.TrashRetention != 48*time.Hour {
+			t.Errorf("%s: retention = %s, want 48h", name, cfg.TrashRetention)
+		}
+	}
+
+	cfg, _, err = Load([]string{"-store", "memory", "-trash-retention", "0"},
+		env(map[string]string{"TODO_TRASH_RETENTION": "1h"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.TrashRetention != 0 {
+		t.Errorf("flag should beat env, got %s", cfg.TrashRetention)
+	}
+
+	_, _, err = Load([]string{"-store", "memory", "-trash-retention", "-1h"},
+		env(map[string]string{"TODO_TRASH_RETENTION": "30 days"}))
+	if err == nil || !strings.Contains(err.Error(), "TODO_TRASH_RETENTION") ||
+		!strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("expected errors for a bad and a negative retention, got %v", err)
+	}
+}
+
+func TestAdmins(t *testing.T) {
+	cfg, _, err := Load([]string{"-store", "memory"}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Admins) != 0 {
+		t.Errorf("no admins by default, got %q", cfg.Admins)
+	}
+
+	for name, body := range map[string]string{
+		"todo.yaml": "store: memory\nadmins: [alice, bob]\n",
+		"todo.toml": "store = \"memory\"\nadmins = [\"alice\", \"bob\"]\n",
+	} {
+		cfg, _, err := Load([]string{"-config", writeFile(t, name, body)}, env(nil))
+		if err != nil {
+			t.Fatalf("%s: Load: %v", name, err)
+		}
+		if strings.Join(cfg.Admins, ",") != "alice,bob" {
+			t.Errorf("%s: admins = %q", name, cfg.Admins)
+		}
+	}
+
+	cfg, _, err = Load([]string{"-store", "memory"}, env(map[string]string{"TODO_ADMINS": " alice, ,bob "}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(cfg.Admins, ",") != "alice,bob" {
+		t.Errorf("env admins = %q", cfg.Admins)
+	}
+
+	cfg, _, err = Load([]string{"-store", "memory", "-admins", "carol"}, env(map[string]string{"TODO_ADMINS": "alice"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(cfg.Admins, ",") != "carol" {
+		t.Errorf("flag should beat env, got %q", cfg.Admins)
+	}
+}
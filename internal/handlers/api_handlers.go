@@ -0,0 +1,1062 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// apiPrefix is where the JSON API is mounted.
+const apiPrefix = "/api/v1"
+
+// maxAPIBody caps JSON request bodies.
+const maxAPIBody = 1 << 20
+
+// APIHandler serves the versioned JSON API over a ToDoStore and TokenStore.
+type APIHandler struct {
+	store  models.ToDoStore
+	tokens models.TokenStore
+	// Events is the audit log served at /api/v1/audit to Admins; nil
+	// turns the endpoint off.
+	Events models.EventStore
+	Admins []string
+}
+
+// NewAPIHandler returns an APIHandler backed by store and tokens.
+func NewAPIHandler(store models.ToDoStore, tokens models.TokenStore) *APIHandler {
+	return &APIHandler{store: store, tokens: tokens}
+}
+
+// apiError is the body of every non-2xx API response.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiConflict is the body of a 409 or 412 answer to an update based on an
+// old version of a todo: the error, plus the todo as it is now.
+type apiConflict struct {
+	Error   apiErrorBody `json:"error"`
+	Current *models.ToDo `json:"current"`
+}
+
+// todoList is the body of GET /api/v1/todos, its search and the trash.
+type todoList struct {
+	Todos []*models.ToDo `json:"todos"`
+	// NextCursor fetches the following page of GET /api/v1/todos; it is
+	// omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// todoWrite is the body of POST and PUT requests. The deadline is given as
+// a local date ("2006-01-02"), optional time ("15:04") and IANA zone
+// (default UTC); leaving due_date empty means no deadline. A list_id of 0
+// (or none) is the Inbox.
+type todoWrite struct {
+	ListID     int             `json:"list_id"`
+	Title      string          `json:"title"`
+	Completed  bool            `json:"completed"`
+	Priority   models.Priority `json:"priority"`
+	DueDate    string          `json:"due_date"`
+	DueTime    string          `json:"due_time"`
+	DueTZ      string          `json:"due_tz"`
+	Recurrence string          `json:"recurrence"`
+	Notes      string          `json:"notes"`
+	Tags       []string        `json:"tags"`
+}
+
+// input validates w and converts it for the store.
+func (w todoWrite) input() (models.ToDoInput, error) {
+	in := models.ToDoInput{ListID: w.ListID, Title: w.Title, Completed: w.Completed, Priority: w.Priority}
+	if err := in.SetDue(w.DueDate, w.DueTime, w.DueTZ); err != nil {
+		return in, err
+	}
+	var err error
+	if in.Notes, err = models.NormalizeNotes(w.Notes); err != nil {
+		return in, err
+	}
+	return in, in.SetRecurrence(w.Recurrence)
+}
+
+// todoPatch is the body of PATCH requests; absent fields are left alone.
+// An empty due_date clears the deadline.
+type todoPatch struct {
+	ListID     *int             `json:"list_id"`
+	Title      *string          `json:"title"`
+	Completed  *bool            `json:"completed"`
+	Priority   *models.Priority `json:"priority"`
+	DueDate    *string          `json:"due_date"`
+	DueTime    *string          `json:"due_time"`
+	DueTZ      *string          `json:"due_tz"`
+	Recurrence *string          `json:"recurrence"`
+	Notes      *string          `json:"notes"`
+	Tags       *[]string        `json:"tags"`
+}
+
+// tagList is the body of POST /api/v1/todos/{id}/tags.
+type tagList struct {
+	Tags []string `json:"tags"`
+}
+
+// tagCounts is the body of GET /api/v1/tags.
+type tagCounts struct {
+	Tags []models.TagCount `json:"tags"`
+}
+
+// todoMove is the body of POST /api/v1/todos/{id}/move: the todos it
+// should now come after and/or before (0 or absent for an open end).
+type todoMove struct {
+	After  int `json:"after"`
+	Before int `json:"before"`
+}
+
+// todoBulk is the body of POST /api/v1/todos/bulk: the todos to change and
+// the operation ("complete", "reopen", "delete", "move", "tag" or
+// "set_due"). list_id is where "move" puts them, tags what "tag" adds, and
+// the due fields, as in todoWrite, the deadline "set_due" gives them.
+type todoBulk struct {
+	IDs     []int    `json:"ids"`
+	Op      string   `json:"op"`
+	ListID  int      `json:"list_id"`
+	Tags    []string `json:"tags"`
+	DueDate string   `json:"due_date"`
+	DueTime string   `json:"due_time"`
+	DueTZ   string   `json:"due_tz"`
+}
+
+// action validates b's operation and converts it for the store.
+func (b todoBulk) action() (models.BulkAction, error) {
+	op, err := models.ParseBulkOp(b.Op)
+	if err != nil {
+		return models.BulkAction{}, err
+	}
+	a := models.BulkAction{Op: op, ListID: b.ListID, Tags: b.Tags}
+	if op == models.BulkSetDue {
+		err = a.SetDue(b.DueDate, b.DueTime, b.DueTZ)
+	}
+	return a, err
+}
+
+// itemList is the body of GET /api/v1/todos/{id}/items.
+type itemList struct {
+	Items []*models.ChecklistItem `json:"items"`
+}
+
+// itemWrite is the body of POST /api/v1/todos/{id}/items.
+type itemWrite struct {
+	Title string `json:"title"`
+}
+
+// itemPatch is the body of PATCH /api/v1/todos/{id}/items/{item}.
+type itemPatch struct {
+	Done *bool `json:"done"`
+}
+
+// listCollection is the body of GET /api/v1/lists.
+type listCollection struct {
+	Lists []*models.List `json:"lists"`
+}
+
+// listWrite is the body of POST /api/v1/lists.
+type listWrite struct {
+	Name string `json:"name"`
+}
+
+// listPatch is the body of PATCH /api/v1/lists/{id}; absent fields are
+// left alone.
+type listPatch struct {
+	Name     *string `json:"name"`
+	Archived *bool   `json:"archived"`
+}
+
+// tokenList is the body of GET /api/v1/tokens.
+type tokenList struct {
+	Tokens []*models.Token `json:"tokens"`
+}
+
+// tokenCreate is the body of POST /api/v1/tokens.
+type tokenCreate struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// tokenCreated is returned once, when a token is issued.
+type tokenCreated struct {
+	Token  *models.Token `json:"token"`
+	Secret string        `json:"secret"`
+}
+
+// Routes returns the API mux. Mount it at "/api/v1/".
+func (a *APIHandler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPrefix+"/todos", a.listToDos)
+	mux.HandleFunc("POST "+apiPrefix+"/todos", a.createToDo)
+	mux.HandleFunc("DELETE "+apiPrefix+"/todos/completed", a.clearCompleted)
+	mux.HandleFunc("GET "+apiPrefix+"/todos/search", a.searchToDos)
+	mux.HandleFunc("POST "+apiPrefix+"/todos/bulk", a.bulkToDos)
+	mux.HandleFunc("GET "+apiPrefix+"/todos/{id}", a.getToDo)
+	mux.HandleFunc("PUT "+apiPrefix+"/todos/{id}", a.replaceToDo)
+	mux.HandleFunc("PATCH "+apiPrefix+"/todos/{id}", a.patchToDo)
+	mux.HandleFunc("DELETE "+apiPrefix+"/todos/{id}", a.deleteToDo)
+	mux.HandleFunc("POST "+apiPrefix+"/todos/{id}/move", a.moveToDo)
+	mux.HandleFunc("POST "+apiPrefix+"/todos/{id}/tags", a.tagToDo)
+	mux.HandleFunc("DELETE "+apiPrefix+"/todos/{id}/tags/{tag}", a.untagToDo)
+	mux.HandleFunc("GET "+apiPrefix+"/todos/{id}/items", a.listItems)
+	mux.HandleFunc("POST "+apiPrefix+"/todos/{id}/items", a.addItem)
+	mux.HandleFunc("PATCH "+apiPrefix+"/todos/{id}/items/{item}", a.patchItem)
+	mux.HandleFunc("DELETE "+apiPrefix+"/todos/{id}/items/{item}", a.deleteItem)
+	mux.HandleFunc("GET "+apiPrefix+"/trash", a.listTrash)
+	mux.HandleFunc("POST "+apiPrefix+"/trash/{id}/restore", a.restoreToDo)
+	mux.HandleFunc("DELETE "+apiPrefix+"/trash/{id}", a.purgeToDo)
+	mux.HandleFunc("GET "+apiPrefix+"/tags", a.listTags)
+	mux.HandleFunc("GET "+apiPrefix+"/audit", a.listEvents)
+	mux.HandleFunc("GET "+apiPrefix+"/lists", a.listLists)
+	mux.HandleFunc("POST "+apiPrefix+"/lists", a.createList)
+	mux.HandleFunc("GET "+apiPrefix+"/lists/{id}", a.getList)
+	mux.HandleFunc("PATCH "+apiPrefix+"/lists/{id}", a.patchList)
+	mux.HandleFunc("DELETE "+apiPrefix+"/lists/{id}", a.deleteList)
+	mux.HandleFunc("GET "+apiPrefix+"/tokens", a.sessionOnly(a.listTokens))
+	mux.HandleFunc("POST "+apiPrefix+"/tokens", a.sessionOnly(a.createToken))
+	mux.HandleFunc("DELETE "+apiPrefix+"/tokens/{id}", a.sessionOnly(a.revokeToken))
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		if allow := allowedMethods(mux, r); len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+			return
+		}
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such endpoint")
+	})
+	return a.requireUser(mux)
+}
+
+// allowedMethods lists the methods mux has a route for at r's path, other
+// than its catch-all. The catch-all answers every method, so without this
+// a known path called the wrong way would get a 404 rather than a 405.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	_, catchAll := mux.Handler(r)
+	var allow []string
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		if _, pattern := mux.Handler(probe); pattern != catchAll {
+			allow = append(allow, m)
+		}
+	}
+	return allow
+}
+
+// requireUser answers 401 instead of redirecting to /login when there is no
+// signed-in user.
+func (a *APIHandler) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestUser(r) == "" {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *APIHandler) listToDos(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sort, err := models.ParseSort(q.Get("sort"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_sort", err.Error())
+		return
+	}
+	opts, ok := apiFilter(w, r)
+	if !ok {
+		return
+	}
+	opts.Sort = sort
+	if opts.Status, err = models.ParseStatus(q.Get("status")); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_status", err.Error())
+		return
+	}
+	limit := 0
+	if raw := q.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > models.MaxPageSize {
+			writeAPIError(w, http.StatusBadRequest, "invalid_limit",
+				fmt.Sprintf("limit must be between 1 and %d", models.MaxPageSize))
+			return
+		}
+	}
+	page, err := a.store.GetPage(requestUser(r), opts, limit, q.Get("cursor"))
+	if errors.Is(err, models.ErrInvalidCursor) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_cursor", err.Error())
+		return
+	}
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if page.Next != "" {
+		next := *r.URL
+		q.Set("cursor", page.Next)
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	writeJSON(w, http.StatusOK, todoList{Todos: page.Todos, NextCursor: page.Next})
+}
+
+// searchToDos answers GET /api/v1/todos/search?q=… with the best matches
+// first; list=, tag= and match= narrow it as they do for GET /todos.
+func (a *APIHandler) searchToDos(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", "q is required")
+		return
+	}
+	opts, ok := apiFilter(w, r)
+	if !ok {
+		return
+	}
+	todos, err := a.store.Search(requestUser(r), q, opts)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, todoList{Todos: todos})
+}
+
+// apiFilter reads the list=, tag= and match= query parameters, answering
+// 400 and returning false when they are malformed.
+func apiFilter(w http.ResponseWriter, r *http.Request) (models.ListOptions, bool) {
+	listID, ok := apiListParam(w, r)
+	if !ok {
+		return models.ListOptions{}, false
+	}
+	q := r.URL.Query()
+	opts := models.ListOptions{ListID: listID}
+	var err error
+	if opts.Tags, err = models.NormalizeTags(q["tag"]); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_tag", err.Error())
+		return opts, false
+	}
+	if opts.TagMatch, err = models.ParseTagMatch(q.Get("match")); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_tag", err.Error())
+		return opts, false
+	}
+	return opts, true
+}
+
+func (a *APIHandler) getToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	todo, err := a.store.Get(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeToDo(w, http.StatusOK, todo)
+}
+
+func (a *APIHandler) createToDo(w http.ResponseWriter, r *http.Request) {
+	var in todoWrite
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if strings.TrimSpace(in.Title) == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_title", "title cannot be empty")
+		return
+	}
+	todoIn, err := in.input()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	tags, err := models.NormalizeTags(in.Tags)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	user := requestUser(r)
+	store := storeFor(a.store, r)
+	todo, err := store.Create(user, todoIn)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if len(tags) > 0 {
+		if todo, err = retag(store, todo, user, tags); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/todos/%d", apiPrefix, todo.ID))
+	writeToDo(w, http.StatusCreated, todo)
+}
+
+func (a *APIHandler) replaceToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in todoWrite
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if strings.TrimSpace(in.Title) == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_title", "title cannot be empty")
+		return
+	}
+	todoIn, err := in.input()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if _, err := models.NormalizeTags(in.Tags); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if todoIn.Version, ok = ifMatch(w, r); !ok {
+		return
+	}
+	todo, err := a.store.Get(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	a.update(w, r, todo, todoIn, &in.Tags)
+}
+
+func (a *APIHandler) patchToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in todoPatch
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	user := requestUser(r)
+	todo, err := a.store.Get(id, user)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if in.Title != nil {
+		if strings.TrimSpace(*in.Title) == "" {
+			writeAPIError(w, http.StatusUnprocessableEntity, "invalid_title", "title cannot be empty")
+			return
+		}
+	}
+	todoIn := todo.Input()
+	if in.ListID != nil {
+		todoIn.ListID = *in.ListID
+	}
+	if in.Title != nil {
+		todoIn.Title = *in.Title
+	}
+	if in.Completed != nil {
+		todoIn.Completed = *in.Completed
+	}
+	if in.Priority != nil {
+		todoIn.Priority = *in.Priority
+	}
+	if in.DueDate != nil || in.DueTime != nil || in.DueTZ != nil {
+		// Fill the parts that weren't sent from the current deadline, so
+		// e.g. {"due_time":"17:00"} keeps the date and zone.
+		date, clock, tz := todo.DueDate(), todo.DueTime(), todo.DueTZ
+		if in.DueDate != nil {
+			date = *in.DueDate
+			if date == "" {
+				clock = ""
+			}
+		}
+		if in.DueTime != nil {
+			clock = *in.DueTime
+		}
+		if in.DueTZ != nil {
+			tz = *in.DueTZ
+		}
+		if err := todoIn.SetDue(date, clock, tz); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	if in.Recurrence != nil {
+		if err := todoIn.SetRecurrence(*in.Recurrence); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	if in.Notes != nil {
+		notes, err := models.NormalizeNotes(*in.Notes)
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		todoIn.Notes = notes
+	}
+	if in.Tags != nil {
+		if _, err := models.NormalizeTags(*in.Tags); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	// The patch applies to what was just read; without If-Match, a
+	// change in between is reported as a conflict rather than undone.
+	if todoIn.Version, ok = ifMatch(w, r); !ok {
+		return
+	}
+	if todoIn.Version == 0 {
+		todoIn.Version = todo.Version
+	}
+	a.update(w, r, todo, todoIn, in.Tags)
+}
+
+func (a *APIHandler) deleteToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	if err := storeFor(a.store, r).Delete(id, requestUser(r)); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *APIHandler) clearCompleted(w http.ResponseWriter, r *http.Request) {
+	listID, ok := apiListParam(w, r)
+	if !ok {
+		return
+	}
+	if err := storeFor(a.store, r).ClearCompleted(requestUser(r), listID); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkToDos answers with the todos the operation changed, as they are
+// now; todos already as asked are left out.
+func (a *APIHandler) bulkToDos(w http.ResponseWriter, r *http.Request) {
+	var in todoBulk
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	action, err := in.action()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	user := requestUser(r)
+	store := storeFor(a.store, r)
+	todos, _, err := store.Bulk(user, in.IDs, action)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, todoList{Todos: todos})
+}
+
+func (a *APIHandler) listTrash(w http.ResponseWriter, r *http.Request) {
+	todos, err := a.store.Trash(requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, todoList{Todos: todos})
+}
+
+func (a *APIHandler) restoreToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	todo, err := storeFor(a.store, r).Restore(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeToDo(w, http.StatusOK, todo)
+}
+
+func (a *APIHandler) purgeToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	if err := storeFor(a.store, r).Purge(id, requestUser(r)); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *APIHandler) moveToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in todoMove
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	todo, err := storeFor(a.store, r).Move(id, requestUser(r), in.Before, in.After)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeToDo(w, http.StatusOK, todo)
+}
+
+func (a *APIHandler) tagToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in tagList
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	user := requestUser(r)
+	if err := storeFor(a.store, r).Tag(id, user, in.Tags...); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	todo, err := a.store.Get(id, user)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeToDo(w, http.StatusOK, todo)
+}
+
+func (a *APIHandler) untagToDo(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	if err := storeFor(a.store, r).Untag(id, requestUser(r), r.PathValue("tag")); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *APIHandler) listTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.store.Tags(requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tagCounts{Tags: tags})
+}
+
+func (a *APIHandler) listItems(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	items, err := a.store.Items(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, itemList{Items: items})
+}
+
+func (a *APIHandler) addItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in itemWrite
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	item, err := storeFor(a.store, r).AddItem(id, requestUser(r), in.Title)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/todos/%d/items/%d", apiPrefix, id, item.ID))
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func (a *APIHandler) patchItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := apiPathID(w, r, "item")
+	if !ok {
+		return
+	}
+	var in itemPatch
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if in.Done == nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_item", "done is required")
+		return
+	}
+	item, err := storeFor(a.store, r).SetItemDone(id, itemID, requestUser(r), *in.Done)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (a *APIHandler) deleteItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := apiPathID(w, r, "item")
+	if !ok {
+		return
+	}
+	if err := a.store.DeleteItem(id, itemID, requestUser(r)); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *APIHandler) listLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := a.store.Lists(requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, listCollection{Lists: lists})
+}
+
+func (a *APIHandler) getList(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	list, err := a.store.GetList(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *APIHandler) createList(w http.ResponseWriter, r *http.Request) {
+	var in listWrite
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	list, err := a.store.CreateList(requestUser(r), in.Name)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/lists/%d", apiPrefix, list.ID))
+	writeJSON(w, http.StatusCreated, list)
+}
+
+func (a *APIHandler) patchList(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	var in listPatch
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	user := requestUser(r)
+	list, err := a.store.GetList(id, user)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if in.Name != nil {
+		if list, err = a.store.RenameList(id, user, *in.Name); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	if in.Archived != nil {
+		if list, err = a.store.ArchiveList(id, user, *in.Archived); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *APIHandler) deleteList(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	if err := storeFor(a.store, r).DeleteList(id, requestUser(r)); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// update saves in, and tags unless nil, over old and answers with the
+// result. Completing a recurring todo creates its next occurrence, linked
+// with rel="next".
+func (a *APIHandler) update(w http.ResponseWriter, r *http.Request, old *models.ToDo, in models.ToDoInput, tags *[]string) {
+	user := requestUser(r)
+	store := storeFor(a.store, r)
+	todo, err := store.Update(old.ID, in, user)
+	if errors.Is(err, models.ErrConflict) {
+		a.conflict(w, r, old.ID)
+		return
+	}
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	// completing a recurring todo spawned its next occurrence
+	next := todo.Spawned
+	// Tags go after the fields, so they aren't changed by an update that
+	// conflicts.
+	if tags != nil {
+		retagged, err := setTags(store, todo, user, *tags)
+		if err == nil && retagged {
+			todo, err = store.Get(todo.ID, user)
+		}
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	if next != nil {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/todos/%d>; rel="next"`, apiPrefix, next.ID))
+	}
+	writeToDo(w, http.StatusOK, todo)
+}
+
+// retag tags a freshly created todo and returns it re-read.
+func retag(store models.ToDoStore, todo *models.ToDo, user string, tags []string) (*models.ToDo, error) {
+	if err := store.Tag(todo.ID, user, tags...); err != nil {
+		return nil, err
+	}
+	return store.Get(todo.ID, user)
+}
+
+// sessionOnly refuses bearer-token callers, so a leaked token can't be used
+// to mint more tokens or revoke the owner's others.
+func (a *APIHandler) sessionOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if viaToken(r) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "tokens can only be managed from a signed-in session")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *APIHandler) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := a.tokens.List(requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokenList{Tokens: tokens})
+}
+
+func (a *APIHandler) createToken(w http.ResponseWriter, r *http.Request) {
+	var in tokenCreate
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_name", "name cannot be empty")
+		return
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_expiry", "expires_at must be in the future")
+		return
+	}
+	secret, token, err := a.tokens.Create(requestUser(r), in.Name, in.ExpiresAt)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/tokens/%d", apiPrefix, token.ID))
+	writeJSON(w, http.StatusCreated, tokenCreated{Token: token, Secret: secret})
+}
+
+func (a *APIHandler) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+	if err := a.tokens.Revoke(id, requestUser(r)); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// conflict answers an update that lost to someone else's: 412 if the
+// client sent If-Match, 409 otherwise, with the todo as it is now.
+func (a *APIHandler) conflict(w http.ResponseWriter, r *http.Request, id int) {
+	current, err := a.store.Get(id, requestUser(r))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	status, code := http.StatusConflict, "conflict"
+	if r.Header.Get("If-Match") != "" {
+		status, code = http.StatusPreconditionFailed, "precondition_failed"
+	}
+	w.Header().Set("ETag", etag(current))
+	writeJSON(w, status, apiConflict{
+		Error:   apiErrorBody{Code: code, Message: "the todo has changed; re-apply your edit to current"},
+		Current: current,
+	})
+}
+
+// etag is the ETag of a todo: its version, quoted.
+func etag(t *models.ToDo) string {
+	return strconv.Quote(strconv.Itoa(t.Version))
+}
+
+// ifMatch parses an If-Match header naming the todo version an update
+// is based on (an ETag from a previous response). It returns 0 when there
+// is none or it is "*", and answers 400 when it isn't one of ours.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(raw, `"`), `"`))
+	if err != nil || n <= 0 || len(raw) < 3 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		writeAPIError(w, http.StatusBadRequest, "invalid_if_match", `If-Match must be a single ETag from this API, e.g. "3"`)
+		return 0, false
+	}
+	return n, true
+}
+
+// apiID parses the {id} path segment, answering 400 when it isn't a number.
+func apiID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return apiPathID(w, r, "id")
+}
+
+// apiPathID parses the named path segment the way apiID parses {id}.
+func apiPathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id <= 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_id", name+" must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// apiListParam parses the optional ?list= filter; 0 (or none) means every
+// list.
+func apiListParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("list")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_list", "list must be a list id")
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeJSON reads a single JSON object into v, answering 400 (or 415) on
+// malformed input or unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "body must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return false
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "body must contain a single JSON object")
+		return false
+	}
+	return true
+}
+
+// apiStoreError is the JSON twin of storeError.
+func apiStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, "not_found", "not found")
+		return
+	case errors.Is(err, models.ErrInvalidDue):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_due", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidTag):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_tag", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidRecurrence):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_recurrence", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidMove):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_move", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidNotes):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_notes", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidBulk):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_bulk", err.Error())
+		return
+	case errors.Is(err, models.ErrItemNotFound):
+		writeAPIError(w, http.StatusNotFound, "not_found", "checklist item not found")
+		return
+	case errors.Is(err, models.ErrInvalidItem):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_item", err.Error())
+		return
+	case errors.Is(err, models.ErrListNotFound):
+		writeAPIError(w, http.StatusNotFound, "not_found", "list not found")
+		return
+	case errors.Is(err, models.ErrListExists):
+		writeAPIError(w, http.StatusConflict, "list_exists", err.Error())
+		return
+	case errors.Is(err, models.ErrInvalidList):
+		writeAPIError(w, http.StatusUnprocessableEntity, "invalid_list", err.Error())
+		return
+	}
+	logging.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Code: code, Message: msg}})
+}
+
+// writeToDo answers with todo and its ETag.
+func writeToDo(w http.ResponseWriter, status int, todo *models.ToDo) {
+	w.Header().Set("ETag", etag(todo))
+	writeJSON(w, status, todo)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Errorf("encoding JSON response: %v", err)
+	}
+}
@@ -0,0 +1,742 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// sessionCookie returns a signed session cookie for username.
+func sessionCookie(t *testing.T, username string) *http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	sess, _ := sessionStore.Get(req, sessionName)
+	sess.Values["user"] = username
+	if err := sess.Save(req, rec); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+// apiDo sends a request to the API as username ("" for anonymous).
+func apiDo(t *testing.T, h http.Handler, username, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if username != "" {
+		req.AddCookie(sessionCookie(t, username))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+}
+
+func TestAPIRequiresAuth(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	rec := apiDo(t, h, "", http.MethodGet, "/api/v1/todos", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	var e apiError
+	decodeBody(t, rec, &e)
+	if e.Error.Code != "unauthorized" {
+		t.Fatalf("unexpected error body %+v", e)
+	}
+}
+
+func TestAPICRUD(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"buy milk"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/todos/1" {
+		t.Fatalf("unexpected Location %q", loc)
+	}
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	if todo.ID != 1 || todo.Title != "buy milk" {
+		t.Fatalf("unexpected todo %+v", todo)
+	}
+
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"completed":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	decodeBody(t, rec, &todo)
+	if todo.Title != "buy milk" || !todo.Completed {
+		t.Fatalf("patch should only change completed: %+v", todo)
+	}
+
+	rec = apiDo(t, h, "alice", http.MethodPut, "/api/v1/todos/1", `{"title":"buy oat milk"}`)
+	decodeBody(t, rec, &todo)
+	if rec.Code != http.StatusOK || todo.Title != "buy oat milk" || todo.Completed {
+		t.Fatalf("put: got %d %+v", rec.Code, todo)
+	}
+
+	if rec = apiDo(t, h, "bob", http.MethodGet, "/api/v1/todos/1", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("other user get: expected 404, got %d", rec.Code)
+	}
+
+	var list todoList
+	rec = apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos", "")
+	decodeBody(t, rec, &list)
+	if len(list.Todos) != 1 {
+		t.Fatalf("list: expected 1 todo, got %+v", list)
+	}
+
+	if rec = apiDo(t, h, "alice", http.MethodDelete, "/api/v1/todos/1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rec.Code)
+	}
+	if rec = apiDo(t, h, "alice", http.MethodDelete, "/api/v1/todos/1", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAPIClearCompleted(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := NewAPIHandler(store, models.NewTokenStoreMemory()).Routes()
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"done","completed":true}`)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"open"}`)
+
+	if rec := apiDo(t, h, "alice", http.MethodDelete, "/api/v1/todos/completed", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	todos, _ := store.GetAll("alice", models.ListOptions{})
+	if len(todos) != 1 || todos[0].Title != "open" {
+		t.Fatalf("unexpected todos after clear: %+v", todos)
+	}
+}
+
+func TestAPIBadRequests(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/todos", `{"title":""}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/v1/todos", `{"title":`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos", `{"name":"x"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/todos", `{"title":"x","due_date":"tomorrow"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/v1/todos", `{"title":"x","due_date":"2030-01-02","due_tz":"Mars/Olympus"}`, http.StatusUnprocessableEntity},
+		{http.MethodGet, "/api/v1/todos/abc", "", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/nope", "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/todos", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/todos/1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		rec := apiDo(t, h, "alice", tc.method, tc.path, tc.body)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s %s: expected %d, got %d", tc.method, tc.path, tc.body, tc.want, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %s: expected a JSON error, got %q", tc.method, tc.path, ct)
+		}
+	}
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/1", "")
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, PUT, PATCH, DELETE" {
+		t.Errorf("405 for /api/v1/todos/1 allows %q", allow)
+	}
+}
+
+func TestAPIDueDates(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos",
+		`{"title":"file taxes","due_date":"2030-04-15","due_time":"17:00","due_tz":"America/New_York"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	if todo.DueAt == nil || !todo.DueHasTime || todo.DueTime() != "17:00" || todo.DueTZ != "America/New_York" {
+		t.Fatalf("unexpected deadline %+v", todo)
+	}
+	if !todo.DueAt.Equal(time.Date(2030, 4, 15, 21, 0, 0, 0, time.UTC)) {
+		t.Fatalf("DueAt = %v, want 21:00 UTC", todo.DueAt)
+	}
+
+	// Patching only the time keeps the date and zone.
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"due_time":"09:00"}`)
+	decodeBody(t, rec, &todo)
+	if todo.DueDate() != "2030-04-15" || todo.DueTime() != "09:00" || todo.DueTZ != "America/New_York" {
+		t.Fatalf("after patching the time: %+v", todo)
+	}
+
+	// An empty due_date clears the deadline; other PATCHes leave it alone.
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"title":"file taxes!"}`)
+	decodeBody(t, rec, &todo)
+	if todo.DueAt == nil {
+		t.Fatal("PATCH without due fields cleared the deadline")
+	}
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"due_date":""}`)
+	todo = models.ToDo{}
+	decodeBody(t, rec, &todo)
+	if todo.DueAt != nil || todo.DueHasTime {
+		t.Fatalf("empty due_date did not clear the deadline: %+v", todo)
+	}
+}
+
+func TestAPITags(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := NewAPIHandler(store, models.NewTokenStoreMemory()).Routes()
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"rotate certs","tags":["Ops","security"]}`)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"send invoices","tags":["billing"]}`)
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/2/tags", `{"tags":["ops"]}`)
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	if rec.Code != http.StatusOK || todo.Tags.String() != "billing, ops" {
+		t.Fatalf("tagging: got %d %+v", rec.Code, todo)
+	}
+
+	var list todoList
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos?tag=ops&tag=security&match=all", ""), &list)
+	if len(list.Todos) != 1 || list.Todos[0].ID != 1 {
+		t.Fatalf("match=all filter returned %+v", list.Todos)
+	}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos?tag=ops", ""), &list)
+	if len(list.Todos) != 2 {
+		t.Fatalf("tag filter returned %+v", list.Todos)
+	}
+
+	if rec := apiDo(t, h, "alice", http.MethodDelete, "/api/v1/todos/1/tags/ops", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("untag: expected 204, got %d", rec.Code)
+	}
+	var counts tagCounts
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/tags", ""), &counts)
+	want := []models.TagCount{{Name: "billing", Count: 1}, {Name: "ops", Count: 1}, {Name: "security", Count: 1}}
+	if len(counts.Tags) != len(want) {
+		t.Fatalf("GET /tags = %+v, want %+v", counts.Tags, want)
+	}
+	for i := range want {
+		if counts.Tags[i] != want[i] {
+			t.Fatalf("GET /tags = %+v, want %+v", counts.Tags, want)
+		}
+	}
+
+	// PATCH with "tags" replaces them; PUT without drops them.
+	decodeBody(t, apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"tags":["urgent"]}`), &todo)
+	if todo.Tags.String() != "urgent" {
+		t.Fatalf("PATCH tags: got %v", todo.Tags)
+	}
+	todo = models.ToDo{}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodPut, "/api/v1/todos/1", `{"title":"rotate certs"}`), &todo)
+	if todo.Tags == nil || len(todo.Tags) != 0 {
+		t.Fatalf("PUT without tags: got %#v", todo.Tags)
+	}
+
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/1/tags", `{"tags":["two words"]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad tag: expected 422, got %d", rec.Code)
+	}
+}
+
+func TestAPILists(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/lists", `{"name":"Work"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create list: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var work models.List
+	decodeBody(t, rec, &work)
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/lists", `{"name":"WORK"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate name: expected 409, got %d", rec.Code)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/lists", `{"name":"inbox"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reserved name: expected 422, got %d", rec.Code)
+	}
+
+	body := fmt.Sprintf(`{"title":"ship release","list_id":%d}`, work.ID)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", body)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"buy milk"}`)
+
+	var list todoList
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, fmt.Sprintf("/api/v1/todos?list=%d", work.ID), ""), &list)
+	if len(list.Todos) != 1 || list.Todos[0].Title != "ship release" {
+		t.Fatalf("?list= did not filter: %+v", list.Todos)
+	}
+
+	var lists listCollection
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/lists", ""), &lists)
+	if len(lists.Lists) != 2 || !lists.Lists[0].Inbox || lists.Lists[1].Open != 1 {
+		t.Fatalf("unexpected lists %+v", lists.Lists)
+	}
+
+	path := fmt.Sprintf("/api/v1/lists/%d", work.ID)
+	rec = apiDo(t, h, "alice", http.MethodPatch, path, `{"name":"Job","archived":true}`)
+	var patched models.List
+	decodeBody(t, rec, &patched)
+	if patched.Name != "Job" || !patched.Archived() {
+		t.Fatalf("patch list: got %d %+v", rec.Code, patched)
+	}
+	inbox := fmt.Sprintf("/api/v1/lists/%d", lists.Lists[0].ID)
+	if rec := apiDo(t, h, "alice", http.MethodDelete, inbox, ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("deleting the Inbox: expected 422, got %d", rec.Code)
+	}
+	if rec := apiDo(t, h, "bob", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("foreign list: expected 404, got %d", rec.Code)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete list: expected 204, got %d", rec.Code)
+	}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos", ""), &list)
+	if len(list.Todos) != 1 || list.Todos[0].Title != "buy milk" {
+		t.Fatalf("deleting a list should delete its todos: %+v", list.Todos)
+	}
+}
+
+func TestAPIChecklist(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"release"}`)
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/1/items", `{"title":"tag"}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/api/v1/todos/1/items/1" {
+		t.Fatalf("add item: got %d, Location %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/1/items", `{"title":"announce"}`)
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/1/items", `{"title":""}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("blank item: expected 422, got %d", rec.Code)
+	}
+
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1/items/1", `{"done":true}`)
+	var item models.ChecklistItem
+	decodeBody(t, rec, &item)
+	if !item.Done {
+		t.Fatalf("patch item: got %d %+v", rec.Code, item)
+	}
+	var todo models.ToDo
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/1", ""), &todo)
+	if todo.ItemsDone != 1 || todo.ItemsTotal != 2 {
+		t.Fatalf("todo counts = %d/%d, want 1/2", todo.ItemsDone, todo.ItemsTotal)
+	}
+
+	if rec := apiDo(t, h, "alice", http.MethodDelete, "/api/v1/todos/1/items/2", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete item: expected 204, got %d", rec.Code)
+	}
+	var items itemList
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/1/items", ""), &items)
+	if len(items.Items) != 1 || items.Items[0].Title != "tag" {
+		t.Fatalf("unexpected items %+v", items.Items)
+	}
+	if rec := apiDo(t, h, "bob", http.MethodGet, "/api/v1/todos/1/items", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("foreign checklist: expected 404, got %d", rec.Code)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1/items/9", `{"done":true}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing item: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAPIRecurrence(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos",
+		`{"title":"invoice","due_date":"2030-01-31","recurrence":"freq=monthly;bymonthday=-1"}`)
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	if todo.Recurrence != "FREQ=MONTHLY;BYMONTHDAY=-1" {
+		t.Fatalf("create: got %d, recurrence %q", rec.Code, todo.Recurrence)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"recurrence":"FREQ=HOURLY"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad rule: expected 422, got %d", rec.Code)
+	}
+
+	rec = apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"completed":true}`)
+	if link := rec.Header().Get("Link"); link != `</api/v1/todos/2>; rel="next"` {
+		t.Fatalf("completing: Link %q", link)
+	}
+	var done models.ToDo
+	decodeBody(t, rec, &done)
+	if !done.Completed || done.Recurrence != "" {
+		t.Fatalf("completed occurrence %+v", done)
+	}
+	var next models.ToDo
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/2", ""), &next)
+	if next.Completed || next.DueDate() != "2030-02-28" || next.Recurrence != "FREQ=MONTHLY;BYMONTHDAY=-1" {
+		t.Fatalf("next occurrence %+v", next)
+	}
+
+	// Clearing the rule stops the series.
+	apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/2", `{"recurrence":""}`)
+	if rec := apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/2", `{"completed":true}`); rec.Header().Get("Link") != "" {
+		t.Fatal("a one-off todo spawned a next occurrence")
+	}
+}
+
+func TestAPINotes(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+
+	rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"report","notes":"  - one\r\n- two\n"}`)
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	if todo.Notes != "- one\n- two" {
+		t.Fatalf("create: got %d, notes %q", rec.Code, todo.Notes)
+	}
+
+	// PATCH without notes keeps them; with notes replaces them.
+	var patched models.ToDo
+	decodeBody(t, apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"title":"final report"}`), &patched)
+	if patched.Notes != todo.Notes {
+		t.Fatalf("patching the title changed notes to %q", patched.Notes)
+	}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"notes":"done"}`), &patched)
+	if patched.Notes != "done" {
+		t.Fatalf("patched notes %q", patched.Notes)
+	}
+
+	long := strings.Repeat("x", models.MaxNotesLen+1)
+	if rec := apiDo(t, h, "alice", http.MethodPatch, "/api/v1/todos/1", `{"notes":"`+long+`"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("over-long notes: expected 422, got %d", rec.Code)
+	}
+}
+
+func TestAPIMove(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	for _, title := range []string{"a", "b", "c"} {
+		apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"`+title+`"}`)
+	}
+
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/3/move", `{"after":1}`); rec.Code != http.StatusOK {
+		t.Fatalf("move: got %d: %s", rec.Code, rec.Body)
+	}
+	var list struct{ Todos []models.ToDo }
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos", ""), &list)
+	var got []int
+	for _, todo := range list.Todos {
+		got = append(got, todo.ID)
+	}
+	if fmt.Sprint(got) != "[1 3 2]" {
+		t.Fatalf("order after move = %v, want [1 3 2]", got)
+	}
+
+	if rec := apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/3/move", `{}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("move without anchor: expected 422, got %d", rec.Code)
+	}
+}
+
+func TestAPISearch(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"Buy milk"}`)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"Call plumber","notes":"the milk tap leaks","tags":["home"]}`)
+	apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"Tax return"}`)
+
+	var res todoList
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/search?q=milk", ""), &res)
+	if len(res.Todos) != 2 || res.Todos[0].Title != "Buy milk" {
+		t.Fatalf("search milk = %+v; want the title match first", res.Todos)
+	}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/search?q=milk&tag=home", ""), &res)
+	if len(res.Todos) != 1 || res.Todos[0].Title != "Call plumber" {
+		t.Fatalf("search milk tagged home = %+v", res.Todos)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/search?q=+", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("blank query: expected 400, got %d", rec.Code)
+	}
+	// "search" isn't mistaken for a todo id.
+	if rec := apiDo(t, h, "alice", http.MethodGet, "/api/v1/todos/search?q=tax", ""); rec.Code != http.StatusOK {
+		t.Fatalf("search route: got %d", rec.Code)
+	}
+}
+
+func TestAPIPaging(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	for i := 1; i <= 5; i++ {
+		apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", fmt.Sprintf(`{"title":"t%d","completed":%v}`, i, i == 2))
+	}
+
+	// Follow the Link headers through the active todos, two at a time.
+	var titles []string
+	next := "/api/v1/todos?status=active&limit=2"
+	for pages := 0; next != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("paging doesn't end")
+		}
+		rec := apiDo(t, h, "alice", http.MethodGet, next, "")
+		var page todoList
+		decodeBody(t, rec, &page)
+		for _, todo := range page.Todos {
+			titles = append(titles, todo.Title)
+		}
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			if page.NextCursor == "" || !strings.Contains(link, "cursor="+page.NextCursor) {
+				t.Fatalf("Link %q doesn't match next_cursor %q", link, page.NextCursor)
+			}
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if strings.Join(titles, ",") != "t1,t3,t4,t5" {
+		t.Fatalf("paged titles = %v", titles)
+	}
+
+	for _, target := range []string{
+		"/api/v1/todos?limit=0",
+		"/api/v1/todos?limit=1000",
+		"/api/v1/todos?status=done",
+		"/api/v1/todos?cursor=bogus",
+	} {
+		if rec := apiDo(t, h, "alice", http.MethodGet, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestAPITrash(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	var todo models.ToDo
+	decodeBody(t, apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos", `{"title":"oops"}`), &todo)
+	path := fmt.Sprintf("/api/v1/todos/%d", todo.ID)
+	trashPath := fmt.Sprintf("/api/v1/trash/%d", todo.ID)
+
+	apiDo(t, h, "alice", http.MethodDelete, path, "")
+	if rec := apiDo(t, h, "alice", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET trashed todo: expected 404, got %d", rec.Code)
+	}
+	var trash todoList
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/trash", ""), &trash)
+	if len(trash.Todos) != 1 || trash.Todos[0].ID != todo.ID || trash.Todos[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v", trash.Todos)
+	}
+
+	rec := apiDo(t, h, "alice", http.MethodPost, trashPath+"/restore", "")
+	var restored models.ToDo
+	decodeBody(t, rec, &restored)
+	if rec.Code != http.StatusOK || restored.DeletedAt != nil {
+		t.Fatalf("restore: got %d %+v", rec.Code, restored)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodPost, trashPath+"/restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("restoring a live todo: expected 404, got %d", rec.Code)
+	}
+
+	apiDo(t, h, "alice", http.MethodDelete, path, "")
+	if rec := apiDo(t, h, "bob", http.MethodDelete, trashPath, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("purge as another user: expected 404, got %d", rec.Code)
+	}
+	if rec := apiDo(t, h, "alice", http.MethodDelete, trashPath, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("purge: expected 204, got %d", rec.Code)
+	}
+	decodeBody(t, apiDo(t, h, "alice", http.MethodGet, "/api/v1/trash", ""), &trash)
+	if len(trash.Todos) != 0 {
+		t.Fatalf("trash after purge = %+v", trash.Todos)
+	}
+}
+
+func TestAPIAudit(t *testing.T) {
+	events := models.NewEventStoreMemory()
+	api := NewAPIHandler(models.NewAuditedStore(models.NewStoreMemory(), events), models.NewTokenStoreMemory())
+	api.Events, api.Admins = events, []string{"root"}
+	h := RequestID(api.Routes())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/todos", strings.NewReader(`{"title":"audit me"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-42")
+	req.AddCookie(sessionCookie(t, "alice"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Request-ID") != "req-42" {
+		t.Fatalf("create: got %d, request id %q", rec.Code, rec.Header().Get("X-Request-ID"))
+	}
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	path := fmt.Sprintf("/api/v1/todos/%d", todo.ID)
+	apiDo(t, h, "alice", http.MethodPatch, path, `{"completed":true}`)
+	apiDo(t, h, "bob", http.MethodPost, "/api/v1/todos", `{"title":"bob's"}`)
+	apiDo(t, h, "alice", http.MethodDelete, path, "")
+
+	if rec := apiDo(t, h, "alice", http.MethodGet, "/api/v1/audit", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin: expected 403, got %d", rec.Code)
+	}
+
+	var got eventList
+	decodeBody(t, apiDo(t, h, "root", http.MethodGet, "/api/v1/audit?user=alice", ""), &got)
+	want := []models.Action{models.ActionDelete, models.ActionComplete, models.ActionCreate}
+	if len(got.Events) != len(want) {
+		t.Fatalf("alice's events = %+v", got.Events)
+	}
+	for i, e := range got.Events {
+		if e.Action != want[i] || e.Actor != "alice" || e.ToDoID != todo.ID {
+			t.Errorf("event %d = %+v, want %s", i, e, want[i])
+		}
+	}
+	if e := got.Events[2]; e.RequestID != "req-42" || e.Changes["title"].To != "audit me" {
+		t.Errorf("create event = %+v", e)
+	}
+	if e := got.Events[1]; len(e.RequestID) != 32 || e.RequestID == "req-42" {
+		t.Errorf("requests without an ID should get a fresh one, got %q", e.RequestID)
+	}
+
+	decodeBody(t, apiDo(t, h, "root", http.MethodGet, "/api/v1/audit?limit=2", ""), &got)
+	if len(got.Events) != 2 || got.NextBefore != got.Events[1].ID {
+		t.Fatalf("first page = %+v, next %d", got.Events, got.NextBefore)
+	}
+	decodeBody(t, apiDo(t, h, "root", http.MethodGet, fmt.Sprintf("/api/v1/audit?limit=2&before=%d", got.NextBefore), ""), &got)
+	if len(got.Events) != 2 || got.Events[1].Action != models.ActionCreate {
+		t.Fatalf("second page = %+v", got.Events)
+	}
+
+	since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	decodeBody(t, apiDo(t, h, "root", http.MethodGet, "/api/v1/audit?since="+since, ""), &got)
+	if len(got.Events) != 0 {
+		t.Errorf("events since an hour from now = %+v", got.Events)
+	}
+	decodeBody(t, apiDo(t, h, "root", http.MethodGet, fmt.Sprintf("/api/v1/audit?todo=%d&until=2999-01-01T00:00:00Z", todo.ID), ""), &got)
+	if len(got.Events) != 3 {
+		t.Errorf("events of todo %d = %+v", todo.ID, got.Events)
+	}
+
+	for _, q := range []string{"todo=x", "limit=0", "limit=501", "before=-1", "since=yesterday"} {
+		if rec := apiDo(t, h, "root", http.MethodGet, "/api/v1/audit?"+q, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("?%s: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestAPIConcurrency(t *testing.T) {
+	h := NewAPIHandler(models.NewStoreMemory(), models.NewTokenStoreMemory()).Routes()
+	do := func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do(http.MethodPost, "/api/v1/todos", "", `{"title":"draft"}`)
+	if rec.Header().Get("ETag") != `"1"` {
+		t.Fatalf("create: ETag = %q", rec.Header().Get("ETag"))
+	}
+	var todo models.ToDo
+	decodeBody(t, rec, &todo)
+	path := fmt.Sprintf("/api/v1/todos/%d", todo.ID)
+	if etag := do(http.MethodGet, path, "", "").Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("GET: ETag = %q", etag)
+	}
+
+	rec = do(http.MethodPut, path, `"1"`, `{"title":"theirs","tags":["work"]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("PUT: got %d, ETag %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+
+	rec = do(http.MethodPatch, path, `"1"`, `{"title":"mine","tags":["home"]}`)
+	if rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("stale PATCH: expected 412 with the current ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	var conflict apiConflict
+	decodeBody(t, rec, &conflict)
+	if conflict.Error.Code != "precondition_failed" || conflict.Current == nil || conflict.Current.Title != "theirs" {
+		t.Fatalf("conflict body = %+v", conflict)
+	}
+	if rec := do(http.MethodGet, path, "", ""); !strings.Contains(rec.Body.String(), `"tags":["work"]`) {
+		t.Fatalf("the refused PATCH changed the todo: %s", rec.Body)
+	}
+
+	if rec := do(http.MethodPatch, path, `"3"`, `{"title":"mine"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH at the current version: got %d: %s", rec.Code, rec.Body)
+	}
+	for _, ifMatch := range []string{"*", ""} {
+		if rec := do(http.MethodPut, path, ifMatch, `{"title":"any"}`); rec.Code != http.StatusOK {
+			t.Errorf("PUT with If-Match %q: got %d", ifMatch, rec.Code)
+		}
+	}
+	for _, bad := range []string{"3", `W/"3"`, `"x"`, `"3", "4"`} {
+		if rec := do(http.MethodPut, path, bad, `{"title":"any"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("If-Match %s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+}
+
+func TestAPIBulk(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := NewAPIHandler(store, models.NewTokenStoreMemory()).Routes()
+	a, _ := store.Create("alice", models.ToDoInput{Title: "a"})
+	b, _ := store.Create("alice", models.ToDoInput{Title: "b", Recurrence: "FREQ=WEEKLY"})
+	theirs, _ := store.Create("bob", models.ToDoInput{Title: "theirs"})
+	bulk := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return apiDo(t, h, "alice", http.MethodPost, "/api/v1/todos/bulk", body)
+	}
+
+	rec := bulk(fmt.Sprintf(`{"ids":[%d,%d],"op":"complete"}`, a.ID, b.ID))
+	var got todoList
+	decodeBody(t, rec, &got)
+	if rec.Code != http.StatusOK || len(got.Todos) != 2 || !got.Todos[0].Completed {
+		t.Fatalf("complete: got %d %+v", rec.Code, got.Todos)
+	}
+	if got.Todos[1].Recurrence != "" {
+		t.Errorf("the repeating todo should hand its rule on, got %+v", got.Todos[1])
+	}
+	if active, _ := store.GetAll("alice", models.ListOptions{Status: models.StatusActive}); len(active) != 1 {
+		t.Errorf("completing should spawn the next occurrence, %d active", len(active))
+	}
+
+	// Only what changed comes back.
+	rec = bulk(fmt.Sprintf(`{"ids":[%d,%d],"op":"tag","tags":["Home"]}`, a.ID, a.ID))
+	decodeBody(t, rec, &got)
+	if len(got.Todos) != 1 || got.Todos[0].Tags.String() != "home" {
+		t.Fatalf("tag: got %+v", got.Todos)
+	}
+	rec = bulk(fmt.Sprintf(`{"ids":[%d],"op":"tag","tags":["home"]}`, a.ID))
+	decodeBody(t, rec, &got)
+	if rec.Code != http.StatusOK || len(got.Todos) != 0 {
+		t.Errorf("re-tag: got %d %+v", rec.Code, got.Todos)
+	}
+	rec = bulk(fmt.Sprintf(`{"ids":[%d],"op":"set_due","due_date":"2031-03-04","due_time":"08:00","due_tz":"Europe/Berlin"}`, a.ID))
+	decodeBody(t, rec, &got)
+	if len(got.Todos) != 1 || got.Todos[0].DueTime() != "08:00" || got.Todos[0].DueTZ != "Europe/Berlin" {
+		t.Errorf("set_due: got %+v", got.Todos)
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"ids":[1],"op":"archive"}`, http.StatusUnprocessableEntity, "invalid_bulk"},
+		{`{"ids":[],"op":"delete"}`, http.StatusUnprocessableEntity, "invalid_bulk"},
+		{fmt.Sprintf(`{"ids":[%d],"op":"tag","tags":["a b"]}`, a.ID), http.StatusUnprocessableEntity, "invalid_tag"},
+		{fmt.Sprintf(`{"ids":[%d],"op":"set_due","due_date":"soon"}`, a.ID), http.StatusUnprocessableEntity, "invalid_due"},
+		{fmt.Sprintf(`{"ids":[%d],"op":"move","list_id":987654}`, a.ID), http.StatusNotFound, "not_found"},
+		{fmt.Sprintf(`{"ids":[%d,%d],"op":"delete"}`, a.ID, theirs.ID), http.StatusNotFound, "not_found"},
+		{`{"ids":[1],"op":"delete","extra":true}`, http.StatusBadRequest, "invalid_json"},
+	} {
+		rec := bulk(tc.body)
+		var e apiError
+		decodeBody(t, rec, &e)
+		if rec.Code != tc.status || e.Error.Code != tc.code {
+			t.Errorf("%s: got %d %q, want %d %q", tc.body, rec.Code, e.Error.Code, tc.status, tc.code)
+		}
+	}
+	if _, err := store.Get(a.ID, "alice"); err != nil {
+		t.Fatalf("a failed bulk delete removed the todo: %v", err)
+	}
+
+	rec = bulk(fmt.Sprintf(`{"ids":[%d,%d],"op":"delete"}`, a.ID, b.ID))
+	decodeBody(t, rec, &got)
+	if rec.Code != http.StatusOK || len(got.Todos) != 2 || got.Todos[0].DeletedAt == nil {
+		t.Fatalf("delete: got %d %+v", rec.Code, got.Todos)
+	}
+	if trash, _ := store.Trash("alice"); len(trash) != 2 {
+		t.Errorf("delete: %d todos in the trash", len(trash))
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// requestIDHeader carries a request's ID in from a proxy and back out to
+// the client.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestID caps incoming request IDs; longer ones are replaced.
+const maxRequestID = 64
+
+// historyLimit is how many events a todo's detail page shows.
+const historyLimit = 50
+
+// RequestID is middleware that gives every request an ID, reusing a
+// well-formed X-Request-ID header from a proxy in front of us, and echoes
+// it in the response. Audit events carry it (see storeFor).
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = hex.EncodeToString(randomKey()[:16])
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), ctxRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts up to maxRequestID letters, digits, '-', '_' and
+// '.', so IDs are safe to log and store as given.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID RequestID gave r, or "".
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(ctxRequestID).(string)
+	return id
+}
+
+// storeFor returns store for making changes on behalf of r: when it is
+// audited, they are attributed to r's user and tagged with its ID.
+func storeFor(store models.ToDoStore, r *http.Request) models.ToDoStore {
+	if a, ok := store.(*models.AuditedStore); ok {
+		return a.As(requestUser(r), requestID(r))
+	}
+	return store
+}
+
+// history returns the latest events of one of user's todos, or none
+// without an event store.
+func (h *Handler) history(todoID int, user string) ([]*models.Event, error) {
+	if h.Events == nil {
+		return nil, nil
+	}
+	return h.Events.Events(models.EventFilter{Username: user, ToDoID: todoID, Limit: historyLimit})
+}
+
+// eventList is the body of GET /api/v1/audit.
+type eventList struct {
+	Events []*models.Event `json:"events"`
+	// NextBefore fetches the following, older page when passed as
+	// ?before=; it is omitted on the last page.
+	NextBefore int `json:"next_before,omitempty"`
+}
+
+// isAdmin reports whether r's user may read everyone's audit log.
+func (a *APIHandler) isAdmin(r *http.Request) bool {
+	return slices.Contains(a.Admins, requestUser(r))
+}
+
+// listEvents serves the audit log to admins, newest first, filtered by
+// ?user=, ?todo=, ?since= and ?until= (RFC 3339) and paged with ?limit=
+// and ?before=.
+func (a *APIHandler) listEvents(w http.ResponseWriter, r *http.Request) {
+	if a.Events == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "the audit log is not enabled")
+		return
+	}
+	if !a.isAdmin(r) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "the audit log is only open to admins")
+		return
+	}
+	q := r.URL.Query()
+	f := models.EventFilter{Username: q.Get("user")}
+	var ok bool
+	if f.ToDoID, ok = apiIntParam(w, q.Get("todo"), "todo", 1, 0); !ok {
+		return
+	}
+	if f.BeforeID, ok = apiIntParam(w, q.Get("before"), "before", 1, 0); !ok {
+		return
+	}
+	if f.Limit, ok = apiIntParam(w, q.Get("limit"), "limit", 1, models.MaxEvents); !ok {
+		return
+	}
+	if f.Since, ok = apiTimeParam(w, q.Get("since"), "since"); !ok {
+		return
+	}
+	if f.Until, ok = apiTimeParam(w, q.Get("until"), "until"); !ok {
+		return
+	}
+	events, err := a.Events.Events(f)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	out := eventList{Events: events}
+	limit := f.Limit
+	if limit == 0 {
+		limit = models.MaxEvents
+	}
+	if len(events) == limit {
+		out.NextBefore = events[len(events)-1].ID
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiIntParam parses an optional integer query parameter of at least min
+// and, unless max is 0, at most max; "" gives 0.
+func apiIntParam(w http.ResponseWriter, raw, name string, min, max int) (int, bool) {
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || (max > 0 && n > max) {
+		msg := fmt.Sprintf("%s must be an integer of at least %d", name, min)
+		if max > 0 {
+			msg = fmt.Sprintf("%s must be between %d and %d", name, min, max)
+		}
+		writeAPIError(w, http.StatusBadRequest, "invalid_"+name, msg)
+		return 0, false
+	}
+	return n, true
+}
+
+// apiTimeParam parses an optional RFC 3339 query parameter; "" gives the
+// zero time.
+func apiTimeParam(w http.ResponseWriter, raw, name string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_"+name, name+" must be an RFC 3339 time")
+		return time.Time{}, false
+	}
+	return t, true
+}
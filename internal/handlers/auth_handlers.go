@@ -1,107 +1,220 @@
 package handlers
 
 import (
-    "html/template"
-    "net/http"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
 
-    "github.com/gorilla/sessions"
-    "github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
+	"github.com/gorilla/sessions"
 )
 
 var (
-    // sessionStore holds our cookie-based session backend.
-    sessionStore = sessions.NewCookieStore([]byte("super-secret-key"))
-    sessionName  = "todo-session"
+	// sessionStore holds our cookie-based session backend. Until
+	// ConfigureSessions is called it signs cookies with a random per-process
+	// key, so sessions don't survive a restart.
+	sessionStore = newSessionStore(randomKey(), false)
+	sessionName  = "todo-session"
 )
 
-// AuthHandler bundles a UserStore (interface) + templates.
+// ConfigureSessions sets the key used to sign session cookies. secure marks
+// the cookies HTTPS-only.
+func ConfigureSessions(secret []byte, secure bool) {
+	sessionStore = newSessionStore(secret, secure)
+}
+
+func newSessionStore(secret []byte, secure bool) *sessions.CookieStore {
+	store := sessions.NewCookieStore(secret)
+	store.Options.HttpOnly = true
+	store.Options.Secure = secure
+	store.Options.SameSite = http.SameSiteLaxMode
+	return store
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// AuthHandler bundles a UserStore and TokenStore (interfaces) + templates.
 type AuthHandler struct {
-    userStore models.UserStore
-    Templates *template.Template
+	userStore  models.UserStore
+	tokenStore models.TokenStore
+	Templates  *templates.Set
 }
 
-// NewAuthHandler parses the auth templates and wires in any UserStore.
-func NewAuthHandler(us models.UserStore) (*AuthHandler, error) {
-    tmpl, err := template.ParseGlob("internal/templates/auth/*.html")
-    if err != nil {
-        return nil, err
-    }
-    return &AuthHandler{
-        userStore: us,       // ← this must match the struct field
-        Templates: tmpl,
-    }, nil
+// NewAuthHandler parses the auth templates from src and wires in any
+// UserStore and TokenStore.
+func NewAuthHandler(us models.UserStore, ts models.TokenStore, src templates.Source) (*AuthHandler, error) {
+	tmpl, err := src.Parse("auth/*.html")
+	if err != nil {
+		return nil, err
+	}
+	return &AuthHandler{
+		userStore:  us, // ← this must match the struct field
+		tokenStore: ts,
+		Templates:  tmpl,
+	}, nil
+}
+
+// authPageData is what the login and register pages render.
+type authPageData struct {
+	CSRFToken string
 }
 
 // LoginPage shows the GET /login form.
 func (a *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
-    a.Templates.ExecuteTemplate(w, "login.html", nil)
+	a.Templates.ExecuteTemplate(w, "login.html", authPageData{CSRFToken: csrfToken(r)})
 }
 
 // Login POSTs /login, checks credentials, and sets the session.
 func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-    if err := r.ParseForm(); err != nil {
-        http.Error(w, "invalid form", http.StatusBadRequest)
-        return
-    }
-    user := r.FormValue("username")
-    pass := r.FormValue("password")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user := r.FormValue("username")
+	pass := r.FormValue("password")
 
-    // authenticate against our store
-    if a.userStore.Authenticate(user, pass) {
-        sess, _ := sessionStore.Get(r, sessionName)
-        sess.Values["user"] = user
-        sess.Save(r, w)
-        http.Redirect(w, r, "/", http.StatusSeeOther)
-        return
-    }
+	// authenticate against our store
+	if a.userStore.Authenticate(user, pass) {
+		sess, _ := sessionStore.Get(r, sessionName)
+		sess.Values["user"] = user
+		// Signing in starts a fresh CSRF token for the new privilege level.
+		sess.Values[csrfSessionKey] = newCSRFToken()
+		sess.Save(r, w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
 
-    http.Error(w, "Invalid credentials", http.StatusForbidden)
+	http.Error(w, "Invalid credentials", http.StatusForbidden)
 }
 
 // Logout GETs /logout and clears the session.
 func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-    sess, _ := sessionStore.Get(r, sessionName)
-    delete(sess.Values, "user")
-    sess.Save(r, w)
-    http.Redirect(w, r, "/login", http.StatusSeeOther)
+	sess, _ := sessionStore.Get(r, sessionName)
+	delete(sess.Values, "user")
+	sess.Save(r, w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
 // RegisterPage shows the GET /register form.
 func (a *AuthHandler) RegisterPage(w http.ResponseWriter, r *http.Request) {
-    a.Templates.ExecuteTemplate(w, "register.html", nil)
+	a.Templates.ExecuteTemplate(w, "register.html", authPageData{CSRFToken: csrfToken(r)})
 }
 
 // Register POSTs /register and creates a new user.
 func (a *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-    if err := r.ParseForm(); err != nil {
-        http.Error(w, "invalid form", http.StatusBadRequest)
-        return
-    }
-    username := r.FormValue("username")
-    password := r.FormValue("password")
-    if username == "" || password == "" {
-        http.Error(w, "username & password required", http.StatusBadRequest)
-        return
-    }
-
-    // create via our interface
-    if err := a.userStore.Create(username, password); err != nil {
-        http.Error(w, "user already exists", http.StatusConflict)
-        return
-    }
-
-    // on success, send them to login
-    http.Redirect(w, r, "/login", http.StatusSeeOther)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+	if username == "" || password == "" {
+		http.Error(w, "username & password required", http.StatusBadRequest)
+		return
+	}
+
+	// create via our interface
+	if err := a.userStore.Create(username, password); err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			http.Error(w, "user already exists", http.StatusConflict)
+			return
+		}
+		logging.Errorf("Register failed for user=%q: %v", username, err)
+		http.Error(w, "could not create user", http.StatusInternalServerError)
+		return
+	}
+
+	// on success, send them to login
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// sessionUser returns the username stored in the session cookie, or "".
+func sessionUser(r *http.Request) string {
+	sess, _ := sessionStore.Get(r, sessionName)
+	user, _ := sess.Values["user"].(string)
+	return user
+}
+
+type ctxKey int
+
+const (
+	ctxUser ctxKey = iota
+	ctxViaToken
+	ctxCSRF
+	ctxRequestID
+)
+
+// requestUser returns the user Authenticate resolved for r, falling back to
+// the session cookie for requests that didn't pass through it.
+func requestUser(r *http.Request) string {
+	if user, ok := r.Context().Value(ctxUser).(string); ok {
+		return user
+	}
+	return sessionUser(r)
+}
+
+// viaToken reports whether r was authenticated with a bearer token.
+func viaToken(r *http.Request) bool {
+	ok, _ := r.Context().Value(ctxViaToken).(bool)
+	return ok
+}
+
+// bearerToken extracts the secret from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Authenticate is middleware that works out who is calling: either the
+// owner of an "Authorization: Bearer <token>" header or the signed-in
+// session user. A bad bearer token is rejected outright with 401 rather than
+// falling back to the cookie. It does not require anyone to be signed in;
+// AuthRequired does that.
+func (a *AuthHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			ctx := context.WithValue(r.Context(), ctxUser, sessionUser(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		user, err := a.tokenStore.Authenticate(secret)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidToken) {
+				logging.Errorf("token lookup failed: %v", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todolist"`)
+			writeAPIError(w, http.StatusUnauthorized, "invalid_token", "invalid or expired token")
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxUser, user)
+		ctx = context.WithValue(ctx, ctxViaToken, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // AuthRequired is middleware that redirects anonymous users to /login.
 func AuthRequired(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        sess, _ := sessionStore.Get(r, sessionName)
-        if _, ok := sess.Values["user"]; !ok {
-            http.Redirect(w, r, "/login", http.StatusSeeOther)
-            return
-        }
-        next.ServeHTTP(w, r)
-    })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestUser(r) == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
+)
+
+func newTestAuth(t *testing.T, tokens models.TokenStore) *AuthHandler {
+	t.Helper()
+	a, err := NewAuthHandler(models.NewUserStoreMemory(), tokens, templates.Embedded())
+	if err != nil {
+		t.Fatalf("NewAuthHandler: %v", err)
+	}
+	return a
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	todos := models.NewStoreMemory()
+	tokens := models.NewTokenStoreMemory()
+	todos.Create("alice", models.ToDoInput{Title: "from a script"})
+	secret, _, _ := tokens.Create("alice", "ci", nil)
+	h := newTestAuth(t, tokens).Authenticate(NewAPIHandler(todos, tokens).Routes())
+
+	do := func(method, path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"name":"x"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/todos", "Bearer "+secret)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "from a script") {
+		t.Fatalf("valid token: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/v1/todos", "Bearer nope"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token: expected 401, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/todos", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no credentials: expected 401, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/tokens", "Bearer "+secret); rec.Code != http.StatusForbidden {
+		t.Fatalf("token minting a token: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestAuthRequiredAcceptsBearer(t *testing.T) {
+	tokens := models.NewTokenStoreMemory()
+	secret, _, _ := tokens.Create("alice", "ci", nil)
+	var seen string
+	h := newTestAuth(t, tokens).Authenticate(AuthRequired(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestUser(r)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != "alice" {
+		t.Fatalf("expected alice, got %q", seen)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("anonymous: expected redirect, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// BulkToDos handles POST "/tasks/bulk" (the Inbox) and POST
+// "/lists/{id}/tasks/bulk" from the bulk action bar: every todo ticked in
+// ids gets the change picked in op, then the main block is re-rendered.
+// Deleting gets a toast offering to undo it.
+func (h *Handler) BulkToDos(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	ids := make([]int, 0, len(r.PostForm["ids"]))
+	for _, raw := range r.PostForm["ids"] {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	a, err := bulkFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	changed, _, err := storeFor(h.store, r).Bulk(user, ids, a)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, listPath(list), http.StatusSeeOther)
+		return
+	}
+	h.renderMain(w, r, user, list)
+	if a.Op == models.BulkDelete && len(changed) > 0 {
+		deleted := make([]int, len(changed))
+		for i, t := range changed {
+			deleted[i] = t.ID
+		}
+		h.offerUndo(w, user, "Selected tasks deleted.", undoDelete(user, deleted...))
+	}
+}
+
+// bulkFromForm reads the action bar's fields for the chosen op.
+func bulkFromForm(r *http.Request) (models.BulkAction, error) {
+	op, err := models.ParseBulkOp(r.PostFormValue("op"))
+	if err != nil {
+		return models.BulkAction{}, err
+	}
+	a := models.BulkAction{Op: op}
+	switch op {
+	case models.BulkMove:
+		raw := r.PostFormValue("list_id")
+		if a.ListID, err = strconv.Atoi(raw); err != nil {
+			return a, fmt.Errorf("%w: invalid list %q", models.ErrInvalidBulk, raw)
+		}
+	case models.BulkTag:
+		a.Tags, err = models.ParseTags(r.PostFormValue("tags"))
+	case models.BulkSetDue:
+		err = a.SetDue(r.PostFormValue("due_date"), r.PostFormValue("due_time"), dueZone(r))
+	}
+	return a, err
+}
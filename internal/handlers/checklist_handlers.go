@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// checklistData is what checklist.html renders.
+type checklistData struct {
+	ToDo  *models.ToDo
+	Items []*models.ChecklistItem
+}
+
+// checklistIDs parses the {id} and, when the route has one, {item} path
+// values.
+func checklistIDs(r *http.Request) (todoID, itemID int, err error) {
+	if todoID, err = strconv.Atoi(r.PathValue("id")); err != nil {
+		return 0, 0, err
+	}
+	if raw := r.PathValue("item"); raw != "" {
+		if itemID, err = strconv.Atoi(raw); err != nil {
+			return 0, 0, err
+		}
+	}
+	return todoID, itemID, nil
+}
+
+// renderChecklist answers a checklist request with the todo's checklist.
+// If the change reopened the todo (see models.ChecklistItem) the whole
+// app is re-rendered instead, so the todo moves out of Completed; on the
+// todo's detail page, which has no app to swap, the page is reloaded.
+func (h *Handler) renderChecklist(w http.ResponseWriter, r *http.Request, user string, todoID int, wasCompleted bool) {
+	todo, err := h.store.Get(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if todo.Completed != wasCompleted {
+		if u, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil && u.Path == todoPath(todo) {
+			w.Header().Set("HX-Refresh", "true")
+			return
+		}
+		list, err := h.store.GetList(todo.ListID, user)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		w.Header().Set("HX-Retarget", "#todoApp")
+		w.Header().Set("HX-Reswap", "outerHTML")
+		h.renderMain(w, r, user, list)
+		return
+	}
+	items, err := h.store.Items(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := h.Templates.ExecuteTemplate(w, "checklist.html", checklistData{ToDo: todo, Items: items}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ServeChecklist handles GET "/tasks/{id}/items" → the checklist snippet.
+func (h *Handler) ServeChecklist(w http.ResponseWriter, r *http.Request) {
+	todoID, _, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	todo, err := h.store.Get(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.renderChecklist(w, r, user, todoID, todo.Completed)
+}
+
+// AddChecklistItem handles POST "/tasks/{id}/items".
+func (h *Handler) AddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	todoID, _, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	todo, err := h.store.Get(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if _, err := storeFor(h.store, r).AddItem(todoID, user, r.PostFormValue("item")); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.renderChecklist(w, r, user, todoID, todo.Completed)
+}
+
+// UpdateChecklistItem handles PUT "/tasks/{id}/items/{item}"; the item is
+// checked when the form's done field is "true".
+func (h *Handler) UpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	todoID, itemID, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	todo, err := h.store.Get(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	done := r.PostFormValue("done") == "true"
+	if _, err := storeFor(h.store, r).SetItemDone(todoID, itemID, user, done); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.renderChecklist(w, r, user, todoID, todo.Completed)
+}
+
+// DeleteChecklistItem handles DELETE "/tasks/{id}/items/{item}".
+func (h *Handler) DeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	todoID, itemID, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	todo, err := h.store.Get(todoID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := h.store.DeleteItem(todoID, itemID, user); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.renderChecklist(w, r, user, todoID, todo.Completed)
+}
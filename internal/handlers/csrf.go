@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+)
+
+const (
+	// csrfSessionKey is where the per-session token lives in the cookie.
+	csrfSessionKey = "csrf"
+	// csrfHeader carries the token on htmx requests (see hx-headers in
+	// layout.html).
+	csrfHeader = "X-CSRF-Token"
+	// csrfField carries the token on plain HTML form posts.
+	csrfField = "csrf_token"
+)
+
+// newCSRFToken returns 32 random bytes, URL-safe encoded.
+func newCSRFToken() string {
+	return base64.RawURLEncoding.EncodeToString(randomKey())
+}
+
+// csrfToken returns the token CSRFProtect attached to r, for rendering into
+// pages and forms.
+func csrfToken(r *http.Request) string {
+	tok, _ := r.Context().Value(ctxCSRF).(string)
+	return tok
+}
+
+// safeMethod reports whether m is read-only and so exempt from CSRF checks.
+func safeMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// CSRFProtect is middleware that gives every session a CSRF token and
+// rejects state-changing requests that don't echo it back, either in the
+// X-CSRF-Token header (htmx) or the csrf_token form field (plain forms).
+//
+// Requests authenticated with a bearer token are exempt: they carry no
+// ambient cookie credentials for another site to ride on. It must therefore
+// run inside AuthHandler.Authenticate.
+func CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if viaToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sess, _ := sessionStore.Get(r, sessionName)
+		want, _ := sess.Values[csrfSessionKey].(string)
+		if want == "" {
+			want = newCSRFToken()
+			sess.Values[csrfSessionKey] = want
+			if err := sess.Save(r, w); err != nil {
+				logging.Errorf("saving CSRF token: %v", err)
+			}
+		}
+
+		if !safeMethod(r.Method) {
+			got := r.Header.Get(csrfHeader)
+			if got == "" {
+				got = r.PostFormValue(csrfField)
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				logging.Warnf("CSRF check failed: %s %s", r.Method, r.URL.Path)
+				csrfFailure(w, r)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), ctxCSRF, want)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// csrfFailure answers 403 in the caller's dialect.
+func csrfFailure(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeAPIError(w, http.StatusForbidden, "csrf_failed",
+			"missing or invalid "+csrfHeader+" header; use a bearer token for non-browser clients")
+		return
+	}
+	http.Error(w, "Forbidden: your session's security token is missing or out of date. Reload the page and try again.", http.StatusForbidden)
+}
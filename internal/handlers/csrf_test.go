@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
+)
+
+var (
+	hxHeadersRE = regexp.MustCompile(`"X-CSRF-Token": "([^"]+)"`)
+	formTokenRE = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+)
+
+// csrfTestServer wires the handlers the way cmd/todolist does and returns a
+// client with a cookie jar, signed up as alice but not signed in.
+func csrfTestServer(t *testing.T) (*httptest.Server, *http.Client, models.TokenStore) {
+	t.Helper()
+	users := models.NewUserStoreMemory()
+	users.Create("alice", "pw")
+	tokens := models.NewTokenStoreMemory()
+	authH, err := NewAuthHandler(users, tokens, templates.Embedded())
+	if err != nil {
+		t.Fatal(err)
+	}
+	todoH, err := NewHandlerWithStore(models.NewStoreMemory(), templates.Embedded())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			authH.LoginPage(w, r)
+		} else {
+			authH.Login(w, r)
+		}
+	})
+	mux.Handle("/", AuthRequired(http.HandlerFunc(todoH.ServeIndex)))
+	mux.Handle("/tasks", AuthRequired(http.HandlerFunc(todoH.CreateToDo)))
+	mux.Handle("/api/v1/", NewAPIHandler(models.NewStoreMemory(), tokens).Routes())
+
+	srv := httptest.NewServer(authH.Authenticate(CSRFProtect(mux)))
+	t.Cleanup(srv.Close)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return srv, client, tokens
+}
+
+// fetchToken GETs path and extracts the CSRF token with re.
+func fetchToken(t *testing.T, client *http.Client, url string, re *regexp.Regexp) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	m := re.FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("no CSRF token in %s:\n%s", url, body)
+	}
+	return string(m[1])
+}
+
+func postForm(t *testing.T, client *http.Client, url string, form url.Values, header map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// login signs alice in through the plain form flow and returns the fresh
+// token rendered into the index page.
+func login(t *testing.T, srv *httptest.Server, client *http.Client) string {
+	t.Helper()
+	tok := fetchToken(t, client, srv.URL+"/login", formTokenRE)
+	resp := postForm(t, client, srv.URL+"/login",
+		url.Values{"username": {"alice"}, "password": {"pw"}, "csrf_token": {tok}}, nil)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("login with token: expected 303, got %d", resp.StatusCode)
+	}
+	return fetchToken(t, client, srv.URL+"/", hxHeadersRE)
+}
+
+func TestCSRFPlainFormFlow(t *testing.T) {
+	srv, client, _ := csrfTestServer(t)
+	creds := url.Values{"username": {"alice"}, "password": {"pw"}}
+
+	if resp := postForm(t, client, srv.URL+"/login", creds, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login without token: expected 403, got %d", resp.StatusCode)
+	}
+	bad := url.Values{"username": {"alice"}, "password": {"pw"}, "csrf_token": {"forged"}}
+	if resp := postForm(t, client, srv.URL+"/login", bad, nil); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login with forged token: expected 403, got %d", resp.StatusCode)
+	}
+
+	pageTok := login(t, srv, client)
+	if resp := postForm(t, client, srv.URL+"/tasks",
+		url.Values{"title": {"via form"}, "csrf_token": {pageTok}}, nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("form post with token: expected 303, got %d", resp.StatusCode)
+	}
+}
+
+func TestCSRFHTMXFlow(t *testing.T) {
+	srv, client, _ := csrfTestServer(t)
+	tok := login(t, srv, client)
+	form := url.Values{"title": {"via htmx"}}
+
+	resp := postForm(t, client, srv.URL+"/tasks", form, map[string]string{"HX-Request": "true"})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("htmx post without header: expected 403, got %d", resp.StatusCode)
+	}
+	resp = postForm(t, client, srv.URL+"/tasks", form, map[string]string{"HX-Request": "true", csrfHeader: tok})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("htmx post with header: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestCSRFRotatesOnLogin(t *testing.T) {
+	srv, client, _ := csrfTestServer(t)
+	before := fetchToken(t, client, srv.URL+"/login", formTokenRE)
+	after := login(t, srv, client)
+	if before == after {
+		t.Fatal("expected a new CSRF token after signing in")
+	}
+}
+
+func TestCSRFSkipsBearerAndFailsAPIAsJSON(t *testing.T) {
+	srv, client, tokens := csrfTestServer(t)
+	login(t, srv, client)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/todos", strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("cookie API write without token: expected JSON 403, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	secret, _, _ := tokens.Create("alice", "ci", nil)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/api/v1/todos", strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+secret)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("bearer API write: expected 201, got %d", resp.StatusCode)
+	}
+}
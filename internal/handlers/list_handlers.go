@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// listPath is the page that shows list: "/" for the Inbox and
+// "/lists/{id}/tasks" for everything else.
+func listPath(list *models.List) string {
+	if list == nil || list.Inbox {
+		return "/"
+	}
+	return "/lists/" + strconv.Itoa(list.ID) + "/tasks"
+}
+
+// pathList loads the list named by the {id} path value, or the Inbox for
+// routes without one (e.g. POST "/tasks").
+func (h *Handler) pathList(r *http.Request, user string) (*models.List, error) {
+	raw := r.PathValue("id")
+	if raw == "" {
+		return h.store.Inbox(user)
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, models.ErrListNotFound
+	}
+	return h.store.GetList(id, user)
+}
+
+// redirectTo sends the browser to path: via HX-Redirect for htmx requests
+// (which would otherwise swap the page into the target) and a 303 otherwise.
+func redirectTo(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", path)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// ServeList handles GET "/lists/{id}/tasks" and renders that list as a full
+// page, just like ServeIndex does for the Inbox.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	list, err := h.pathList(r, h.currentUser(r))
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.servePage(w, r, list)
+}
+
+// CreateList handles POST "/lists" and opens the new list.
+func (h *Handler) CreateList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	list, err := h.store.CreateList(h.currentUser(r), r.PostFormValue("name"))
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	redirectTo(w, r, listPath(list))
+}
+
+// RenameList handles PUT "/lists/{id}".
+func (h *Handler) RenameList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if list, err = h.store.RenameList(list.ID, user, r.PostFormValue("name")); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	redirectTo(w, r, listPath(list))
+}
+
+// ArchiveList handles PUT "/lists/{id}/archive"; the form's archived field
+// is "true" to archive and "false" to bring the list back.
+func (h *Handler) ArchiveList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	archived, err := strconv.ParseBool(r.PostFormValue("archived"))
+	if err != nil {
+		http.Error(w, "archived must be true or false", http.StatusBadRequest)
+		return
+	}
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if list, err = h.store.ArchiveList(list.ID, user, archived); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	redirectTo(w, r, listPath(list))
+}
+
+// DeleteList handles DELETE "/lists/{id}", removing the list with all its
+// tasks, and goes back to the Inbox.
+func (h *Handler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := storeFor(h.store, r).DeleteList(list.ID, user); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	redirectTo(w, r, "/")
+}
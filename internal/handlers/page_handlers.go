@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// moreLink is what the "load_more" template renders: where the next page
+// of one section of a list comes from. An empty Cursor means there is none.
+type moreLink struct {
+	ListID int
+	Status models.Status
+	Cursor string
+}
+
+// moreData is what more_todos.html renders: the next page of active todos
+// grouped by deadline, or of completed ones, and the link after it.
+type moreData struct {
+	dueGroups
+	Completed []*models.ToDo
+	More      moreLink
+}
+
+// MoreToDos handles GET "/lists/{id}/tasks/more?status=…&cursor=…", which
+// the "Load more" button calls. It answers with the next page of the
+// active or completed section, in the sort order and tag filter of the
+// page it was called from.
+func (h *Handler) MoreToDos(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	status, err := models.ParseStatus(q.Get("status"))
+	if err != nil || status == models.StatusAll {
+		http.Error(w, "status must be active or completed", http.StatusBadRequest)
+		return
+	}
+	opts := listOptions(r)
+	opts.ListID = list.ID
+	opts.Status = status
+	page, err := h.store.GetPage(user, opts, indexPageSize, q.Get("cursor"))
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	data := moreData{More: moreLink{ListID: list.ID, Status: status, Cursor: page.Next}}
+	if status == models.StatusCompleted {
+		data.Completed = page.Todos
+	} else {
+		data.dueGroups = groupByDue(page.Todos, time.Now(), viewerLocation(r))
+	}
+	if err := h.Templates.ExecuteTemplate(w, "more_todos.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// searchData is what search_results.html renders.
+type searchData struct {
+	Query   string
+	Results []searchHit
+}
+
+// searchHit is one search result with the name of the list it is in.
+type searchHit struct {
+	*models.ToDo
+	ListName string
+}
+
+// search runs query over every one of user's lists. lists names the
+// results' lists; a failed search is logged and shows no results.
+func (h *Handler) search(user, query string, lists []*models.List) searchData {
+	data := searchData{Query: query}
+	todos, err := h.store.Search(user, query, models.ListOptions{})
+	if err != nil {
+		logging.Errorf("search failed for user=%q: %v", user, err)
+		return data
+	}
+	names := make(map[int]string, len(lists))
+	for _, l := range lists {
+		names[l.ID] = l.Name
+	}
+	for _, t := range todos {
+		data.Results = append(data.Results, searchHit{ToDo: t, ListName: names[t.ListID]})
+	}
+	return data
+}
+
+// Search handles GET "/search?q=…", which the search box calls as you
+// type, and returns the results snippet. Without htmx it sends the browser
+// to the index page, which shows the same results.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/?q="+url.QueryEscape(q), http.StatusSeeOther)
+		return
+	}
+	user := h.currentUser(r)
+	var data searchData
+	if q != "" {
+		lists, err := h.store.Lists(user)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		data = h.search(user, q, lists)
+	}
+	if err := h.Templates.ExecuteTemplate(w, "search_results.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
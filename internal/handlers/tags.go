@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// tagChip is one entry in the index page's tag filter bar.
+type tagChip struct {
+	Name     string
+	Count    int
+	Selected bool
+	// URL toggles this tag in the current filter.
+	URL string
+}
+
+// tagFilter is the index page's tag filter state.
+type tagFilter struct {
+	Chips    []tagChip
+	Selected []string
+	Match    models.TagMatch
+	// AnyURL and AllURL switch the match mode; ClearURL drops the filter.
+	AnyURL, AllURL, ClearURL string
+}
+
+// pageQuery returns the query string the page was loaded with. htmx
+// requests carry the page's URL in HX-Current-URL, so a create or toggle
+// re-renders the same filtered view the user is looking at.
+func pageQuery(r *http.Request) url.Values {
+	if r.Header.Get("HX-Request") == "true" {
+		if u, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil {
+			return u.Query()
+		}
+	}
+	return r.URL.Query()
+}
+
+// tagOptions reads ?tag=...&match=any|all from q, dropping tags that don't
+// normalize.
+func tagOptions(q url.Values) ([]string, models.TagMatch) {
+	var tags []string
+	for _, raw := range q["tag"] {
+		if tag, err := models.NormalizeTag(raw); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	tags, _ = models.NormalizeTags(tags)
+	match, err := models.ParseTagMatch(q.Get("match"))
+	if err != nil {
+		match = models.TagMatchAny
+	}
+	return tags, match
+}
+
+// filterURL links to the list page at base filtered by tags.
+func filterURL(base string, tags []string, match models.TagMatch) string {
+	if len(tags) == 0 {
+		return base
+	}
+	q := url.Values{"tag": tags}
+	if match == models.TagMatchAll {
+		q.Set("match", string(match))
+	}
+	return base + "?" + q.Encode()
+}
+
+// buildTagFilter combines the user's tags with the active filter in opts;
+// its links stay on the list page at base.
+func buildTagFilter(counts []models.TagCount, opts models.ListOptions, base string) tagFilter {
+	selected := make(map[string]bool, len(opts.Tags))
+	for _, tag := range opts.Tags {
+		selected[tag] = true
+	}
+	f := tagFilter{
+		Selected: opts.Tags,
+		Match:    opts.TagMatch,
+		AnyURL:   filterURL(base, opts.Tags, models.TagMatchAny),
+		AllURL:   filterURL(base, opts.Tags, models.TagMatchAll),
+		ClearURL: base,
+	}
+	for _, c := range counts {
+		var toggled []string
+		for _, tag := range opts.Tags {
+			if tag != c.Name {
+				toggled = append(toggled, tag)
+			}
+		}
+		if !selected[c.Name] {
+			toggled = append(toggled, c.Name)
+		}
+		f.Chips = append(f.Chips, tagChip{
+			Name:     c.Name,
+			Count:    c.Count,
+			Selected: selected[c.Name],
+			URL:      filterURL(base, toggled, opts.TagMatch),
+		})
+	}
+	return f
+}
+
+// setTags makes todo's tags equal want, tagging and untagging only the
+// difference. It reports whether anything changed.
+func setTags(store models.ToDoStore, todo *models.ToDo, username string, want []string) (bool, error) {
+	want, err := models.NormalizeTags(want)
+	if err != nil {
+		return false, err
+	}
+	keep := make(map[string]bool, len(want))
+	for _, tag := range want {
+		keep[tag] = true
+	}
+	var add, remove []string
+	for _, tag := range want {
+		if !todo.HasTag(tag) {
+			add = append(add, tag)
+		}
+	}
+	for _, tag := range todo.Tags {
+		if !keep[tag] {
+			remove = append(remove, tag)
+		}
+	}
+	if len(add) > 0 {
+		if err := store.Tag(todo.ID, username, add...); err != nil {
+			return false, err
+		}
+	}
+	if len(remove) > 0 {
+		if err := store.Untag(todo.ID, username, remove...); err != nil {
+			return false, err
+		}
+	}
+	return len(add)+len(remove) > 0, nil
+}
@@ -1,101 +1,372 @@
 package handlers
 
 import (
+	"errors"
 	"html/template"
-	"log"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/markdown"
 	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
 )
 
-// pageData holds everything layout.html needs: the current user + both lists.
+// pageData holds everything layout.html needs: the current user, the CSRF
+// token for hx-headers, the list being shown + the todo slices.
 type pageData struct {
 	Username  string
+	CSRFToken string
+	List      *models.List   // the list being shown
+	Lists     []*models.List // every list, for the switcher
+	Sort      models.SortOrder
+	Search    searchData
+	Filter    tagFilter
+	NewTags   string // pre-fills the add form with the filtered tags
 	Active    []*models.ToDo
+	Overdue   []*models.ToDo
+	Today     []*models.ToDo
+	Upcoming  []*models.ToDo
+	NoDate    []*models.ToDo
 	Completed []*models.ToDo
+	// ActiveMore and CompletedMore load the next pages of each section.
+	ActiveMore    moreLink
+	CompletedMore moreLink
 }
 
-// viewData holds just the two To-Do slices.
+// viewData holds the To-Do slices: the first page of active todos, the same
+// todos grouped by deadline, and the first page of completed ones, with the
+// cursors for the next pages; plus the user's tags.
 type viewData struct {
-	Active    []*models.ToDo
-	Completed []*models.ToDo
+	Tags []models.TagCount
+	dueGroups
+	Active        []*models.ToDo
+	ActiveNext    string
+	Completed     []*models.ToDo
+	CompletedNext string
+}
+
+// dueGroups splits active todos by deadline.
+type dueGroups struct {
+	Overdue  []*models.ToDo
+	Today    []*models.ToDo
+	Upcoming []*models.ToDo
+	NoDate   []*models.ToDo
+}
+
+// groupByDue sorts active todos into overdue, today, upcoming and no date
+// as seen from loc at now, keeping their order within each group.
+func groupByDue(todos []*models.ToDo, now time.Time, loc *time.Location) dueGroups {
+	var g dueGroups
+	for _, t := range todos {
+		switch t.Bucket(now, loc) {
+		case models.DueOverdue:
+			g.Overdue = append(g.Overdue, t)
+		case models.DueToday:
+			g.Today = append(g.Today, t)
+		case models.DueUpcoming:
+			g.Upcoming = append(g.Upcoming, t)
+		default:
+			g.NoDate = append(g.NoDate, t)
+		}
+	}
+	return g
+}
+
+// indexPageSize is how many active (and completed) todos the index page
+// shows before "Load more".
+const indexPageSize = 50
+
+// editData is what edit_form.html renders: the todo plus the lists it can
+// be moved to.
+type editData struct {
+	*models.ToDo
+	Lists []*models.List
+	// Conflict is the todo as saved by someone else, when ToDo is an edit
+	// that lost to it (see editConflict).
+	Conflict *models.ToDo
+}
+
+// detailData is what pages/todo_detail.html renders.
+type detailData struct {
+	Username  string
+	CSRFToken string
+	ToDo      *models.ToDo
+	List      *models.List
+	Notes     template.HTML // ToDo.Notes rendered by package markdown
+	History   []*models.Event
+}
+
+// todoPath is the todo's detail page.
+func todoPath(t *models.ToDo) string {
+	return "/tasks/" + strconv.Itoa(t.ID)
+}
+
+// tzCookie is set by static/js/app.js to the browser's IANA time zone.
+const tzCookie = "tz"
+
+// viewerLocation returns the time zone the viewer's browser reported, or UTC
+// if it didn't send one (or sent something we don't know). app.js encodes
+// the cookie, so "America/New_York" arrives as "America%2FNew_York".
+func viewerLocation(r *http.Request) *time.Location {
+	if c, err := r.Cookie(tzCookie); err == nil && c.Value != "" {
+		name, err := url.PathUnescape(c.Value)
+		if err != nil {
+			return time.UTC
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// sortCookie remembers the sort order picked on the index page, so htmx
+// re-renders after a create or toggle keep it.
+const sortCookie = "sort"
+
+// listOptions reads the tag filter and sort order from the page's query
+// string, falling back to the sort cookie and then the store default.
+func listOptions(r *http.Request) models.ListOptions {
+	q := pageQuery(r)
+	raw := q.Get("sort")
+	if raw == "" {
+		if c, err := r.Cookie(sortCookie); err == nil {
+			raw = c.Value
+		}
+	}
+	sort, err := models.ParseSort(raw)
+	if err != nil {
+		sort, _ = models.ParseSort("")
+	}
+	tags, match := tagOptions(q)
+	return models.ListOptions{Sort: sort, Tags: tags, TagMatch: match}
+}
+
+// dueZone picks the zone a submitted due date is in: the form's due_tz
+// field, falling back to the viewer's location.
+func dueZone(r *http.Request) string {
+	if tz := r.PostFormValue("due_tz"); tz != "" {
+		return tz
+	}
+	return viewerLocation(r).String()
+}
+
+// recurrenceFromForm builds a recurrence rule from the edit form's repeat
+// fields: repeat (none, daily, weekly, monthly or yearly), repeat_every,
+// repeat_day (weekday codes, for weekly), repeat_monthday (for monthly)
+// and repeat_from ("completion" to count from when the task was done).
+// It returns "" for no repetition; SetRecurrence validates the rest.
+func recurrenceFromForm(form url.Values) string {
+	freq := strings.ToUpper(form.Get("repeat"))
+	if freq == "" || freq == "NONE" {
+		return ""
+	}
+	parts := []string{"FREQ=" + freq}
+	if every := strings.TrimSpace(form.Get("repeat_every")); every != "" {
+		parts = append(parts, "INTERVAL="+every)
+	}
+	if days := form["repeat_day"]; len(days) > 0 && freq == "WEEKLY" {
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if day := strings.TrimSpace(form.Get("repeat_monthday")); day != "" && freq == "MONTHLY" {
+		parts = append(parts, "BYMONTHDAY="+day)
+	}
+	if form.Get("repeat_from") == "completion" {
+		parts = append(parts, "FROM=COMPLETION")
+	}
+	return strings.Join(parts, ";")
 }
 
 // Handler bundles your ToDoStore and parsed templates.
 type Handler struct {
 	store     models.ToDoStore
-	Templates *template.Template
+	Templates *templates.Set
+	Detail    *templates.Set // the layout + a single todo's page
+	Trash     *templates.Set // the layout + the trash page
+	// TrashRetention is how long deleted todos are kept, as shown on the
+	// trash page; 0 means until deleted by hand.
+	TrashRetention time.Duration
+	// Events, when set, is the audit log shown as each todo's history.
+	Events models.EventStore
+	undo   *undoRegistry
 }
 
-// NewHandlerWithStore parses your layout + all partials and returns a Handler
-// wired to any models.ToDoStore implementation.
-func NewHandlerWithStore(store models.ToDoStore) (*Handler, error) {
-	// 1) Parse layout.html + index.html
-	tmpl, err := template.ParseGlob(filepath.Join("internal", "templates", "*.html"))
+// NewHandlerWithStore parses your layout + all partials from src and returns
+// a Handler wired to any models.ToDoStore implementation.
+func NewHandlerWithStore(store models.ToDoStore, src templates.Source) (*Handler, error) {
+	// layout.html + index.html, then all partials: todo_item.html,
+	// todo_list.html, edit_form.html, etc.
+	tmpl, err := src.Parse("*.html", "partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+	// the detail page defines its own "main", so it needs a set of its own
+	detail, err := src.Parse("layout.html", "pages/todo_detail.html", "partials/*.html")
 	if err != nil {
 		return nil, err
 	}
-	// 2) Parse all partials: todo_item.html, todo_list.html, edit_form.html, etc.
-	tmpl, err = tmpl.ParseGlob(filepath.Join("internal", "templates", "partials", "*.html"))
+	trash, err := src.Parse("layout.html", "pages/trash.html")
 	if err != nil {
 		return nil, err
 	}
-	return &Handler{store: store, Templates: tmpl}, nil
+	return &Handler{store: store, Templates: tmpl, Detail: detail, Trash: trash, undo: newUndoRegistry()}, nil
 }
 
-// currentUser pulls the signed-in username out of the session cookie.
+// storeError answers 404 when err is models.ErrNotFound and 500 otherwise,
+// logging the underlying cause so database failures don't masquerade as
+// missing todos.
+func storeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrListNotFound):
+		http.Error(w, "list not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrItemNotFound):
+		http.Error(w, "checklist item not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrListExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrInvalidTag), errors.Is(err, models.ErrInvalidList),
+		errors.Is(err, models.ErrInvalidItem), errors.Is(err, models.ErrInvalidRecurrence),
+		errors.Is(err, models.ErrInvalidNotes), errors.Is(err, models.ErrInvalidMove),
+		errors.Is(err, models.ErrInvalidBulk):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
+
+// currentUser returns the signed-in username (session or API token).
 func (h *Handler) currentUser(r *http.Request) string {
-	sess, _ := sessionStore.Get(r, sessionName)
-	user, _ := sess.Values["user"].(string)
-	return user
+	return requestUser(r)
 }
 
-// buildViewData fetches all todos for this user and splits into active/completed.
-func (h *Handler) buildViewData(username string) viewData {
-    // 1) fetch the rows and catch any error
-    todos, err := h.store.GetAll(username)
-    if err != nil {
-        log.Printf("[ERROR] buildViewData failed for user=%q: %v", username, err)
-        // we still return an empty viewData so the handler doesn't panic
-        return viewData{}
-    }
-
-    // 2) split into active vs completed
-    var active, completed []*models.ToDo
-    for _, t := range todos {
-        if t.Completed {
-            completed = append(completed, t)
-        } else {
-            active = append(active, t)
-        }
-    }
-
-    // 3) debug‐log exactly what you’ll render
-    log.Printf(
-        "[DEBUG] buildViewData → Active=%d Completed=%d for user=%q\n",
-        len(active), len(completed), username,
-    )
-
-    return viewData{Active: active, Completed: completed}
+// buildViewData fetches the first page of active and of completed todos for
+// this user in the order opts asks for, and groups the active ones by
+// deadline as seen from loc.
+func (h *Handler) buildViewData(username string, loc *time.Location, opts models.ListOptions) viewData {
+	// 1) fetch the first page of each section and catch any error
+	opts.Status = models.StatusActive
+	active, err := h.store.GetPage(username, opts, indexPageSize, "")
+	if err != nil {
+		logging.Errorf("buildViewData failed for user=%q: %v", username, err)
+		// we still return an empty viewData so the handler doesn't panic
+		return viewData{}
+	}
+	opts.Status = models.StatusCompleted
+	completed, err := h.store.GetPage(username, opts, indexPageSize, "")
+	if err != nil {
+		logging.Errorf("buildViewData failed for user=%q: %v", username, err)
+		return viewData{}
+	}
+
+	// 2) group the active ones: overdue, today, upcoming, no date, keeping
+	// the store's order within each group
+	vd := viewData{
+		dueGroups:     groupByDue(active.Todos, time.Now(), loc),
+		Active:        active.Todos,
+		ActiveNext:    active.Next,
+		Completed:     completed.Todos,
+		CompletedNext: completed.Next,
+	}
+	if vd.Tags, err = h.store.Tags(username); err != nil {
+		logging.Errorf("buildViewData: listing tags for user=%q: %v", username, err)
+	}
+
+	// 3) debug‐log exactly what you’ll render
+	logging.Debugf("buildViewData → Overdue=%d Today=%d Upcoming=%d NoDate=%d Completed=%d for user=%q",
+		len(vd.Overdue), len(vd.Today), len(vd.Upcoming), len(vd.NoDate), len(vd.Completed), username,
+	)
+
+	return vd
 }
 
-// ServeIndex handles GET "/" and renders the full page (using layout.html).
-func (h *Handler) ServeIndex(w http.ResponseWriter, r *http.Request) {
-	user := h.currentUser(r)
-	vd := h.buildViewData(user)
-	data := pageData{
+// newPageData combines the view data for list with the per-request fields
+// layout.html needs.
+func (h *Handler) newPageData(r *http.Request, user string, list *models.List, vd viewData) pageData {
+	opts := listOptions(r)
+	lists, err := h.store.Lists(user)
+	if err != nil {
+		logging.Errorf("newPageData: listing lists for user=%q: %v", user, err)
+	}
+	return pageData{
 		Username:  user,
+		CSRFToken: csrfToken(r),
+		List:      list,
+		Lists:     lists,
+		Sort:      opts.Sort,
+		Filter:    buildTagFilter(vd.Tags, opts, listPath(list)),
+		NewTags:   models.StringList(opts.Tags).String(),
 		Active:    vd.Active,
+		Overdue:   vd.Overdue,
+		Today:     vd.Today,
+		Upcoming:  vd.Upcoming,
+		NoDate:    vd.NoDate,
 		Completed: vd.Completed,
+		ActiveMore: moreLink{
+			ListID: list.ID, Status: models.StatusActive, Cursor: vd.ActiveNext,
+		},
+		CompletedMore: moreLink{
+			ListID: list.ID, Status: models.StatusCompleted, Cursor: vd.CompletedNext,
+		},
+	}
+}
+
+// ServeIndex handles GET "/" and renders the Inbox as a full page (using
+// layout.html).
+func (h *Handler) ServeIndex(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.Inbox(h.currentUser(r))
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.servePage(w, r, list)
+}
+
+// servePage renders list as a full page, remembering a ?sort= choice.
+func (h *Handler) servePage(w http.ResponseWriter, r *http.Request, list *models.List) {
+	if raw := r.URL.Query().Get("sort"); raw != "" {
+		if _, err := models.ParseSort(raw); err == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name: sortCookie, Value: raw, Path: "/",
+				MaxAge: 365 * 24 * 60 * 60, HttpOnly: true, SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	user := h.currentUser(r)
+	opts := listOptions(r)
+	opts.ListID = list.ID
+	vd := h.buildViewData(user, viewerLocation(r), opts)
+	data := h.newPageData(r, user, list, vd)
+	if q := r.URL.Query().Get("q"); q != "" {
+		data.Search = h.search(user, q, data.Lists)
 	}
 	if err := h.Templates.ExecuteTemplate(w, "layout.html", data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-// CreateToDo handles POST "/tasks". On HTMX it re-renders only the "main" block.
+// renderMain re-renders the "main" block for list, as htmx requests expect.
+func (h *Handler) renderMain(w http.ResponseWriter, r *http.Request, user string, list *models.List) {
+	opts := listOptions(r)
+	opts.ListID = list.ID
+	vd := h.buildViewData(user, viewerLocation(r), opts)
+	data := h.newPageData(r, user, list, vd)
+	if err := h.Templates.ExecuteTemplate(w, "main", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateToDo handles POST "/tasks" (the Inbox) and POST "/lists/{id}/tasks".
+// On HTMX it re-renders only the "main" block.
 func (h *Handler) CreateToDo(w http.ResponseWriter, r *http.Request) {
 	// 1) Parse + validate
 	if err := r.ParseForm(); err != nil {
@@ -108,41 +379,62 @@ func (h *Handler) CreateToDo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-  // 2) Create under the current user, capturing the new record
-    user := h.currentUser(r)
-    newTodo, err := h.store.Create(user, title)
-    if err != nil {
-        http.Error(w, "could not create todo", http.StatusInternalServerError)
-        return
-    }
-
-    // —— DEBUG LOGGING ——
-    log.Printf("[DEBUG] CreateToDo: created %+v for user=%q\n", newTodo, user)
-
-    // 3) If HTMX, re-render the <div id="todoApp">…</div> by firing the "main" template
-    if r.Header.Get("HX-Request") == "true" {
-        vd := h.buildViewData(user)
-        log.Printf("[DEBUG] buildViewData → Active=%d, Completed=%d", len(vd.Active), len(vd.Completed))
-        for i, t := range vd.Active {
-            log.Printf("[DEBUG]   active[%d]: %+v", i, t)
-        }
-
-        data := pageData{
-            Username:  user,
-            Active:    vd.Active,
-            Completed: vd.Completed,
-        }
-        if err := h.Templates.ExecuteTemplate(w, "main", data); err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-        }
-        return
-    }
-
-    // 4) Fallback: full redirect
-    http.Redirect(w, r, "/", http.StatusSeeOther)
+	priority, err := models.ParsePriority(r.PostFormValue("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in := models.ToDoInput{Title: title, Priority: priority}
+	if err := in.SetDue(r.PostFormValue("due_date"), r.PostFormValue("due_time"), dueZone(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.ParseTags(r.PostFormValue("tags"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 2) Create under the current user in the list from the path, capturing
+	// the new record
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	in.ListID = list.ID
+	store := storeFor(h.store, r)
+	newTodo, err := store.Create(user, in)
+	if err != nil {
+		logging.Errorf("CreateToDo failed for user=%q: %v", user, err)
+		http.Error(w, "could not create todo", http.StatusInternalServerError)
+		return
+	}
+	if len(tags) > 0 {
+		if err := store.Tag(newTodo.ID, user, tags...); err != nil {
+			storeError(w, r, err)
+			return
+		}
+	}
+
+	// —— DEBUG LOGGING ——
+	logging.Debugf("CreateToDo: created %+v for user=%q", newTodo, user)
+
+	// 3) If HTMX, re-render the <div id="todoApp">…</div> by firing the "main" template
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderMain(w, r, user, list)
+		return
+	}
+
+	// 4) Fallback: full redirect
+	http.Redirect(w, r, listPath(list), http.StatusSeeOther)
 }
-// DeleteToDo handles DELETE "/tasks/{id}" and simply returns 200 OK on HTMX so
-// htmx will remove the <li> for you.
+
+// DeleteToDo handles DELETE "/tasks/{id}", moving the todo to the trash, and
+// simply returns 200 OK on HTMX so htmx will remove the <li> for you. The
+// only content is an out-of-band toast offering to undo it.
 func (h *Handler) DeleteToDo(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Path[len("/tasks/"):])
 	if err != nil {
@@ -150,11 +442,42 @@ func (h *Handler) DeleteToDo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	user := h.currentUser(r)
-	if err := h.store.Delete(id, user); err != nil {
-		http.Error(w, "todo not found", http.StatusNotFound)
+	if err := storeFor(h.store, r).Delete(id, user); err != nil {
+		storeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
+	if r.Header.Get("HX-Request") == "true" {
+		h.offerUndo(w, user, "Task deleted.", undoDelete(user, id))
+	}
+}
+
+// MoveToDo handles POST "/tasks/{id}/move", sent when a todo is dropped in
+// a list shown in manual order. The form's after and before fields name
+// the todos it now sits between (empty at either end). The page already
+// shows the new order, so a success has no body.
+func (h *Handler) MoveToDo(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var ids [3]int
+	for i, raw := range []string{r.PathValue("id"), r.PostFormValue("before"), r.PostFormValue("after")} {
+		if raw == "" && i > 0 {
+			continue
+		}
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ids[i] = id
+	}
+	if _, err := storeFor(h.store, r).Move(ids[0], h.currentUser(r), ids[1], ids[2]); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // UpdateToDo handles PUT "/tasks/{id}" – distinguishes inline edit vs checkbox toggle.
@@ -170,51 +493,150 @@ func (h *Handler) UpdateToDo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	user := h.currentUser(r)
+	store := storeFor(h.store, r)
 
 	// 2) Load the old todo (for title fallback)
 	old, err := h.store.Get(id, user)
 	if err != nil {
-		http.Error(w, "todo not found", http.StatusNotFound)
+		storeError(w, r, err)
 		return
 	}
 
-	// 3) Determine new values
-	title := r.PostFormValue("title")
-	if title == "" {
-		title = old.Title
+	// 3) Determine new values; priority, deadline and tags only change
+	// when the edit form sent their fields (the checkbox toggle doesn't)
+	in := old.Input()
+	if title := r.PostFormValue("title"); title != "" {
+		in.Title = title
+	}
+	in.Completed = r.PostFormValue("completed") == "on"
+	// The edit form sends the version it was filled from, so saving over
+	// a change made elsewhere in the meantime is caught; the checkbox
+	// toggle is held to the version just read.
+	in.Version = old.Version
+	if raw := r.PostFormValue("version"); raw != "" {
+		if in.Version, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+	if raw := r.PostFormValue("list_id"); raw != "" {
+		if in.ListID, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "invalid list", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := r.PostForm["priority"]; ok {
+		if in.Priority, err = models.ParsePriority(r.PostFormValue("priority")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	_, dueEdited := r.PostForm["due_date"]
+	if dueEdited {
+		if err := in.SetDue(r.PostFormValue("due_date"), r.PostFormValue("due_time"), dueZone(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := r.PostForm["repeat"]; ok {
+		if err := in.SetRecurrence(recurrenceFromForm(r.PostForm)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := r.PostForm["notes"]; ok {
+		if in.Notes, err = models.NormalizeNotes(r.PostFormValue("notes")); err != nil {
+			storeError(w, r, err)
+			return
+		}
+	}
+	var tags []string
+	_, tagsEdited := r.PostForm["tags"]
+	if tagsEdited {
+		if tags, err = models.ParseTags(r.PostFormValue("tags")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Completing a todo checks its whole checklist; remember which items
+	// were still open in case it is undone.
+	completing := in.Completed && !old.Completed
+	var open []*models.ChecklistItem
+	if completing && old.ItemsDone < old.ItemsTotal {
+		items, err := h.store.Items(id, user)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		for _, it := range items {
+			if !it.Done {
+				open = append(open, it)
+			}
+		}
 	}
-	completed := r.PostFormValue("completed") == "on"
 
-	updated, err := h.store.Update(id, title, completed, user)
+	updated, err := store.Update(id, in, user)
+	if errors.Is(err, models.ErrConflict) && r.PostFormValue("title") == "" {
+		h.toggleConflict(w, r, old)
+		return
+	}
+	if errors.Is(err, models.ErrConflict) {
+		h.editConflict(w, r, id, in, tags)
+		return
+	}
 	if err != nil {
-		http.Error(w, "todo not found", http.StatusNotFound)
+		storeError(w, r, err)
+		return
+	}
+	// completing a recurring todo spawned its next occurrence
+	spawned := updated.Spawned
+	// tags are saved once the update is known not to conflict
+	retagged := false
+	if tagsEdited {
+		if retagged, err = setTags(store, updated, user, tags); err == nil && retagged {
+			updated, err = store.Get(id, user)
+		}
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+	}
+	// the page still shows the list the todo was in
+	list, err := h.store.GetList(old.ListID, user)
+	if err != nil {
+		storeError(w, r, err)
 		return
 	}
 
 	// 4) HTMX inline-edit vs toggle:
 	if r.Header.Get("HX-Request") == "true" {
-		// a) inline save → return a single <li> snippet
-		if r.PostFormValue("title") != "" {
-			if err := h.Templates.ExecuteTemplate(w, "partials/todo_item.html", updated); err != nil {
+		// a) inline save → return a single <li> snippet, unless a new
+		// deadline, priority, tag or list may have moved it, or completing
+		// it spawned the next occurrence
+		moved := updated.Priority != old.Priority || updated.ListID != old.ListID || dueEdited || retagged ||
+			spawned != nil
+		if r.PostFormValue("title") != "" && !moved {
+			if err := h.Templates.ExecuteTemplate(w, "todo_item.html", updated); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
 			return
 		}
-		// b) checkbox toggle → re-render entire todoApp
-		vd := h.buildViewData(user)
-		data := pageData{
-			Username:  user,
-			Active:    vd.Active,
-			Completed: vd.Completed,
+		// b) checkbox toggle or moved item → re-render entire todoApp
+		if moved {
+			w.Header().Set("HX-Retarget", "#todoApp")
+			w.Header().Set("HX-Reswap", "outerHTML")
 		}
-		if err := h.Templates.ExecuteTemplate(w, "main", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.renderMain(w, r, user, list)
+		// ticking the checkbox gets a toast offering to undo it
+		if completing && r.PostFormValue("title") == "" {
+			h.offerUndo(w, user, "Task completed.", undoComplete(user, old, updated.Version, open, spawned))
 		}
 		return
 	}
 
 	// 5) non-HTMX fallback
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, listPath(list), http.StatusSeeOther)
 }
 
 // EditFormToDo handles GET "/tasks/{id}/edit" → returns the inline edit <li> form.
@@ -229,14 +651,73 @@ func (h *Handler) EditFormToDo(w http.ResponseWriter, r *http.Request) {
 	user := h.currentUser(r)
 	todo, err := h.store.Get(id, user)
 	if err != nil {
-		http.Error(w, "todo not found", http.StatusNotFound)
+		storeError(w, r, err)
+		return
+	}
+	lists, err := h.store.Lists(user)
+	if err != nil {
+		storeError(w, r, err)
 		return
 	}
-	h.Templates.ExecuteTemplate(w, "partials/edit_form.html", todo)
+	h.Templates.ExecuteTemplate(w, "edit_form.html", editData{ToDo: todo, Lists: lists})
 }
 
-// GetToDo handles GET "/tasks/{id}" → returns a single <li> snippet.
-func (h *Handler) GetToDo(w http.ResponseWriter, r *http.Request) {
+// editConflict answers an edit that lost to a change made elsewhere with
+// 409 and, for htmx, the edit form again: filled with the user's edit
+// (in, and tags if the form had them) on top of the current version, so saving
+// re-applies it, under a note showing the todo as it is now.
+func (h *Handler) editConflict(w http.ResponseWriter, r *http.Request, id int, in models.ToDoInput, tags []string) {
+	user := h.currentUser(r)
+	current, err := h.store.Get(id, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if r.Header.Get("HX-Request") != "true" {
+		http.Error(w, "the task was changed elsewhere; reload it and edit again", http.StatusConflict)
+		return
+	}
+	lists, err := h.store.Lists(user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	mine := *current
+	mine.ListID, mine.Title, mine.Completed, mine.Priority = in.ListID, in.Title, in.Completed, in.Priority
+	mine.DueAt, mine.DueHasTime, mine.DueTZ = in.DueAt, in.DueHasTime, in.DueTZ
+	mine.Recurrence, mine.Notes = in.Recurrence, in.Notes
+	if _, ok := r.PostForm["tags"]; ok {
+		mine.Tags = tags
+	}
+	w.WriteHeader(http.StatusConflict)
+	if err := h.Templates.ExecuteTemplate(w, "edit_form.html", editData{ToDo: &mine, Lists: lists, Conflict: current}); err != nil {
+		logging.Errorf("rendering edit conflict: %v", err)
+	}
+}
+
+// toggleConflict answers a checkbox toggle that lost to a change made
+// between reading and saving old with 409 and, for htmx, the page as it is
+// now, so the checkbox shows the current state and can be ticked again.
+func (h *Handler) toggleConflict(w http.ResponseWriter, r *http.Request, old *models.ToDo) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Error(w, "the task was changed elsewhere; reload it and try again", http.StatusConflict)
+		return
+	}
+	user := h.currentUser(r)
+	list, err := h.store.GetList(old.ListID, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.Header().Set("HX-Retarget", "#todoApp")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.WriteHeader(http.StatusConflict)
+	h.renderMain(w, r, user, list)
+}
+
+// ServeToDo handles GET "/tasks/{id}" → the todo's detail page, with its
+// notes rendered as Markdown.
+func (h *Handler) ServeToDo(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Path[len("/tasks/"):])
 	if err != nil {
 		http.Error(w, "invalid id", http.StatusBadRequest)
@@ -245,22 +726,73 @@ func (h *Handler) GetToDo(w http.ResponseWriter, r *http.Request) {
 	user := h.currentUser(r)
 	todo, err := h.store.Get(id, user)
 	if err != nil {
-		http.Error(w, "todo not found", http.StatusNotFound)
+		storeError(w, r, err)
+		return
+	}
+	list, err := h.store.GetList(todo.ListID, user)
+	if err != nil {
+		storeError(w, r, err)
 		return
 	}
-	h.Templates.ExecuteTemplate(w, "partials/todo_item.html", todo)
+	data := detailData{
+		Username:  user,
+		CSRFToken: csrfToken(r),
+		ToDo:      todo,
+		List:      list,
+		Notes:     markdown.Render(todo.Notes),
+	}
+	if data.History, err = h.history(id, user); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := h.Detail.ExecuteTemplate(w, "layout.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
-// ClearCompleted handles DELETE "/tasks/completed" → re-renders the main block.
-func (h *Handler) ClearCompleted(w http.ResponseWriter, r *http.Request) {
+// GetToDoItem handles GET "/tasks/{id}/item" → returns a single <li>
+// snippet, e.g. when an inline edit is cancelled.
+func (h *Handler) GetToDoItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
 	user := h.currentUser(r)
-	h.store.ClearCompleted(user)
+	todo, err := h.store.Get(id, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.Templates.ExecuteTemplate(w, "todo_item.html", todo)
+}
 
-	vd := h.buildViewData(user)
-	data := pageData{
-		Username:  user,
-		Active:    vd.Active,
-		Completed: vd.Completed,
+// ClearCompleted handles DELETE "/tasks/completed" (the Inbox) and DELETE
+// "/lists/{id}/tasks/completed" → moves them to the trash and re-renders the
+// main block, with a toast offering to undo it.
+func (h *Handler) ClearCompleted(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	list, err := h.pathList(r, user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	// note which todos go, so the toast can bring back just those
+	done, err := h.store.GetAll(user, models.ListOptions{ListID: list.ID, Status: models.StatusCompleted})
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := storeFor(h.store, r).ClearCompleted(user, list.ID); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.renderMain(w, r, user, list)
+	if len(done) > 0 {
+		ids := make([]int, len(done))
+		for i, t := range done {
+			ids[i] = t.ID
+		}
+		h.offerUndo(w, user, "Completed tasks deleted.", undoDelete(user, ids...))
 	}
-	h.Templates.ExecuteTemplate(w, "main", data)
 }
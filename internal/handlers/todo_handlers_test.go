@@ -0,0 +1,949 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
+)
+
+func newTestToDoHandler(t *testing.T, store models.ToDoStore) *Handler {
+	t.Helper()
+	h, err := NewHandlerWithStore(store, templates.Embedded())
+	if err != nil {
+		t.Fatalf("NewHandlerWithStore: %v", err)
+	}
+	return h
+}
+
+// indexAs renders GET target for username and returns the body.
+func indexAs(t *testing.T, h *Handler, username, target string, cookies ...*http.Cookie) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.AddCookie(sessionCookie(t, username))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeIndex(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d: %s", target, rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func TestIndexGroupsByDueDate(t *testing.T) {
+	store := models.NewStoreMemory()
+	now := time.Now().UTC()
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+	store.Create("alice", models.ToDoInput{Title: "renew passport", DueAt: &yesterday, DueHasTime: true, DueTZ: "UTC"})
+	store.Create("alice", models.ToDoInput{Title: "book dentist", DueAt: &tomorrow, DueHasTime: true, DueTZ: "UTC"})
+	store.Create("alice", models.ToDoInput{Title: "learn Go"})
+
+	body := indexAs(t, newTestToDoHandler(t, store), "alice", "/")
+	overdue := strings.Index(body, `id="overdueList"`)
+	upcoming := strings.Index(body, `id="upcomingList"`)
+	noDate := strings.Index(body, `id="noDateList"`)
+	if overdue < 0 || upcoming < 0 || noDate < 0 {
+		t.Fatalf("missing a due-date group in:\n%s", body)
+	}
+	if !(overdue < strings.Index(body, "renew passport") && strings.Index(body, "renew passport") < upcoming &&
+		upcoming < strings.Index(body, "book dentist") && strings.Index(body, "book dentist") < noDate) {
+		t.Fatalf("todos rendered in the wrong groups:\n%s", body)
+	}
+	// Empty groups are rendered (for "Load more" to append to) but empty,
+	// so CSS hides them.
+	today := body[strings.Index(body, `id="todayList"`):]
+	if strings.Contains(today[:strings.Index(today, "</ul>")], "<li") {
+		t.Fatal("put a todo in the Today group")
+	}
+}
+
+func TestIndexViewerTimeZone(t *testing.T) {
+	// The browser sends its zone the way app.js encodes it.
+	tz := func(name string) *http.Cookie {
+		return &http.Cookie{Name: tzCookie, Value: url.QueryEscape(name)}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(tz("America/New_York"))
+	if loc := viewerLocation(req); loc.String() != "America/New_York" {
+		t.Fatalf("viewerLocation = %v, want America/New_York", loc)
+	}
+
+	// Pick a zone whose date differs from UTC's right now, and a todo due
+	// today there: only a viewer in that zone sees it as due today.
+	name := "Etc/GMT+12"
+	if time.Now().UTC().Hour() >= 12 {
+		name = "Pacific/Kiritimati"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no zone data: %v", err)
+	}
+	y, m, d := time.Now().In(loc).Date()
+	due := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	store := models.NewStoreMemory()
+	store.Create("alice", models.ToDoInput{Title: "water plants", DueAt: &due, DueTZ: name})
+	h := newTestToDoHandler(t, store)
+
+	inToday := func(body string) bool {
+		today := body[strings.Index(body, `id="todayList"`):]
+		return strings.Contains(today[:strings.Index(today, "</ul>")], "water plants")
+	}
+	if body := indexAs(t, h, "alice", "/", tz(name)); !inToday(body) {
+		t.Errorf("viewer in %s doesn't see the todo as due today:\n%s", name, body)
+	}
+	if body := indexAs(t, h, "alice", "/"); inToday(body) {
+		t.Errorf("viewer in UTC sees the todo as due today:\n%s", body)
+	}
+}
+
+func TestIndexSortOrder(t *testing.T) {
+	store := models.NewStoreMemory()
+	store.Create("alice", models.ToDoInput{Title: "older, low", Priority: models.PriorityLow})
+	store.Create("alice", models.ToDoInput{Title: "newer, urgent", Priority: models.PriorityUrgent})
+	h := newTestToDoHandler(t, store)
+
+	body := indexAs(t, h, "alice", "/")
+	if strings.Index(body, "older, low") > strings.Index(body, "newer, urgent") {
+		t.Fatal("default (manual) order should keep the older todo first")
+	}
+	if !strings.Contains(body, `class="mb-4 sortable"`) {
+		t.Fatal("manual order should make the list sortable")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?sort=priority", nil)
+	req.AddCookie(sessionCookie(t, "alice"))
+	rec := httptest.NewRecorder()
+	h.ServeIndex(rec, req)
+	body = rec.Body.String()
+	if strings.Index(body, "newer, urgent") > strings.Index(body, "older, low") {
+		t.Fatal("?sort=priority should put the urgent todo first")
+	}
+	var remembered *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sortCookie {
+			remembered = c
+		}
+	}
+	if remembered == nil || remembered.Value != "priority" {
+		t.Fatalf("sort order not remembered, cookies: %v", rec.Result().Cookies())
+	}
+
+	// Later requests without ?sort= use the cookie.
+	body = indexAs(t, h, "alice", "/", remembered)
+	if strings.Index(body, "newer, urgent") > strings.Index(body, "older, low") {
+		t.Fatal("sort cookie was ignored")
+	}
+}
+
+func TestIndexTagFilter(t *testing.T) {
+	store := models.NewStoreMemory()
+	ops, _ := store.Create("alice", models.ToDoInput{Title: "rotate certs"})
+	store.Tag(ops.ID, "alice", "ops")
+	store.Create("alice", models.ToDoInput{Title: "buy milk"})
+	h := newTestToDoHandler(t, store)
+
+	body := indexAs(t, h, "alice", "/?tag=ops")
+	if !strings.Contains(body, "rotate certs") || strings.Contains(body, "buy milk") {
+		t.Fatalf("?tag=ops did not filter:\n%s", body)
+	}
+	if !strings.Contains(body, `class="tag tag-selected"`) {
+		t.Fatal("selected tag not highlighted")
+	}
+
+	// htmx re-renders keep the filter of the page they were sent from.
+	req := httptest.NewRequest(http.MethodDelete, "/tasks/completed", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Current-URL", "http://example.com/?tag=ops")
+	req.AddCookie(sessionCookie(t, "alice"))
+	rec := httptest.NewRecorder()
+	h.ClearCompleted(rec, req)
+	if body := rec.Body.String(); strings.Contains(body, "buy milk") {
+		t.Fatalf("htmx re-render lost the tag filter:\n%s", body)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lists", h.CreateList)
+	mux.HandleFunc("DELETE /lists/{id}", h.DeleteList)
+	mux.HandleFunc("GET /lists/{id}/tasks", h.ServeList)
+	mux.HandleFunc("POST /lists/{id}/tasks", h.CreateToDo)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/lists", "name=Work")
+	page := rec.Header().Get("HX-Redirect")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(page, "/lists/") {
+		t.Fatalf("create list: got %d, HX-Redirect %q", rec.Code, page)
+	}
+	if rec := do(http.MethodPost, "/lists", "name=work"); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate list name: expected 409, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodPost, strings.TrimSuffix(page, "/tasks")+"/tasks", "title=ship+release")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ship release") {
+		t.Fatalf("create in list: got %d:\n%s", rec.Code, rec.Body)
+	}
+	store.Create("alice", models.ToDoInput{Title: "buy milk"})
+
+	rec = do(http.MethodGet, page, "")
+	if body := rec.Body.String(); !strings.Contains(body, "ship release") || strings.Contains(body, "buy milk") {
+		t.Fatalf("list page shows the wrong todos:\n%s", body)
+	}
+	if body := indexAs(t, h, "alice", "/"); strings.Contains(body, "ship release") || !strings.Contains(body, "buy milk") {
+		t.Fatalf("Inbox shows the wrong todos:\n%s", body)
+	}
+
+	// Without htmx, ticking a todo goes back to its list.
+	all, _ := store.GetAll("alice", models.ListOptions{})
+	var shipped *models.ToDo
+	for _, todo := range all {
+		if todo.Title == "ship release" {
+			shipped = todo
+		}
+	}
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/tasks/%d", shipped.ID), strings.NewReader("completed=on"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sessionCookie(t, "alice"))
+	rec = httptest.NewRecorder()
+	h.UpdateToDo(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != page {
+		t.Fatalf("plain toggle: got %d, Location %q, want %q", rec.Code, rec.Header().Get("Location"), page)
+	}
+
+	rec = do(http.MethodDelete, strings.TrimSuffix(page, "/tasks"), "")
+	if rec.Header().Get("HX-Redirect") != "/" {
+		t.Fatalf("delete list: got %d, HX-Redirect %q", rec.Code, rec.Header().Get("HX-Redirect"))
+	}
+	if rec := do(http.MethodGet, page, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted list: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	store := models.NewStoreMemory()
+	todo, _ := store.Create("alice", models.ToDoInput{Title: "move house"})
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks/{id}/items", h.AddChecklistItem)
+	mux.HandleFunc("PUT /tasks/{id}/items/{item}", h.UpdateChecklistItem)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, fmt.Sprintf("/tasks/%d/items", todo.ID), "item=pack+boxes")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "pack boxes") || !strings.Contains(body, "☑ 0/1") {
+		t.Fatalf("add item: got %d:\n%s", rec.Code, body)
+	}
+	if rec := do(http.MethodPost, fmt.Sprintf("/tasks/%d/items", todo.ID), "item=+"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("blank item: expected 400, got %d", rec.Code)
+	}
+
+	items, _ := store.Items(todo.ID, "alice")
+	item := fmt.Sprintf("/tasks/%d/items/%d", todo.ID, items[0].ID)
+	if body := do(http.MethodPut, item, "done=true").Body.String(); !strings.Contains(body, "☑ 1/1") {
+		t.Fatalf("checking the item:\n%s", body)
+	}
+	if got, _ := store.Get(todo.ID, "alice"); got.Completed {
+		t.Fatal("checking the last item completed the todo")
+	}
+
+	// Unchecking an item of a completed todo reopens it, so the whole app
+	// is re-rendered to move it out of Completed.
+	in := todo.Input()
+	in.Completed = true
+	store.Update(todo.ID, in, "alice")
+	rec = do(http.MethodPut, item, "")
+	if rec.Header().Get("HX-Retarget") != "#todoApp" || !strings.Contains(rec.Body.String(), `id="todoApp"`) {
+		t.Fatalf("reopening: HX-Retarget %q:\n%s", rec.Header().Get("HX-Retarget"), rec.Body)
+	}
+}
+
+func TestRecurringToDo(t *testing.T) {
+	store := models.NewStoreMemory()
+	todo, _ := store.Create("alice", models.ToDoInput{Title: "water plants"})
+	h := newTestToDoHandler(t, store)
+	put := func(form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/tasks/%d", todo.ID), strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		h.UpdateToDo(rec, req)
+		return rec
+	}
+
+	// Saving the edit form sets the rule.
+	rec := put("title=water+plants&repeat=daily&repeat_every=3&repeat_from=completion")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "3 days after completion") {
+		t.Fatalf("saving the rule: got %d:\n%s", rec.Code, rec.Body)
+	}
+	if rec := put("title=water+plants&repeat=weekly&repeat_day=XX"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad weekday: expected 400, got %d", rec.Code)
+	}
+
+	// Ticking the checkbox completes it and creates the next occurrence.
+	put("completed=on")
+	all, _ := store.GetAll("alice", models.ListOptions{})
+	if len(all) != 2 {
+		t.Fatalf("expected the next occurrence, have %+v", all)
+	}
+	next := all[1]
+	if next.ID == todo.ID || next.Completed || next.DueAt == nil || next.Recurrence == "" {
+		t.Fatalf("unexpected next occurrence %+v", next)
+	}
+	if want := time.Now().UTC().AddDate(0, 0, 3).Format("2006-01-02"); next.DueDate() != want {
+		t.Errorf("next due %s, want %s", next.DueDate(), want)
+	}
+}
+
+func TestToDoNotes(t *testing.T) {
+	store := models.NewStoreMemory()
+	todo, _ := store.Create("alice", models.ToDoInput{Title: "write report"})
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/{id}/item", h.GetToDoItem)
+	mux.HandleFunc("GET /tasks/", h.ServeToDo)
+	mux.HandleFunc("PUT /tasks/", h.UpdateToDo)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	path := fmt.Sprintf("/tasks/%d", todo.ID)
+
+	// The edit form's notes field is saved; the checkbox toggle, which
+	// doesn't send it, leaves the notes alone.
+	notes := "See **draft** at https://example.com/doc\r\n\r\n- intro\r\n- <script>alert(1)</script>"
+	do(http.MethodPut, path, "title=write+report&notes="+url.QueryEscape(notes))
+	do(http.MethodPut, path, "completed=on")
+	if got, _ := store.Get(todo.ID, "alice"); !strings.HasPrefix(got.Notes, "See **draft**") || strings.Contains(got.Notes, "\r") {
+		t.Fatalf("notes = %q", got.Notes)
+	}
+
+	rec := do(http.MethodGet, path, "")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("detail page: got %d:\n%s", rec.Code, body)
+	}
+	for _, want := range []string{
+		"<strong>draft</strong>",
+		`<a href="https://example.com/doc"`,
+		"<li>&lt;script&gt;alert(1)&lt;/script&gt;</li>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("detail page lacks %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>alert") {
+		t.Errorf("detail page runs the notes' script:\n%s", body)
+	}
+
+	// The list item (as the edit form's Cancel loads it) links to the page.
+	if body := do(http.MethodGet, path+"/item", "").Body.String(); !strings.Contains(body, `href="`+path+`"`) {
+		t.Errorf("list item doesn't link to the detail page:\n%s", body)
+	}
+	if rec := do(http.MethodGet, "/tasks/999", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing todo: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestMoveToDo(t *testing.T) {
+	store := models.NewStoreMemory()
+	a, _ := store.Create("alice", models.ToDoInput{Title: "a"})
+	b, _ := store.Create("alice", models.ToDoInput{Title: "b"})
+	c, _ := store.Create("alice", models.ToDoInput{Title: "c"})
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks/{id}/move", h.MoveToDo)
+	move := func(id int, form string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/tasks/%d/move", id), strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Dropping c at the top sends an empty "after".
+	if code := move(c.ID, fmt.Sprintf("after=&before=%d", a.ID)); code != http.StatusNoContent {
+		t.Fatalf("move: expected 204, got %d", code)
+	}
+	body := indexAs(t, h, "alice", "/")
+	at := func(todo *models.ToDo) int { return strings.Index(body, fmt.Sprintf(`id="todo-%d"`, todo.ID)) }
+	if !(at(c) < at(a) && at(a) < at(b)) {
+		t.Fatalf("expected order c, a, b:\n%s", body)
+	}
+
+	if code := move(a.ID, "after=x"); code != http.StatusBadRequest {
+		t.Errorf("bad anchor: expected 400, got %d", code)
+	}
+	if code := move(a.ID, ""); code != http.StatusBadRequest {
+		t.Errorf("no anchor: expected 400, got %d", code)
+	}
+	if code := move(b.ID, "after=999"); code != http.StatusNotFound {
+		t.Errorf("missing anchor: expected 404, got %d", code)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	store := models.NewStoreMemory()
+	store.Create("alice", models.ToDoInput{Title: "Buy milk"})
+	store.Create("alice", models.ToDoInput{Title: "Call plumber", Notes: "kitchen sink", Completed: true})
+	work, _ := store.CreateList("alice", "Work")
+	store.Create("alice", models.ToDoInput{ListID: work.ID, Title: "Kitchen budget"})
+	h := newTestToDoHandler(t, store)
+	get := func(target string, htmx bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		h.Search(rec, req)
+		return rec
+	}
+
+	// Live results span every list and include completed tasks.
+	body := get("/search?q=kitch", true).Body.String()
+	if !strings.Contains(body, "Kitchen budget") || !strings.Contains(body, "Call plumber") ||
+		!strings.Contains(body, "Work") || strings.Contains(body, "Buy milk") {
+		t.Fatalf("search results:\n%s", body)
+	}
+	if body := get("/search?q=submarine", true).Body.String(); !strings.Contains(body, "No tasks match") {
+		t.Fatalf("no results:\n%s", body)
+	}
+	if body := get("/search?q=", true).Body.String(); strings.TrimSpace(body) != "" {
+		t.Fatalf("empty query rendered:\n%s", body)
+	}
+
+	// Without htmx the index page shows the same results.
+	if rec := get("/search?q=milk", false); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/?q=milk" {
+		t.Fatalf("plain GET: %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if body := indexAs(t, h, "alice", "/?q=plumber"); !strings.Contains(body, `value="plumber"`) ||
+		!strings.Contains(body, `<a href="/tasks/2" class=" line-through text-gray-500  hover:underline">Call plumber</a>`) {
+		t.Fatalf("index with ?q=:\n%s", body)
+	}
+}
+
+func TestIndexLoadMore(t *testing.T) {
+	store := models.NewStoreMemory()
+	for i := 1; i <= indexPageSize+5; i++ {
+		store.Create("alice", models.ToDoInput{Title: fmt.Sprintf("task %03d", i)})
+	}
+	h := newTestToDoHandler(t, store)
+
+	body := indexAs(t, h, "alice", "/")
+	if !strings.Contains(body, fmt.Sprintf("task %03d", indexPageSize)) ||
+		strings.Contains(body, fmt.Sprintf("task %03d", indexPageSize+1)) {
+		t.Fatalf("first page should stop at task %d:\n%s", indexPageSize, body)
+	}
+	i := strings.Index(body, `hx-get="/lists/1/tasks/more?status=active&cursor=`)
+	if i < 0 || strings.Contains(body, "status=completed&cursor=") {
+		t.Fatalf("expected a Load more button for active tasks only:\n%s", body)
+	}
+	more := body[i+len(`hx-get="`):]
+	more = more[:strings.IndexByte(more, '"')]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lists/{id}/tasks/more", h.MoreToDos)
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get(more)
+	body = rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `hx-swap-oob="beforeend:#noDateList"`) ||
+		!strings.Contains(body, fmt.Sprintf("task %03d", indexPageSize+5)) ||
+		strings.Contains(body, fmt.Sprintf("task %03d", indexPageSize)) || strings.Contains(body, "Load more") {
+		t.Fatalf("next page: got %d:\n%s", rec.Code, body)
+	}
+
+	if rec := get("/lists/1/tasks/more?status=active&cursor=bogus"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad cursor: expected 400, got %d", rec.Code)
+	}
+	if rec := get("/lists/1/tasks/more"); rec.Code != http.StatusBadRequest {
+		t.Errorf("no status: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	store := models.NewStoreMemory()
+	a, _ := store.Create("alice", models.ToDoInput{Title: "Water plants"})
+	b, _ := store.Create("alice", models.ToDoInput{Title: "Pay rent"})
+	store.Create("bob", models.ToDoInput{Title: "Bob's secret"})
+	h := newTestToDoHandler(t, store)
+	h.TrashRetention = 30 * 24 * time.Hour
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /trash", h.ServeTrash)
+	mux.HandleFunc("POST /trash/{id}/restore", h.RestoreToDo)
+	mux.HandleFunc("DELETE /trash/{id}", h.PurgeToDo)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, todo := range []*models.ToDo{a, b} {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/tasks/%d", todo.ID), nil)
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		h.DeleteToDo(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("delete: expected 200, got %d", rec.Code)
+		}
+	}
+	if body := indexAs(t, h, "alice", "/"); strings.Contains(body, "Water plants") {
+		t.Fatalf("deleted todo still listed:\n%s", body)
+	}
+
+	rec := do(http.MethodGet, "/trash")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "Water plants") || !strings.Contains(body, "Pay rent") ||
+		strings.Contains(body, "Bob's secret") || !strings.Contains(body, "after 30 days") ||
+		!strings.Contains(body, fmt.Sprintf(`hx-post="/trash/%d/restore"`, a.ID)) {
+		t.Fatalf("trash page: got %d:\n%s", rec.Code, body)
+	}
+
+	if rec := do(http.MethodPost, fmt.Sprintf("/trash/%d/restore", a.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d", rec.Code)
+	}
+	if body := indexAs(t, h, "alice", "/"); !strings.Contains(body, "Water plants") {
+		t.Fatalf("restored todo missing:\n%s", body)
+	}
+	if rec := do(http.MethodDelete, fmt.Sprintf("/trash/%d", b.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("purge: expected 200, got %d", rec.Code)
+	}
+	if body := do(http.MethodGet, "/trash").Body.String(); !strings.Contains(body, "The trash is empty.") {
+		t.Fatalf("trash should be empty:\n%s", body)
+	}
+	if rec := do(http.MethodDelete, fmt.Sprintf("/trash/%d", a.ID)); rec.Code != http.StatusNotFound {
+		t.Errorf("purging a live todo: expected 404, got %d", rec.Code)
+	}
+}
+
+// undoToken returns the token of the undo toast in body, or "".
+func undoToken(body string) string {
+	i := strings.Index(body, `hx-post="/undo/`)
+	if i < 0 || !strings.Contains(body, `id="toast" hx-swap-oob="true"`) {
+		return ""
+	}
+	token := body[i+len(`hx-post="/undo/`):]
+	return token[:strings.IndexByte(token, '"')]
+}
+
+func TestUndo(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /tasks/", h.UpdateToDo)
+	mux.HandleFunc("DELETE /tasks/completed", h.ClearCompleted)
+	mux.HandleFunc("DELETE /tasks/", h.DeleteToDo)
+	mux.HandleFunc("POST /undo/{token}", h.Undo)
+	do := func(user, method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, user))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	undo := func(user, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		token := undoToken(body)
+		if token == "" {
+			t.Fatalf("no undo toast in:\n%s", body)
+		}
+		return do(user, http.MethodPost, "/undo/"+token, "")
+	}
+
+	// Deleting.
+	milk, _ := store.Create("alice", models.ToDoInput{Title: "Buy milk"})
+	rec := do("alice", http.MethodDelete, fmt.Sprintf("/tasks/%d", milk.ID), "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Task deleted.") {
+		t.Fatalf("delete: got %d:\n%s", rec.Code, rec.Body)
+	}
+	deleted := rec.Body.String()
+	if rec := undo("bob", deleted); rec.Code != http.StatusGone {
+		t.Errorf("another user's undo: expected 410, got %d", rec.Code)
+	}
+	if rec := undo("alice", deleted); rec.Code != http.StatusOK || rec.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("undo delete: got %d %v", rec.Code, rec.Header())
+	}
+	if _, err := store.Get(milk.ID, "alice"); err != nil {
+		t.Fatalf("undo didn't restore the todo: %v", err)
+	}
+	if rec := undo("alice", deleted); rec.Code != http.StatusGone {
+		t.Errorf("second undo: expected 410, got %d", rec.Code)
+	}
+
+	// Completing a recurring todo with a checklist.
+	plants, _ := store.Create("alice", models.ToDoInput{Title: "Water plants", Recurrence: "FREQ=DAILY"})
+	first, _ := store.AddItem(plants.ID, "alice", "ferns")
+	store.AddItem(plants.ID, "alice", "cactus")
+	store.SetItemDone(plants.ID, first.ID, "alice", true)
+	rec = do("alice", http.MethodPut, fmt.Sprintf("/tasks/%d", plants.ID), "completed=on")
+	if !strings.Contains(rec.Body.String(), "Task completed.") {
+		t.Fatalf("completing: no toast in\n%s", rec.Body)
+	}
+	if all, _ := store.GetAll("alice", models.ListOptions{}); len(all) != 3 {
+		t.Fatalf("completing should spawn the next occurrence, have %d todos", len(all))
+	}
+	if rec := undo("alice", rec.Body.String()); rec.Code != http.StatusOK {
+		t.Fatalf("undo completion: got %d %s", rec.Code, rec.Body)
+	}
+	got, _ := store.Get(plants.ID, "alice")
+	if got.Completed || got.Recurrence != "FREQ=DAILY" || got.ItemsDone != 1 || got.ItemsTotal != 2 {
+		t.Errorf("after undo: %+v", got)
+	}
+	all, _ := store.GetAll("alice", models.ListOptions{})
+	trash, _ := store.Trash("alice")
+	if len(all) != 2 || len(trash) != 0 {
+		t.Errorf("the spawned occurrence should be gone: %d todos, %d in the trash", len(all), len(trash))
+	}
+
+	// An edit made after completing wins over the undo.
+	call, _ := store.Create("alice", models.ToDoInput{Title: "Call mom"})
+	rec = do("alice", http.MethodPut, fmt.Sprintf("/tasks/%d", call.ID), "completed=on")
+	done, _ := store.Get(call.ID, "alice")
+	in := done.Input()
+	in.Title = "Call mom back"
+	store.Update(call.ID, in, "alice")
+	if rec := undo("alice", rec.Body.String()); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "changed since") {
+		t.Fatalf("undo after an edit: got %d:\n%s", rec.Code, rec.Body)
+	}
+	if got, _ := store.Get(call.ID, "alice"); got.Title != "Call mom back" || !got.Completed {
+		t.Errorf("the refused undo changed the todo: %+v", got)
+	}
+	store.Delete(call.ID, "alice")
+
+	// Editing through the form gets no toast.
+	rec = do("alice", http.MethodPut, fmt.Sprintf("/tasks/%d", milk.ID), "title=Buy+oat+milk&completed=on")
+	if undoToken(rec.Body.String()) != "" {
+		t.Errorf("inline edit offered an undo:\n%s", rec.Body)
+	}
+
+	// Clearing completed todos brings back only those.
+	rec = do("alice", http.MethodDelete, "/tasks/completed", "")
+	if all, _ := store.GetAll("alice", models.ListOptions{}); len(all) != 1 {
+		t.Fatalf("clear: %d todos left", len(all))
+	}
+	if rec := undo("alice", rec.Body.String()); rec.Code != http.StatusOK {
+		t.Fatalf("undo clear: got %d", rec.Code)
+	}
+	if _, err := store.Get(milk.ID, "alice"); err != nil {
+		t.Errorf("undo clear didn't restore the todo: %v", err)
+	}
+
+	// Nothing to clear, nothing to undo.
+	store.Delete(milk.ID, "alice")
+	if rec := do("alice", http.MethodDelete, "/tasks/completed", ""); undoToken(rec.Body.String()) != "" {
+		t.Errorf("empty clear offered an undo")
+	}
+}
+
+func TestUndoExpires(t *testing.T) {
+	u := newUndoRegistry()
+	now := time.Now()
+	u.now = func() time.Time { return now }
+	ran := false
+	token := u.add("alice", func(models.ToDoStore) error { ran = true; return nil })
+	stale := u.add("alice", func(models.ToDoStore) error { return nil })
+
+	now = now.Add(undoWindow + time.Second)
+	if _, ok := u.take(stale, "alice"); ok {
+		t.Error("expired token was accepted")
+	}
+	u.add("alice", func(models.ToDoStore) error { return nil }) // prunes the rest
+	if _, ok := u.take(token, "alice"); ok || ran || len(u.actions) != 1 {
+		t.Errorf("expired actions were kept: %d left", len(u.actions))
+	}
+}
+
+func TestToDoHistory(t *testing.T) {
+	events := models.NewEventStoreMemory()
+	store := models.NewAuditedStore(models.NewStoreMemory(), events)
+	todo, _ := store.Create("alice", models.ToDoInput{Title: "draft"})
+	h := newTestToDoHandler(t, store)
+	h.Events = events
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/", h.ServeToDo)
+	mux.HandleFunc("PUT /tasks/", h.UpdateToDo)
+	mux.HandleFunc("DELETE /lists/{id}", h.DeleteList)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		RequestID(mux).ServeHTTP(rec, req)
+		return rec
+	}
+	path := fmt.Sprintf("/tasks/%d", todo.ID)
+	do(http.MethodPut, path, "title=final")
+
+	body := do(http.MethodGet, path, "").Body.String()
+	for _, want := range []string{"History", "update", "<del>draft</del> → final", "create"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("history lacks %q:\n%s", want, body)
+		}
+	}
+	got, _ := events.Events(models.EventFilter{ToDoID: todo.ID, Limit: 1})
+	if len(got) != 1 || got[0].RequestID == "" {
+		t.Errorf("web edits should carry a request ID, got %+v", got)
+	}
+
+	// So do the purges of a deleted list's todos.
+	work, _ := store.CreateList("alice", "Work")
+	doomed, _ := store.Create("alice", models.ToDoInput{ListID: work.ID, Title: "old plan"})
+	do(http.MethodDelete, fmt.Sprintf("/lists/%d", work.ID), "")
+	got, _ = events.Events(models.EventFilter{ToDoID: doomed.ID, Limit: 1})
+	if len(got) != 1 || got[0].Action != models.ActionPurge || got[0].RequestID == "" {
+		t.Errorf("deleting a list should record purges with a request ID, got %+v", got)
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	store := models.NewStoreMemory()
+	todo, _ := store.Create("alice", models.ToDoInput{Title: "draft"})
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/{id}/edit", h.EditFormToDo)
+	mux.HandleFunc("PUT /tasks/", h.UpdateToDo)
+	do := func(method, target, form string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, "alice"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	path := fmt.Sprintf("/tasks/%d", todo.ID)
+
+	if body := do(http.MethodGet, path+"/edit", "").Body.String(); !strings.Contains(body, `name="version" value="1"`) {
+		t.Fatalf("edit form lacks the version:\n%s", body)
+	}
+
+	// Two tabs opened the form at version 1; the first save wins.
+	if rec := do(http.MethodPut, path, "version=1&title=theirs&tags=work"); rec.Code != http.StatusOK {
+		t.Fatalf("first save: got %d: %s", rec.Code, rec.Body)
+	}
+	rec := do(http.MethodPut, path, "version=1&title=mine&tags=home")
+	body := rec.Body.String()
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("second save: expected 409, got %d: %s", rec.Code, body)
+	}
+	for _, want := range []string{
+		"<strong>theirs</strong>", "#work", // the newer version
+		`value="mine"`, `value="home"`, // the user's edit, ready to save again
+		`name="version" value="3"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("conflict form lacks %s:\n%s", want, body)
+		}
+	}
+	if got, _ := store.Get(todo.ID, "alice"); got.Title != "theirs" || got.Tags.String() != "work" {
+		t.Fatalf("the losing save changed the todo: %+v", got)
+	}
+
+	// Saving the form again re-applies the edit.
+	if rec := do(http.MethodPut, path, "version=3&title=mine&tags=home"); rec.Code != http.StatusOK {
+		t.Fatalf("re-applied save: got %d: %s", rec.Code, rec.Body)
+	}
+	if got, _ := store.Get(todo.ID, "alice"); got.Title != "mine" || got.Tags.String() != "home" {
+		t.Fatalf("re-applied save = %+v", got)
+	}
+
+	// The checkbox toggle applies to the version it just read.
+	if rec := do(http.MethodPut, path, "completed=on"); rec.Code != http.StatusOK {
+		t.Fatalf("toggle: got %d", rec.Code)
+	}
+}
+
+// racingStore runs race once, right after the next Get, as if another
+// request changed the todo between the handler reading and saving it.
+type racingStore struct {
+	models.ToDoStore
+	race func()
+}
+
+func (s *racingStore) Get(id int, username string) (*models.ToDo, error) {
+	t, err := s.ToDoStore.Get(id, username)
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return t, err
+}
+
+func TestToggleConflict(t *testing.T) {
+	inner := models.NewStoreMemory()
+	store := &racingStore{ToDoStore: inner}
+	todo, _ := inner.Create("alice", models.ToDoInput{Title: "draft"})
+	h := newTestToDoHandler(t, store)
+	path := fmt.Sprintf("/tasks/%d", todo.ID)
+
+	store.race = func() {
+		in := todo.Input()
+		in.Title = "theirs"
+		if _, err := inner.Update(todo.ID, in, "alice"); err != nil {
+			t.Fatalf("concurrent Update: %v", err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPut, path, strings.NewReader("completed=on"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(sessionCookie(t, "alice"))
+	rec := httptest.NewRecorder()
+	h.UpdateToDo(rec, req)
+	if rec.Code != http.StatusConflict || rec.Header().Get("HX-Retarget") != "#todoApp" {
+		t.Fatalf("racing toggle: expected 409 retargeted to #todoApp, got %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), "theirs") {
+		t.Errorf("conflict should show the todo as it is now:\n%s", rec.Body)
+	}
+	if got, _ := inner.Get(todo.ID, "alice"); got.Title != "theirs" || got.Completed {
+		t.Errorf("the toggle overwrote the concurrent edit: %+v", got)
+	}
+}
+
+func TestBulkToDos(t *testing.T) {
+	store := models.NewStoreMemory()
+	h := newTestToDoHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks/bulk", h.BulkToDos)
+	mux.HandleFunc("POST /lists/{id}/tasks/bulk", h.BulkToDos)
+	mux.HandleFunc("POST /undo/{token}", h.Undo)
+	do := func(user, target string, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(sessionCookie(t, user))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	ids := func(todos ...*models.ToDo) []string {
+		out := make([]string, len(todos))
+		for i, t := range todos {
+			out[i] = fmt.Sprint(t.ID)
+		}
+		return out
+	}
+
+	milk, _ := store.Create("alice", models.ToDoInput{Title: "Buy milk"})
+	plants, _ := store.Create("alice", models.ToDoInput{Title: "Water plants", Recurrence: "FREQ=DAILY"})
+	theirs, _ := store.Create("bob", models.ToDoInput{Title: "Bob's"})
+
+	// The list page has the bar and a checkbox per todo.
+	body := indexAs(t, h, "alice", "/")
+	if !strings.Contains(body, `id="bulkForm"`) ||
+		!strings.Contains(body, fmt.Sprintf(`name="ids" value="%d" form="bulkForm"`, milk.ID)) {
+		t.Fatalf("no bulk controls on the index page:\n%s", body)
+	}
+
+	// Completing spawns the next occurrence of a repeating todo.
+	rec := do("alice", "/tasks/bulk", url.Values{"op": {"complete"}, "ids": ids(milk, plants)})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `id="todoApp"`) {
+		t.Fatalf("complete: got %d:\n%s", rec.Code, rec.Body)
+	}
+	active, _ := store.GetAll("alice", models.ListOptions{Status: models.StatusActive})
+	if len(active) != 1 || active[0].Title != "Water plants" || active[0].ID == plants.ID {
+		t.Fatalf("after completing, active = %+v", active)
+	}
+
+	// Moving to a list, then tagging and setting a deadline.
+	work, _ := store.CreateList("alice", "Work")
+	path := fmt.Sprintf("/lists/%d/tasks/bulk", work.ID)
+	rec = do("alice", path, url.Values{"op": {"move"}, "list_id": {fmt.Sprint(work.ID)}, "ids": ids(milk, plants)})
+	if got, _ := store.Get(milk.ID, "alice"); rec.Code != http.StatusOK || got.ListID != work.ID {
+		t.Fatalf("move: got %d, todo in list %d", rec.Code, got.ListID)
+	}
+	do("alice", path, url.Values{"op": {"tag"}, "tags": {"ops, #billing"}, "ids": ids(milk)})
+	do("alice", path, url.Values{"op": {"set_due"}, "due_date": {"2030-01-02"}, "due_tz": {"UTC"}, "ids": ids(milk)})
+	if got, _ := store.Get(milk.ID, "alice"); got.Tags.String() != "billing, ops" || got.DueDate() != "2030-01-02" {
+		t.Errorf("after tagging and setting a due date: %+v", got)
+	}
+
+	// Bad requests change nothing.
+	for name, form := range map[string]url.Values{
+		"unknown op":   {"op": {"archive"}, "ids": ids(milk)},
+		"no todos":     {"op": {"reopen"}},
+		"bad id":       {"op": {"reopen"}, "ids": {"x"}},
+		"bad tag":      {"op": {"tag"}, "tags": {"a/b"}, "ids": ids(milk)},
+		"bad due date": {"op": {"set_due"}, "due_date": {"tomorrow"}, "ids": ids(milk)},
+	} {
+		if rec := do("alice", "/tasks/bulk", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+	rec = do("alice", "/tasks/bulk", url.Values{"op": {"reopen"}, "ids": ids(milk, theirs)})
+	if got, _ := store.Get(milk.ID, "alice"); rec.Code != http.StatusNotFound || !got.Completed {
+		t.Errorf("with another user's todo: got %d, todo %+v", rec.Code, got)
+	}
+
+	// Deleting offers to undo it.
+	rec = do("alice", path, url.Values{"op": {"delete"}, "ids": ids(milk, plants)})
+	if !strings.Contains(rec.Body.String(), "Selected tasks deleted.") {
+		t.Fatalf("delete: no toast in\n%s", rec.Body)
+	}
+	if trash, _ := store.Trash("alice"); len(trash) != 2 {
+		t.Fatalf("delete: %d todos in the trash", len(trash))
+	}
+	token := undoToken(rec.Body.String())
+	if rec := do("alice", "/undo/"+token, nil); rec.Code != http.StatusOK {
+		t.Fatalf("undo: got %d", rec.Code)
+	}
+	if trash, _ := store.Trash("alice"); len(trash) != 0 {
+		t.Errorf("undo left %d todos in the trash", len(trash))
+	}
+}
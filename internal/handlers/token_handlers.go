@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/templates"
+)
+
+// tokensPageData is what pages/tokens.html renders.
+type tokensPageData struct {
+	Username  string
+	CSRFToken string
+	Tokens    []*models.Token
+	NewSecret string
+	Error     string
+}
+
+// TokenHandler serves the /tokens page for managing personal API tokens.
+type TokenHandler struct {
+	store     models.TokenStore
+	Templates *templates.Set
+}
+
+// NewTokenHandler parses the layout + tokens page from src.
+func NewTokenHandler(store models.TokenStore, src templates.Source) (*TokenHandler, error) {
+	tmpl, err := src.Parse("layout.html", "pages/tokens.html")
+	if err != nil {
+		return nil, err
+	}
+	return &TokenHandler{store: store, Templates: tmpl}, nil
+}
+
+// render shows the tokens page with any one-off message.
+func (h *TokenHandler) render(w http.ResponseWriter, r *http.Request, status int, data tokensPageData) {
+	data.Username = requestUser(r)
+	data.CSRFToken = csrfToken(r)
+	tokens, err := h.store.List(data.Username)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	data.Tokens = tokens
+	w.WriteHeader(status)
+	if err := h.Templates.ExecuteTemplate(w, "layout.html", data); err != nil {
+		logging.Errorf("rendering tokens page: %v", err)
+	}
+}
+
+// ServeHTTP routes GET/POST /tokens and DELETE /tokens/{id}. Tokens can only
+// be managed from a browser session, never with another token.
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if viaToken(r) {
+		http.Error(w, "tokens can only be managed from a signed-in session", http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.URL.Path == "/tokens" && r.Method == http.MethodGet:
+		h.render(w, r, http.StatusOK, tokensPageData{})
+	case r.URL.Path == "/tokens" && r.Method == http.MethodPost:
+		h.CreateToken(w, r)
+	case strings.HasPrefix(r.URL.Path, "/tokens/") && r.Method == http.MethodDelete:
+		h.RevokeToken(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// CreateToken handles POST "/tokens" and shows the new secret once.
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" {
+		h.render(w, r, http.StatusBadRequest, tokensPageData{Error: "Token name cannot be empty."})
+		return
+	}
+	var expiresAt *time.Time
+	if days := r.PostFormValue("expires_in_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			h.render(w, r, http.StatusBadRequest, tokensPageData{Error: "Invalid expiry."})
+			return
+		}
+		t := time.Now().AddDate(0, 0, n)
+		expiresAt = &t
+	}
+
+	secret, _, err := h.store.Create(requestUser(r), name, expiresAt)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	h.render(w, r, http.StatusCreated, tokensPageData{NewSecret: secret})
+}
+
+// RevokeToken handles DELETE "/tokens/{id}"; htmx removes the row on 200.
+func (h *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/tokens/"):])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.Revoke(id, requestUser(r)); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			http.Error(w, "token not found", http.StatusNotFound)
+			return
+		}
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// trashData is what pages/trash.html renders.
+type trashData struct {
+	Username  string
+	CSRFToken string
+	Todos     []trashedToDo
+	// Retention is how long todos stay in the trash; 0 means forever.
+	Retention time.Duration
+}
+
+// trashedToDo is a todo in the trash with the name of its list.
+type trashedToDo struct {
+	*models.ToDo
+	ListName string
+}
+
+// RetentionDays is Retention in whole days, for display.
+func (d trashData) RetentionDays() int {
+	return int(d.Retention / (24 * time.Hour))
+}
+
+// ServeTrash handles GET "/trash": the user's deleted todos, most recent
+// first, each with Restore and Delete forever buttons.
+func (h *Handler) ServeTrash(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	todos, err := h.store.Trash(user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	lists, err := h.store.Lists(user)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	names := make(map[int]string, len(lists))
+	for _, l := range lists {
+		names[l.ID] = l.Name
+	}
+	data := trashData{
+		Username:  user,
+		CSRFToken: csrfToken(r),
+		Retention: h.TrashRetention,
+	}
+	for _, t := range todos {
+		data.Todos = append(data.Todos, trashedToDo{ToDo: t, ListName: names[t.ListID]})
+	}
+	if err := h.Trash.ExecuteTemplate(w, "layout.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RestoreToDo handles POST "/trash/{id}/restore"; htmx removes the row from
+// the trash on 200.
+func (h *Handler) RestoreToDo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := storeFor(h.store, r).Restore(id, h.currentUser(r)); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PurgeToDo handles DELETE "/trash/{id}", deleting a todo for good; htmx
+// removes the row on 200.
+func (h *Handler) PurgeToDo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := storeFor(h.store, r).Purge(id, h.currentUser(r)); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// undoWindow is how long an undo token stays valid. The toast offering it
+// fades out a little earlier (see .toast in app.css), so a click at the
+// last moment still lands.
+const undoWindow = 10 * time.Second
+
+// undoAction reverses one operation of one user.
+type undoAction struct {
+	user    string
+	expires time.Time
+	revert  revertFunc
+}
+
+// revertFunc reverses an operation through store, which is the one of the
+// request asking for the undo, so the audit log credits that request.
+type revertFunc func(store models.ToDoStore) error
+
+// undoRegistry keeps the pending undo actions, by token, in memory: a
+// restart (or another instance behind a load balancer) forgets them, which
+// is fine for something only offered for a few seconds. Tokens can be used
+// once.
+type undoRegistry struct {
+	mu      sync.Mutex
+	actions map[string]*undoAction
+	now     func() time.Time
+}
+
+func newUndoRegistry() *undoRegistry {
+	return &undoRegistry{actions: make(map[string]*undoAction), now: time.Now}
+}
+
+// add registers revert for user and returns its token. Expired actions
+// are dropped on the way.
+func (u *undoRegistry) add(user string, revert revertFunc) string {
+	token := hex.EncodeToString(randomKey()[:16])
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	for t, a := range u.actions {
+		if now.After(a.expires) {
+			delete(u.actions, t)
+		}
+	}
+	u.actions[token] = &undoAction{user: user, expires: now.Add(undoWindow), revert: revert}
+	return token
+}
+
+// take removes and returns the action behind token, if it belongs to user
+// and hasn't expired.
+func (u *undoRegistry) take(token, user string) (revertFunc, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	a, ok := u.actions[token]
+	if !ok || a.user != user {
+		return nil, false
+	}
+	delete(u.actions, token)
+	if u.now().After(a.expires) {
+		return nil, false
+	}
+	return a.revert, true
+}
+
+// undoToast is what the "undo_toast" template renders; without a Token it
+// is just a message.
+type undoToast struct {
+	Message string
+	Token   string
+}
+
+// offerUndo registers revert and writes an out-of-band toast offering to
+// run it, to be appended to an htmx response.
+func (h *Handler) offerUndo(w io.Writer, user, message string, revert revertFunc) {
+	toast := undoToast{Message: message, Token: h.undo.add(user, revert)}
+	if err := h.Templates.ExecuteTemplate(w, "undo_toast", toast); err != nil {
+		logging.Errorf("rendering undo toast: %v", err)
+	}
+}
+
+// ignoreNotFound treats a todo that has gone away since as nothing left to
+// undo.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// undoDelete restores todos from the trash.
+func undoDelete(user string, ids ...int) revertFunc {
+	return func(store models.ToDoStore) error {
+		for _, id := range ids {
+			if _, err := store.Restore(id, user); ignoreNotFound(err) != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// undoComplete reopens todo, which was just completed: its fields go back
+// to old, the checklist items in open are unchecked again, and the next
+// occurrence completing it spawned, if any, is deleted for good. version is
+// the todo's version once completed; if it has changed since, nothing is
+// undone and the revert fails with models.ErrConflict.
+func undoComplete(user string, old *models.ToDo, version int, open []*models.ChecklistItem, spawned *models.ToDo) revertFunc {
+	return func(store models.ToDoStore) error {
+		in := old.Input()
+		in.Version = version
+		if _, err := store.Update(old.ID, in, user); err != nil {
+			return ignoreNotFound(err)
+		}
+		if spawned != nil {
+			if err := ignoreNotFound(store.Delete(spawned.ID, user)); err != nil {
+				return err
+			}
+			if err := ignoreNotFound(store.Purge(spawned.ID, user)); err != nil {
+				return err
+			}
+		}
+		for _, it := range open {
+			if _, err := store.SetItemDone(old.ID, it.ID, user, false); err != nil && !errors.Is(err, models.ErrItemNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Undo handles POST "/undo/{token}" from an undo toast: it reverses the
+// operation and reloads the page to show the result. Tokens are only good
+// for undoWindow, and only once. An operation whose todo has been changed
+// since is left alone, with a 409 and a toast saying so.
+func (h *Handler) Undo(w http.ResponseWriter, r *http.Request) {
+	revert, ok := h.undo.take(r.PathValue("token"), h.currentUser(r))
+	if !ok {
+		http.Error(w, fmt.Sprintf("nothing to undo (undo is only possible for %s)", undoWindow), http.StatusGone)
+		return
+	}
+	err := revert(storeFor(h.store, r))
+	if errors.Is(err, models.ErrConflict) {
+		const msg = "The task was changed since, so it wasn't undone."
+		if r.Header.Get("HX-Request") != "true" {
+			http.Error(w, msg, http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		if err := h.Templates.ExecuteTemplate(w, "undo_toast", undoToast{Message: msg}); err != nil {
+			logging.Errorf("rendering undo toast: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
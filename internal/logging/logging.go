@@ -0,0 +1,68 @@
+// Package logging adds level filtering on top of the standard library logger
+// while keeping the "[LEVEL] message" line format used across the app.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level orders log messages by severity.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string { return levelNames[l] }
+
+// ParseLevel converts "debug", "info", "warn" or "error" into a Level.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+var minLevel atomic.Int32
+
+func init() { minLevel.Store(int32(LevelInfo)) }
+
+// SetLevel drops messages below l from now on.
+func SetLevel(l Level) { minLevel.Store(int32(l)) }
+
+// Enabled reports whether messages at l are currently written.
+func Enabled(l Level) bool { return int32(l) >= minLevel.Load() }
+
+func logf(l Level, prefix, format string, args ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+	log.Output(3, prefix+fmt.Sprintf(format, args...))
+}
+
+// Debugf logs chatty diagnostics, hidden unless the level is debug.
+func Debugf(format string, args ...interface{}) { logf(LevelDebug, "[DEBUG] ", format, args...) }
+
+// Infof logs normal operational messages.
+func Infof(format string, args ...interface{}) { logf(LevelInfo, "[INFO] ", format, args...) }
+
+// Warnf logs recoverable problems.
+func Warnf(format string, args ...interface{}) { logf(LevelWarn, "[WARN] ", format, args...) }
+
+// Errorf logs failures.
+func Errorf(format string, args ...interface{}) { logf(LevelError, "[ERROR] ", format, args...) }
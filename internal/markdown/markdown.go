@@ -0,0 +1,229 @@
+// Package markdown renders the small Markdown dialect used for todo notes
+// into HTML that is safe to embed in a page.
+//
+// Safety comes from construction rather than filtering: every piece of the
+// source is HTML-escaped, and the only tags in the output are the ones the
+// renderer writes itself. Raw HTML in notes is shown as text. Links only
+// keep http, https and mailto URLs (and relative ones).
+//
+// Supported: paragraphs (single newlines become <br>), ATX headings,
+// "-", "*" or "+" bullet lists, numbered lists, "> " quotes, fenced code
+// blocks, `code`, **strong**, *emphasis* or _emphasis_, [links](url),
+// bare http(s) URLs and backslash escapes.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Render converts src to sanitized HTML.
+func Render(src string) template.HTML {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```"):
+			i = fence(&b, lines, i)
+		case heading.MatchString(trimmed):
+			m := heading.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+			i++
+		case bullet.MatchString(line):
+			i = list(&b, lines, i, bullet, "ul")
+		case ordered.MatchString(line):
+			i = list(&b, lines, i, ordered, "ol")
+		case strings.HasPrefix(trimmed, ">"):
+			i = quote(&b, lines, i)
+		default:
+			i = paragraph(&b, lines, i)
+		}
+	}
+	return template.HTML(b.String())
+}
+
+var (
+	heading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bullet   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	ordered  = regexp.MustCompile(`^\s*\d{1,9}[.)]\s+(.*)$`)
+	language = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+)
+
+// startsBlock reports whether line begins something other than paragraph
+// text, ending the paragraph before it.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, ">") ||
+		heading.MatchString(trimmed) || bullet.MatchString(line) || ordered.MatchString(line)
+}
+
+// fence writes the fenced code block starting at lines[i] and returns the
+// index after it. An unclosed fence runs to the end of the text.
+func fence(b *strings.Builder, lines []string, i int) int {
+	lang := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "```"))
+	b.WriteString("<pre><code")
+	if language.MatchString(lang) {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	for i++; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "```" {
+			i++
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// list writes the run of items matching item starting at lines[i] as a
+// <tag> list and returns the index after it.
+func list(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag + ">\n")
+	for ; i < len(lines); i++ {
+		m := item.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		b.WriteString("<li>" + inline(m[1]) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// quote writes the run of "> " lines starting at lines[i] as a blockquote
+// and returns the index after it.
+func quote(b *strings.Builder, lines []string, i int) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmed, ">") {
+			break
+		}
+		text = append(text, inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))))
+	}
+	b.WriteString("<blockquote><p>" + strings.Join(text, "<br>\n") + "</p></blockquote>\n")
+	return i
+}
+
+// paragraph writes the paragraph starting at lines[i] and returns the index
+// after it.
+func paragraph(b *strings.Builder, lines []string, i int) int {
+	var text []string
+	for ; i < len(lines) && (len(text) == 0 || !startsBlock(lines[i])); i++ {
+		text = append(text, inline(strings.TrimSpace(lines[i])))
+	}
+	b.WriteString("<p>" + strings.Join(text, "<br>\n") + "</p>\n")
+	return i
+}
+
+// inline renders the spans within one line of text.
+func inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_[]()#+-.!>", rune(rest[1])):
+			b.WriteString(html.EscapeString(rest[1:2]))
+			i += 2
+			continue
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				b.WriteString("<code>" + html.EscapeString(rest[1:1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**"):
+			if end := strings.Index(rest[2:], "**"); end > 0 {
+				b.WriteString("<strong>" + inline(rest[2:2+end]) + "</strong>")
+				i += end + 4
+				continue
+			}
+		case rest[0] == '*' || (rest[0] == '_' && (i == 0 || !isWord(s[i-1]))):
+			if end := strings.IndexByte(rest[1:], rest[0]); end > 0 && rest[1] != ' ' {
+				b.WriteString("<em>" + inline(rest[1:1+end]) + "</em>")
+				i += end + 2
+				continue
+			}
+		case rest[0] == '[':
+			if text, href, n, ok := link(rest); ok {
+				if u, ok := safeURL(href); ok {
+					b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + inline(text) + "</a>")
+				} else {
+					b.WriteString(inline(text))
+				}
+				i += n
+				continue
+			}
+		case (strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")) && (i == 0 || !isWord(s[i-1])):
+			raw := bareURL(rest)
+			if u, ok := safeURL(raw); ok {
+				b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + html.EscapeString(raw) + "</a>")
+				i += len(raw)
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// link parses "[text](href)" at the start of s, returning how many bytes
+// it spans.
+func link(s string) (text, href string, n int, ok bool) {
+	close := strings.Index(s, "](")
+	if close < 0 {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[close+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	return s[1:close], strings.TrimSpace(s[close+2 : close+2+end]), close + 3 + end, true
+}
+
+// bareURL returns the URL at the start of s, up to whitespace and without
+// trailing punctuation.
+func bareURL(s string) string {
+	end := strings.IndexAny(s, " \t<>\"")
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.TrimRight(s[:end], ".,;:!?)'")
+}
+
+// safeURL accepts http, https, mailto and relative URLs.
+func safeURL(raw string) (string, bool) {
+	if raw == "" || strings.ContainsAny(raw, " \t\n\"'<>`") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String(), true
+	case "":
+		if strings.HasPrefix(raw, "//") || strings.Contains(raw, ":") && !strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "#") && !strings.HasPrefix(raw, "?") {
+			return "", false
+		}
+		return u.String(), true
+	}
+	return "", false
+}
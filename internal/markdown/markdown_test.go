@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one<br>\ntwo</p>\n<p>three</p>\n"},
+		{"heading", "## Plan ##", "<h2>Plan</h2>\n"},
+		{"bullets", "- a\n* **b**\n+ c", "<ul>\n<li>a</li>\n<li><strong>b</strong></li>\n<li>c</li>\n</ul>\n"},
+		{"numbered", "1. a\n2) b", "<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"quote", "> said\n> twice", "<blockquote><p>said<br>\ntwice</p></blockquote>\n"},
+		{"fence", "```go\nif a < b {}\n```", "<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>\n"},
+		{"code span", "run `rm -rf <dir>`", "<p>run <code>rm -rf &lt;dir&gt;</code></p>\n"},
+		{"emphasis", "*a* _b_ snake_case_name", "<p><em>a</em> <em>b</em> snake_case_name</p>\n"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)",
+			"<p><a href=\"https://example.com/a?b=1&amp;c=2\" rel=\"nofollow noopener noreferrer\">docs</a></p>\n"},
+		{"bare URL", "see https://example.com/x.",
+			"<p>see <a href=\"https://example.com/x\" rel=\"nofollow noopener noreferrer\">https://example.com/x</a>.</p>\n"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>\n"},
+	}
+	for _, tc := range tests {
+		if got := string(Render(tc.in)); got != tc.want {
+			t.Errorf("%s: Render(%q) =\n%q\nwant\n%q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRenderIsSafe(t *testing.T) {
+	for _, in := range []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"[x](data:text/html;base64,PHNjcmlwdD4=)",
+		`[x](https://example.com/" onmouseover="alert(1))`,
+		"```\"><script>\n</script>\n```",
+		"**<b onclick=alert(1)>**",
+	} {
+		out := string(Render(in))
+		for _, bad := range []string{"<script", "<img", "<b ", "javascript:", "data:", "onmouseover=\"", "JaVaScRiPt"} {
+			if strings.Contains(out, bad) {
+				t.Errorf("Render(%q) = %q contains %q", in, out, bad)
+			}
+		}
+	}
+}
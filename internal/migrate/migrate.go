@@ -0,0 +1,275 @@
+// Package migrate applies numbered SQL migrations to Postgres and records
+// which ones ran in a schema_migrations table.
+//
+// Every migration runs in its own transaction while the runner holds a
+// session-level advisory lock, so several instances starting at once apply
+// each migration exactly once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockID is the pg_advisory_lock key guarding schema changes. It is
+// arbitrary but must stay stable across releases.
+const lockID = 7316_2024
+
+const (
+	upMarker   = "-- +migrate Up"
+	downMarker = "-- +migrate Down"
+)
+
+var fileRE = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+)\.sql$`)
+
+// ErrNoDown is returned when rolling back a migration without a Down section.
+var ErrNoDown = errors.New("migration has no down section")
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load reads every NNNN_name.sql file in the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var migs []Migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := fileRE.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %q: name must look like 0001_description.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", prev, e.Name(), version)
+		}
+		seen[version] = e.Name()
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := split(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("migration %q: %w", e.Name(), err)
+		}
+		migs = append(migs, Migration{Version: version, Name: m[2], Up: up, Down: down})
+	}
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	return migs, nil
+}
+
+// split separates a migration file into its Up and Down SQL. A file without
+// markers is treated as Up-only.
+func split(body string) (up, down string, err error) {
+	upAt := strings.Index(body, upMarker)
+	downAt := strings.Index(body, downMarker)
+	switch {
+	case upAt < 0 && downAt < 0:
+		return strings.TrimSpace(body), "", nil
+	case upAt < 0:
+		return "", "", fmt.Errorf("%q without %q", downMarker, upMarker)
+	case downAt < 0:
+		return strings.TrimSpace(body[upAt+len(upMarker):]), "", nil
+	case downAt < upAt:
+		return "", "", fmt.Errorf("%q must come after %q", downMarker, upMarker)
+	}
+	up = strings.TrimSpace(body[upAt+len(upMarker) : downAt])
+	down = strings.TrimSpace(body[downAt+len(downMarker):])
+	return up, down, nil
+}
+
+// Runner applies a fixed set of migrations to one database.
+type Runner struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New loads the migrations in fsys and returns a Runner for db.
+func New(db *sqlx.DB, fsys fs.FS) (*Runner, error) {
+	migs, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: migs}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it ran.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := r.locked(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sqlx.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					m.Version, m.Name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply %04d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the most recently applied migration. It returns nil, nil when
+// nothing is applied.
+func (r *Runner) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := r.locked(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("revert %04d_%s: %w", m.Version, m.Name, ErrNoDown)
+			}
+			err := inTx(ctx, conn, func(tx *sqlx.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`DELETE FROM schema_migrations WHERE version = $1`, m.Version,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("revert %04d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = &m
+			return nil
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := r.locked(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			st := Status{Migration: m}
+			if at, ok := applied[m.Version]; ok {
+				st.AppliedAt = &at
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// locked runs fn on a dedicated connection holding the migration advisory
+// lock, creating schema_migrations first if needed.
+func (r *Runner) locked(ctx context.Context, fn func(conn *sqlx.Conn) error) (err error) {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so a cancelled ctx still releases the lock.
+		_, uerr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+		if err == nil && uerr != nil {
+			err = fmt.Errorf("release migration lock: %w", uerr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+           version    INTEGER     PRIMARY KEY,
+           name       TEXT        NOT NULL,
+           applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+         )`,
+	); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// appliedVersions maps each applied version to its applied_at time.
+func appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int]time.Time, error) {
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(ctx, &rows,
+		`SELECT version, applied_at FROM schema_migrations`,
+	); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// inTx runs fn in a transaction on conn, rolling back if it fails.
+func inTx(ctx context.Context, conn *sqlx.Conn, fn func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gjb1088/To-Do-list/migrations"
+)
+
+func TestLoadSortsAndSplits(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_col.sql": {Data: []byte("-- +migrate Up\nALTER TABLE t ADD c INT;\n-- +migrate Down\nALTER TABLE t DROP c;\n")},
+		"0001_init.sql":    {Data: []byte("CREATE TABLE t (id INT);\n")},
+		"README.md":        {Data: []byte("ignored")},
+	}
+	migs, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migs) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migs))
+	}
+	if migs[0].Version != 1 || migs[0].Name != "init" || migs[0].Down != "" {
+		t.Errorf("unexpected first migration %+v", migs[0])
+	}
+	if migs[1].Version != 2 || migs[1].Up != "ALTER TABLE t ADD c INT;" || migs[1].Down != "ALTER TABLE t DROP c;" {
+		t.Errorf("unexpected second migration %+v", migs[1])
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name": {"create.sql": {Data: []byte("SELECT 1;")}},
+		"duplicate version": {
+			"0001_a.sql": {Data: []byte("SELECT 1;")},
+			"1_b.sql":    {Data: []byte("SELECT 1;")},
+		},
+		"down before up": {"0001_a.sql": {Data: []byte("-- +migrate Down\nx\n-- +migrate Up\ny\n")}},
+	}
+	for name, fsys := range cases {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migs, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load(migrations.FS): %v", err)
+	}
+	for i, m := range migs {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d (no gaps)", m.Name, m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %04d_%s needs both Up and Down sections", m.Version, m.Name)
+		}
+	}
+}
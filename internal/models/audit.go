@@ -0,0 +1,310 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/logging"
+)
+
+// AuditedStore wraps a ToDoStore and records every change made through it
+// in an EventStore. Reads pass straight through.
+//
+// The change and its event are written separately. A change that went
+// through is reported as such even if recording it fails; the failure is
+// logged.
+type AuditedStore struct {
+	ToDoStore
+	events    EventStore
+	actor     string
+	requestID string
+}
+
+// updateRetries is how often Update tries again when another change to
+// the todo slips in between reading and updating it.
+const updateRetries = 3
+
+// NewAuditedStore returns store with its changes recorded in events. Until
+// narrowed down with As, changes are attributed to the owner of the todo.
+func NewAuditedStore(store ToDoStore, events EventStore) *AuditedStore {
+	return &AuditedStore{ToDoStore: store, events: events}
+}
+
+// As returns a view of s whose changes are attributed to actor and tagged
+// with requestID.
+func (s *AuditedStore) As(actor, requestID string) *AuditedStore {
+	c := *s
+	c.actor, c.requestID = actor, requestID
+	return &c
+}
+
+// record writes an event about todo id of username, logging a failure.
+func (s *AuditedStore) record(id int, username string, action Action, changes Changes) {
+	actor := s.actor
+	if actor == "" {
+		actor = username
+	}
+	e := &Event{
+		ToDoID:    id,
+		Username:  username,
+		Actor:     actor,
+		Action:    action,
+		Changes:   changes,
+		RequestID: s.requestID,
+	}
+	if err := s.events.Record(e); err != nil {
+		logging.Errorf("recording %s of todo %d (request %q): %v", action, id, s.requestID, err)
+	}
+}
+
+// recordChange records the difference between before and after, if any,
+// as a complete or reopen event when Completed flipped and as an update
+// otherwise. Given fields, only those are compared: before was then read
+// apart from the change, and the other fields may differ because of
+// someone else's change in between.
+func (s *AuditedStore) recordChange(before, after *ToDo, username string, fields ...string) {
+	changes := diff(before, after)
+	if len(fields) > 0 {
+		kept := Changes{}
+		for _, f := range fields {
+			if c, ok := changes[f]; ok {
+				kept[f] = c
+			}
+		}
+		changes = kept
+	}
+	if len(changes) == 0 {
+		return
+	}
+	action := ActionUpdate
+	switch {
+	case after.Completed && !before.Completed:
+		action = ActionComplete
+	case !after.Completed && before.Completed:
+		action = ActionReopen
+	}
+	s.record(after.ID, username, action, changes)
+}
+
+func (s *AuditedStore) Create(username string, in ToDoInput) (*ToDo, error) {
+	t, err := s.ToDoStore.Create(username, in)
+	if err != nil {
+		return nil, err
+	}
+	s.record(t.ID, username, ActionCreate, diff(nil, t))
+	return t, nil
+}
+
+// recordSpawned records the creation of the next occurrence that completing
+// t spawned, if any.
+func (s *AuditedStore) recordSpawned(t *ToDo, username string) {
+	if t.Spawned != nil {
+		s.record(t.Spawned.ID, username, ActionCreate, diff(nil, t.Spawned))
+	}
+}
+
+// Update holds an unconditional update to the version it has just read, so
+// the event compares against exactly what was replaced, and starts over if
+// another change gets in first.
+func (s *AuditedStore) Update(id int, in ToDoInput, username string) (*ToDo, error) {
+	for attempt := 0; ; attempt++ {
+		before, err := s.ToDoStore.Get(id, username)
+		if err != nil {
+			return nil, err
+		}
+		pinned := in
+		if pinned.Version == 0 {
+			pinned.Version = before.Version
+		}
+		after, err := s.ToDoStore.Update(id, pinned, username)
+		if errors.Is(err, ErrConflict) && in.Version == 0 && attempt < updateRetries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.recordChange(before, after, username)
+		s.recordSpawned(after, username)
+		return after, nil
+	}
+}
+
+func (s *AuditedStore) Delete(id int, username string) error {
+	if err := s.ToDoStore.Delete(id, username); err != nil {
+		return err
+	}
+	s.record(id, username, ActionDelete, nil)
+	return nil
+}
+
+func (s *AuditedStore) Move(id int, username string, beforeID, afterID int) (*ToDo, error) {
+	before, err := s.ToDoStore.Get(id, username)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.ToDoStore.Move(id, username, beforeID, afterID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordChange(before, after, username, "position")
+	return after, nil
+}
+
+// ClearCompleted records a delete for each todo that was completed just
+// before; one completed at the same moment may go unrecorded.
+func (s *AuditedStore) ClearCompleted(username string, listID int) error {
+	done, err := s.ToDoStore.GetAll(username, ListOptions{ListID: listID, Status: StatusCompleted})
+	if err != nil {
+		return err
+	}
+	if err := s.ToDoStore.ClearCompleted(username, listID); err != nil {
+		return err
+	}
+	for _, t := range done {
+		s.record(t.ID, username, ActionDelete, nil)
+	}
+	return nil
+}
+
+func (s *AuditedStore) Bulk(username string, ids []int, a BulkAction) ([]*ToDo, []*ToDo, error) {
+	todos, before, err := s.ToDoStore.Bulk(username, ids, a)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, t := range todos {
+		if a.Op == BulkDelete {
+			s.record(t.ID, username, ActionDelete, nil)
+		} else {
+			s.recordChange(before[i], t, username)
+			s.recordSpawned(t, username)
+		}
+	}
+	return todos, before, nil
+}
+
+func (s *AuditedStore) Restore(id int, username string) (*ToDo, error) {
+	t, err := s.ToDoStore.Restore(id, username)
+	if err != nil {
+		return nil, err
+	}
+	s.record(id, username, ActionRestore, nil)
+	return t, nil
+}
+
+func (s *AuditedStore) Purge(id int, username string) error {
+	if err := s.ToDoStore.Purge(id, username); err != nil {
+		return err
+	}
+	s.record(id, username, ActionPurge, nil)
+	return nil
+}
+
+// PurgeDeleted is attributed to SystemActor unless As says otherwise.
+func (s *AuditedStore) PurgeDeleted(cutoff time.Time) ([]PurgedToDo, error) {
+	purged, err := s.ToDoStore.PurgeDeleted(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	sys := s
+	if sys.actor == "" {
+		sys = s.As(SystemActor, s.requestID)
+	}
+	for _, p := range purged {
+		sys.record(p.ID, p.Username, ActionPurge, nil)
+	}
+	return purged, nil
+}
+
+// DeleteList records a purge for each todo that goes with the list,
+// trashed ones included.
+func (s *AuditedStore) DeleteList(id int, username string) error {
+	todos, err := s.ToDoStore.GetAll(username, ListOptions{ListID: id})
+	if err != nil {
+		return err
+	}
+	trash, err := s.ToDoStore.Trash(username)
+	if err != nil {
+		return err
+	}
+	for _, t := range trash {
+		if t.ListID == id {
+			todos = append(todos, t)
+		}
+	}
+	if err := s.ToDoStore.DeleteList(id, username); err != nil {
+		return err
+	}
+	for _, t := range todos {
+		s.record(t.ID, username, ActionPurge, nil)
+	}
+	return nil
+}
+
+// retag runs a Tag or Untag and records the tags changing.
+func (s *AuditedStore) retag(id int, username string, change func() error) error {
+	before, err := s.ToDoStore.Get(id, username)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	s.recordAfter(before, username, "tags")
+	return nil
+}
+
+func (s *AuditedStore) Tag(id int, username string, tags ...string) error {
+	return s.retag(id, username, func() error { return s.ToDoStore.Tag(id, username, tags...) })
+}
+
+func (s *AuditedStore) Untag(id int, username string, tags ...string) error {
+	return s.retag(id, username, func() error { return s.ToDoStore.Untag(id, username, tags...) })
+}
+
+// Checklist changes are not audited themselves, but adding or unchecking
+// an item can reopen its todo, which is.
+
+func (s *AuditedStore) AddItem(todoID int, username, title string) (*ChecklistItem, error) {
+	before, err := s.ToDoStore.Get(todoID, username)
+	if err != nil {
+		return nil, err
+	}
+	it, err := s.ToDoStore.AddItem(todoID, username, title)
+	if err != nil {
+		return nil, err
+	}
+	s.recordReopen(before, username)
+	return it, nil
+}
+
+func (s *AuditedStore) SetItemDone(todoID, itemID int, username string, done bool) (*ChecklistItem, error) {
+	before, err := s.ToDoStore.Get(todoID, username)
+	if err != nil {
+		return nil, err
+	}
+	it, err := s.ToDoStore.SetItemDone(todoID, itemID, username, done)
+	if err != nil {
+		return nil, err
+	}
+	s.recordReopen(before, username)
+	return it, nil
+}
+
+// recordReopen records before being reopened by a checklist change, if it
+// was completed.
+func (s *AuditedStore) recordReopen(before *ToDo, username string) {
+	if before.Completed {
+		s.recordAfter(before, username, "completed")
+	}
+}
+
+// recordAfter reads the todo before was taken from again, now that it has
+// been changed, and records what changed in fields.
+func (s *AuditedStore) recordAfter(before *ToDo, username string, fields ...string) {
+	after, err := s.ToDoStore.Get(before.ID, username)
+	if err != nil {
+		logging.Errorf("recording change of todo %d (request %q): %v", before.ID, s.requestID, err)
+		return
+	}
+	s.recordChange(before, after, username, fields...)
+}
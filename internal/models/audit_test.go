@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+// brokenEvents is an EventStore that can't record anything.
+type brokenEvents struct{ EventStore }
+
+func (brokenEvents) Record(*Event) error { return errors.New("disk full") }
+
+func TestAuditedStoreRecordFailure(t *testing.T) {
+	s := NewAuditedStore(NewStoreMemory(), brokenEvents{})
+	todo, err := s.Create("alice", ToDoInput{Title: "draft"})
+	if err != nil {
+		t.Fatalf("Create reported the recording failure: %v", err)
+	}
+	if _, err := s.Update(todo.ID, ToDoInput{Title: "final"}, "alice"); err != nil {
+		t.Fatalf("Update reported the recording failure: %v", err)
+	}
+	if err := s.Tag(todo.ID, "alice", "work"); err != nil {
+		t.Fatalf("Tag reported the recording failure: %v", err)
+	}
+	if err := s.Delete(todo.ID, "alice"); err != nil {
+		t.Fatalf("Delete reported the recording failure: %v", err)
+	}
+	if trash, _ := s.Trash("alice"); len(trash) != 1 || trash[0].Title != "final" {
+		t.Fatalf("changes didn't go through: %+v", trash)
+	}
+}
+
+// racingStore runs race once, right after the next Get, as if another
+// request changed the todo between the audit snapshot and the change.
+type racingStore struct {
+	ToDoStore
+	race func()
+}
+
+func (s *racingStore) Get(id int, username string) (*ToDo, error) {
+	t, err := s.ToDoStore.Get(id, username)
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return t, err
+}
+
+func TestAuditedStoreSnapshotRace(t *testing.T) {
+	inner := NewStoreMemory()
+	racing := &racingStore{ToDoStore: inner}
+	events := NewEventStoreMemory()
+	s := NewAuditedStore(racing, events)
+	todo, _ := s.Create("alice", ToDoInput{Title: "draft"})
+	sneak := func() {
+		in := todo.Input()
+		in.Notes = "theirs"
+		if _, err := inner.Update(todo.ID, in, "alice"); err != nil {
+			t.Fatalf("concurrent Update: %v", err)
+		}
+	}
+	changesOf := func() Changes {
+		t.Helper()
+		got, err := events.Events(EventFilter{ToDoID: todo.ID, Limit: 1})
+		if err != nil || len(got) != 1 {
+			t.Fatalf("Events = %+v, %v", got, err)
+		}
+		return got[0].Changes
+	}
+
+	// The unconditional update starts over and keeps the other change.
+	racing.race = sneak
+	in := todo.Input()
+	in.Title, in.Notes = "mine", "theirs"
+	if _, err := s.Update(todo.ID, in, "alice"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if c := changesOf(); len(c) != 1 || c["title"].To != "mine" {
+		t.Errorf("update recorded %+v, want only the title", c)
+	}
+
+	// Tagging records only the tags.
+	racing.race = func() {
+		in := todo.Input()
+		in.Title = "theirs again"
+		inner.Update(todo.ID, in, "alice")
+	}
+	if err := s.Tag(todo.ID, "alice", "work"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if c := changesOf(); len(c) != 1 || c["tags"].To != "work" {
+		t.Errorf("tagging recorded %+v, want only the tags", c)
+	}
+}
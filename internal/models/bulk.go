@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrInvalidBulk is returned by Bulk for an unknown operation, an empty or
+// oversized selection, or an operation missing what it needs.
+var ErrInvalidBulk = errors.New("invalid bulk action")
+
+// MaxBulk is the most todos one Bulk call may change.
+const MaxBulk = 500
+
+// BulkOp is the change Bulk makes to each selected todo.
+type BulkOp string
+
+const (
+	BulkComplete BulkOp = "complete"
+	BulkReopen   BulkOp = "reopen"
+	BulkDelete   BulkOp = "delete"
+	BulkMove     BulkOp = "move"
+	BulkTag      BulkOp = "tag"
+	BulkSetDue   BulkOp = "set_due"
+)
+
+// ParseBulkOp validates an operation name from a form or request body.
+func ParseBulkOp(s string) (BulkOp, error) {
+	switch op := BulkOp(s); op {
+	case BulkComplete, BulkReopen, BulkDelete, BulkMove, BulkTag, BulkSetDue:
+		return op, nil
+	}
+	return "", fmt.Errorf("%w: unknown operation %q", ErrInvalidBulk, s)
+}
+
+// BulkAction is the change one Bulk call makes. Which fields matter depends
+// on Op.
+type BulkAction struct {
+	Op BulkOp
+	// ListID is where BulkMove puts the todos; 0 means the Inbox.
+	ListID int
+	// Tags are what BulkTag adds.
+	Tags []string
+	// DueAt, DueHasTime and DueTZ are the deadline BulkSetDue gives every
+	// todo, as filled in by SetDue. A nil DueAt clears it.
+	DueAt      *time.Time
+	DueHasTime bool
+	DueTZ      string
+}
+
+// SetDue parses date, clock and tz with ParseDue and stores the result in
+// a. An empty date clears the deadline.
+func (a *BulkAction) SetDue(date, clock, tz string) error {
+	dueAt, hasTime, zone, err := ParseDue(date, clock, tz)
+	if err != nil {
+		return err
+	}
+	a.DueAt, a.DueHasTime, a.DueTZ = dueAt, hasTime, zone
+	return nil
+}
+
+// prepare checks a Bulk call's arguments and returns ids sorted without
+// duplicates, and a with its tags normalized and its deadline in UTC.
+func (a BulkAction) prepare(ids []int) ([]int, BulkAction, error) {
+	if _, err := ParseBulkOp(string(a.Op)); err != nil {
+		return nil, a, err
+	}
+	uniq := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniq = append(uniq, id)
+		}
+	}
+	switch {
+	case len(uniq) == 0:
+		return nil, a, fmt.Errorf("%w: no todos selected", ErrInvalidBulk)
+	case len(uniq) > MaxBulk:
+		return nil, a, fmt.Errorf("%w: at most %d todos at a time", ErrInvalidBulk, MaxBulk)
+	}
+	sort.Ints(uniq)
+
+	switch a.Op {
+	case BulkTag:
+		tags, err := NormalizeTags(a.Tags)
+		if err != nil {
+			return nil, a, err
+		}
+		if len(tags) == 0 {
+			return nil, a, fmt.Errorf("%w: no tags to add", ErrInvalidBulk)
+		}
+		a.Tags = tags
+	case BulkSetDue:
+		if a.DueAt != nil {
+			due := a.DueAt.UTC()
+			a.DueAt = &due
+		}
+	}
+	return uniq, a, nil
+}
+
+// sameDue reports whether t already has the deadline BulkSetDue would give it.
+func (a BulkAction) sameDue(t *ToDo) bool {
+	if a.DueAt == nil || t.DueAt == nil {
+		return a.DueAt == nil && t.DueAt == nil
+	}
+	return a.DueAt.Equal(*t.DueAt) && a.DueHasTime == t.DueHasTime && a.DueTZ == t.DueTZ
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+func (s *StoreMemory) Bulk(username string, ids []int, a BulkAction) ([]*ToDo, []*ToDo, error) {
+	ids, a, err := a.prepare(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check everything before changing anything.
+	entries := make([]*memToDo, len(ids))
+	for i, id := range ids {
+		if entries[i], err = s.get(id, username); err != nil {
+			return nil, nil, err
+		}
+	}
+	if a.Op == BulkMove {
+		if a.ListID, err = s.resolveList(username, a.ListID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	todos, before := []*ToDo{}, []*ToDo{}
+	for _, e := range entries {
+		prior := e.todo
+		if !s.bulkApply(e, a, now) {
+			continue
+		}
+		var spawned *ToDo
+		if a.Op == BulkComplete {
+			spawned = s.spawn(e)
+		}
+		t := e.todo
+		t.Spawned = spawned
+		todos, before = append(todos, &t), append(before, &prior)
+	}
+	return todos, before, nil
+}
+
+// bulkApply makes a's change to e, reporting false when e needed none.
+// Callers must hold mu for writing.
+func (s *StoreMemory) bulkApply(e *memToDo, a BulkAction, now time.Time) bool {
+	t := &e.todo
+	switch a.Op {
+	case BulkComplete, BulkReopen:
+		done := a.Op == BulkComplete
+		if t.Completed == done {
+			return false
+		}
+		t.Completed = done
+		if done {
+			for _, it := range s.items {
+				if it.ToDoID == t.ID {
+					it.Done = true
+				}
+			}
+			s.countItems(e)
+		}
+	case BulkDelete:
+		s.discard(e, now)
+		return true
+	case BulkMove:
+		if t.ListID == a.ListID {
+			return false
+		}
+		t.Position = s.lastPosition(e.owner, a.ListID) + 1
+		t.ListID = a.ListID
+	case BulkTag:
+		// like Tag, this leaves UpdatedAt alone
+		merged, _ := NormalizeTags(append(append([]string{}, t.Tags...), a.Tags...))
+		if len(merged) == len(t.Tags) {
+			return false
+		}
+		t.Tags = merged
+		t.Version++
+		return true
+	case BulkSetDue:
+		if a.sameDue(t) {
+			return false
+		}
+		t.DueAt = nil
+		if a.DueAt != nil {
+			due := *a.DueAt
+			t.DueAt = &due
+		}
+		t.DueHasTime, t.DueTZ = a.DueHasTime, a.DueTZ
+	}
+	t.Version++
+	t.UpdatedAt = now
+	return true
+}
@@ -0,0 +1,208 @@
+package models
+
+import "github.com/jmoiron/sqlx"
+
+func (s *StorePostgres) Bulk(username string, ids []int, a BulkAction) ([]*ToDo, []*ToDo, error) {
+	ids, a, err := a.prepare(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Op == BulkMove {
+		if a.ListID, err = s.resolveList(username, a.ListID); err != nil {
+			return nil, nil, err
+		}
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the whole selection first, reading how it is now; it is all or
+	// nothing.
+	var locked []*ToDo
+	if err := tx.Select(
+		&locked,
+		`SELECT `+todoColumns+`
+           FROM todos
+          WHERE id = ANY($1::int[])
+            AND username = $2
+            AND deleted_at IS NULL
+          ORDER BY id
+            FOR UPDATE`,
+		ids, username,
+	); err != nil {
+		return nil, nil, err
+	}
+	if len(locked) != len(ids) {
+		return nil, nil, ErrNotFound
+	}
+
+	changed, err := bulkApply(tx, username, ids, a)
+	if err != nil {
+		return nil, nil, err
+	}
+	todos, before := []*ToDo{}, []*ToDo{}
+	if len(changed) > 0 {
+		if err := tx.Select(
+			&todos,
+			`SELECT `+todoColumns+`
+               FROM todos
+              WHERE id = ANY($1::int[])
+              ORDER BY id`,
+			changed,
+		); err != nil {
+			return nil, nil, err
+		}
+	}
+	if a.Op == BulkComplete {
+		for _, t := range todos {
+			if t.Recurrence == "" {
+				continue
+			}
+			// the rule goes on to the next occurrence
+			if _, err := tx.Exec(`UPDATE todos SET recurrence = '' WHERE id = $1`, t.ID); err != nil {
+				return nil, nil, err
+			}
+			done := *t
+			t.Recurrence = ""
+			if t.Spawned, err = spawn(tx, username, &done); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	// both are in ID order
+	for _, t := range locked {
+		if len(before) < len(todos) && todos[len(before)].ID == t.ID {
+			before = append(before, t)
+		}
+	}
+	return todos, before, tx.Commit()
+}
+
+// bulkApply makes a's change to the locked todos ids and returns which of
+// them it changed. The ids have already been checked to belong to username.
+func bulkApply(tx *sqlx.Tx, username string, ids []int, a BulkAction) ([]int, error) {
+	var changed []int
+	switch a.Op {
+	case BulkComplete:
+		// Completing a todo checks its whole checklist.
+		if _, err := tx.Exec(
+			`UPDATE checklist_items
+                SET done = TRUE
+              WHERE todo_id = ANY($1::int[])
+                AND NOT done`,
+			ids,
+		); err != nil {
+			return nil, err
+		}
+		err := tx.Select(
+			&changed,
+			`UPDATE todos
+                SET completed  = TRUE,
+                    version    = version + 1,
+                    updated_at = NOW()
+              WHERE id = ANY($1::int[])
+                AND NOT completed
+          RETURNING id`,
+			ids,
+		)
+		return changed, err
+	case BulkReopen:
+		err := tx.Select(
+			&changed,
+			`UPDATE todos
+                SET completed  = FALSE,
+                    version    = version + 1,
+                    updated_at = NOW()
+              WHERE id = ANY($1::int[])
+                AND completed
+          RETURNING id`,
+			ids,
+		)
+		return changed, err
+	case BulkDelete:
+		err := tx.Select(
+			&changed,
+			`UPDATE todos
+                SET deleted_at = NOW()
+              WHERE id = ANY($1::int[])
+          RETURNING id`,
+			ids,
+		)
+		return changed, err
+	case BulkMove:
+		// they go last in the new list, in ID order
+		err := tx.Select(
+			&changed,
+			`WITH moved AS (
+                SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n
+                  FROM todos
+                 WHERE id = ANY($1::int[])
+                   AND list_id <> $2
+             ), last AS (
+                SELECT COALESCE(MAX(position), 0) AS position
+                  FROM todos
+                 WHERE username = $3
+                   AND list_id = $2
+                   AND deleted_at IS NULL
+             )
+             UPDATE todos t
+                SET list_id    = $2,
+                    position   = last.position + moved.n,
+                    version    = t.version + 1,
+                    updated_at = NOW()
+               FROM moved, last
+              WHERE t.id = moved.id
+          RETURNING t.id`,
+			ids, a.ListID, username,
+		)
+		return changed, err
+	case BulkTag:
+		if _, err := tx.Exec(
+			`INSERT INTO tags (username, name)
+             SELECT $1, unnest($2::text[])
+                 ON CONFLICT (username, name) DO NOTHING`,
+			username, a.Tags,
+		); err != nil {
+			return nil, err
+		}
+		// like Tag, this leaves updated_at alone
+		err := tx.Select(
+			&changed,
+			`WITH added AS (
+                INSERT INTO todo_tags (todo_id, tag_id)
+                SELECT t.id, g.id
+                  FROM unnest($1::int[]) AS t(id), tags g
+                 WHERE g.username = $2
+                   AND g.name = ANY($3)
+                    ON CONFLICT DO NOTHING
+             RETURNING todo_id
+             )
+             UPDATE todos
+                SET version = version + 1
+              WHERE id IN (SELECT todo_id FROM added)
+          RETURNING id`,
+			ids, username, a.Tags,
+		)
+		return changed, err
+	case BulkSetDue:
+		err := tx.Select(
+			&changed,
+			`UPDATE todos
+                SET due_at       = $2,
+                    due_has_time = $3,
+                    due_tz       = $4,
+                    version      = version + 1,
+                    updated_at   = NOW()
+              WHERE id = ANY($1::int[])
+                AND (due_at IS DISTINCT FROM $2
+                     OR due_has_time <> $3
+                     OR due_tz <> $4)
+          RETURNING id`,
+			ids, a.DueAt, a.DueHasTime, a.DueTZ,
+		)
+		return changed, err
+	}
+	return nil, ErrInvalidBulk
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrItemNotFound is returned when a checklist item doesn't exist or
+	// isn't on the given todo.
+	ErrItemNotFound = errors.New("checklist item not found")
+	// ErrInvalidItem is returned for empty or over-long item titles.
+	ErrInvalidItem = errors.New("invalid checklist item")
+)
+
+// MaxItemTitleLen is the longest checklist item title, in characters.
+const MaxItemTitleLen = 200
+
+// ChecklistItem is one step of a todo. Items keep the order they were
+// added in.
+//
+// A completed todo never has open items: completing a todo checks all of
+// its items, and adding an item to a completed todo or unchecking one of
+// its items reopens it. Checking the last open item leaves the todo as it
+// is. Deleting a todo deletes its items.
+type ChecklistItem struct {
+	ID        int       `db:"id" json:"id"`
+	ToDoID    int       `db:"todo_id" json:"todo_id"`
+	Title     string    `db:"title" json:"title"`
+	Done      bool      `db:"done" json:"done"`
+	Position  int       `db:"position" json:"position"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// NormalizeItemTitle trims title and checks it is usable for an item.
+func NormalizeItemTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	switch {
+	case title == "":
+		return "", fmt.Errorf("%w: title cannot be empty", ErrInvalidItem)
+	case utf8.RuneCountInString(title) > MaxItemTitleLen:
+		return "", fmt.Errorf("%w: title must be at most %d characters", ErrInvalidItem, MaxItemTitleLen)
+	}
+	return title, nil
+}
+
+// Progress is the todo's checklist progress, e.g. "3/5", or "" when it has
+// no items.
+func (t *ToDo) Progress() string {
+	if t.ItemsTotal == 0 {
+		return ""
+	}
+	return strconv.Itoa(t.ItemsDone) + "/" + strconv.Itoa(t.ItemsTotal)
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// drop permanently deletes todo id, live or trashed, together with its
+// checklist items. Callers must hold mu for writing.
+func (s *StoreMemory) drop(id int) {
+	for itemID, it := range s.items {
+		if it.ToDoID == id {
+			delete(s.items, itemID)
+		}
+	}
+	delete(s.todos, id)
+	delete(s.trash, id)
+}
+
+// countItems refreshes e's ItemsDone and ItemsTotal. Callers must hold mu
+// for writing.
+func (s *StoreMemory) countItems(e *memToDo) {
+	e.todo.ItemsDone, e.todo.ItemsTotal = 0, 0
+	for _, it := range s.items {
+		if it.ToDoID != e.todo.ID {
+			continue
+		}
+		e.todo.ItemsTotal++
+		if it.Done {
+			e.todo.ItemsDone++
+		}
+	}
+}
+
+// getItem returns item itemID if it is on todoID. Callers must hold mu.
+func (s *StoreMemory) getItem(todoID, itemID int) (*ChecklistItem, error) {
+	it, ok := s.items[itemID]
+	if !ok || it.ToDoID != todoID {
+		return nil, ErrItemNotFound
+	}
+	return it, nil
+}
+
+// reopen marks e not completed, as adding or unchecking an item does.
+// Callers must hold mu for writing.
+func (e *memToDo) reopen() {
+	if e.todo.Completed {
+		e.todo.Completed = false
+		e.todo.Version++
+		e.todo.UpdatedAt = time.Now().UTC()
+	}
+}
+
+func (s *StoreMemory) Items(todoID int, username string) ([]*ChecklistItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.get(todoID, username); err != nil {
+		return nil, err
+	}
+	items := []*ChecklistItem{}
+	for _, it := range s.items {
+		if it.ToDoID == todoID {
+			c := *it
+			items = append(items, &c)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (s *StoreMemory) AddItem(todoID int, username, title string) (*ChecklistItem, error) {
+	title, err := NormalizeItemTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(todoID, username)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	for _, it := range s.items {
+		if it.ToDoID == todoID && it.Position > pos {
+			pos = it.Position
+		}
+	}
+	it := &ChecklistItem{
+		ID:        s.nextItemID,
+		ToDoID:    todoID,
+		Title:     title,
+		Position:  pos + 1,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.items[it.ID] = it
+	s.nextItemID++
+	e.reopen()
+	s.countItems(e)
+
+	c := *it
+	return &c, nil
+}
+
+func (s *StoreMemory) SetItemDone(todoID, itemID int, username string, done bool) (*ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(todoID, username)
+	if err != nil {
+		return nil, err
+	}
+	it, err := s.getItem(todoID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	it.Done = done
+	if !done {
+		e.reopen()
+	}
+	s.countItems(e)
+
+	c := *it
+	return &c, nil
+}
+
+func (s *StoreMemory) DeleteItem(todoID, itemID int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(todoID, username)
+	if err != nil {
+		return err
+	}
+	if _, err := s.getItem(todoID, itemID); err != nil {
+		return err
+	}
+	delete(s.items, itemID)
+	s.countItems(e)
+	return nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// itemNotFound maps sql.ErrNoRows onto ErrItemNotFound.
+func itemNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrItemNotFound
+	}
+	return err
+}
+
+// reopenToDo marks a todo not completed, as adding or unchecking an item
+// does.
+func reopenToDo(q sqlx.Execer, id int) error {
+	_, err := q.Exec(
+		`UPDATE todos SET completed = FALSE, version = version + 1, updated_at = NOW() WHERE id = $1 AND completed`,
+		id,
+	)
+	return err
+}
+
+func (s *StorePostgres) Items(todoID int, username string) ([]*ChecklistItem, error) {
+	if err := ownsToDo(s.db, todoID, username); err != nil {
+		return nil, err
+	}
+	items := []*ChecklistItem{}
+	err := s.db.Select(
+		&items,
+		`SELECT id, todo_id, title, done, position, created_at
+           FROM checklist_items
+          WHERE todo_id = $1
+          ORDER BY position, id`,
+		todoID,
+	)
+	return items, err
+}
+
+func (s *StorePostgres) AddItem(todoID int, username, title string) (*ChecklistItem, error) {
+	title, err := NormalizeItemTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the todo so concurrent adds don't pick the same position.
+	var one int
+	if err := tx.Get(&one, `SELECT 1 FROM todos WHERE id = $1 AND username = $2 AND deleted_at IS NULL FOR UPDATE`, todoID, username); err != nil {
+		return nil, notFound(err)
+	}
+	var it ChecklistItem
+	err = tx.Get(
+		&it,
+		`INSERT INTO checklist_items (todo_id, title, position)
+         SELECT $1, $2, COALESCE(MAX(position), 0) + 1
+           FROM checklist_items
+          WHERE todo_id = $1
+      RETURNING id, todo_id, title, done, position, created_at`,
+		todoID, title,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := reopenToDo(tx, todoID); err != nil {
+		return nil, err
+	}
+	return &it, tx.Commit()
+}
+
+func (s *StorePostgres) SetItemDone(todoID, itemID int, username string, done bool) (*ChecklistItem, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := ownsToDo(tx, todoID, username); err != nil {
+		return nil, err
+	}
+	var it ChecklistItem
+	err = tx.Get(
+		&it,
+		`UPDATE checklist_items
+            SET done = $1
+          WHERE id = $2 AND todo_id = $3
+      RETURNING id, todo_id, title, done, position, created_at`,
+		done, itemID, todoID,
+	)
+	if err != nil {
+		return nil, itemNotFound(err)
+	}
+	if !done {
+		if err := reopenToDo(tx, todoID); err != nil {
+			return nil, err
+		}
+	}
+	return &it, tx.Commit()
+}
+
+func (s *StorePostgres) DeleteItem(todoID, itemID int, username string) error {
+	if err := ownsToDo(s.db, todoID, username); err != nil {
+		return err
+	}
+	res, err := s.db.Exec(
+		`DELETE FROM checklist_items WHERE id = $1 AND todo_id = $2`,
+		itemID, todoID,
+	)
+	if err := mustAffect(res, err); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package models_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/gjb1088/To-Do-list/internal/migrate"
+	"github.com/gjb1088/To-Do-list/internal/models"
+	"github.com/gjb1088/To-Do-list/internal/models/storetest"
+	"github.com/gjb1088/To-Do-list/migrations"
+)
+
+// postgresDSNEnv names the environment variable pointing the Postgres
+// conformance run at a scratch database. Every table in it is truncated.
+const postgresDSNEnv = "TODO_TEST_POSTGRES_DSN"
+
+func TestStoreMemoryConformance(t *testing.T) {
+	storetest.RunToDoStoreTests(t, func(t *testing.T) models.ToDoStore {
+		return models.NewStoreMemory()
+	})
+}
+
+func TestUserStoreMemoryConformance(t *testing.T) {
+	storetest.RunUserStoreTests(t, func(t *testing.T) models.UserStore {
+		return models.NewUserStoreMemory()
+	})
+}
+
+func TestTokenStoreMemoryConformance(t *testing.T) {
+	storetest.RunTokenStoreTests(t, func(t *testing.T) models.TokenStore {
+		return models.NewTokenStoreMemory()
+	})
+}
+
+func TestEventStoreMemoryConformance(t *testing.T) {
+	storetest.RunEventStoreTests(t, func(t *testing.T) models.EventStore {
+		return models.NewEventStoreMemory()
+	})
+}
+
+func TestAuditedStoreMemory(t *testing.T) {
+	storetest.RunAuditTests(t, func(t *testing.T) (models.ToDoStore, models.EventStore) {
+		return models.NewStoreMemory(), models.NewEventStoreMemory()
+	})
+}
+
+func TestStorePostgresConformance(t *testing.T) {
+	db := openTestPostgres(t)
+	storetest.RunToDoStoreTests(t, func(t *testing.T) models.ToDoStore {
+		resetPostgres(t, db)
+		seedUsers(t, db)
+		return models.NewStorePostgres(db)
+	})
+}
+
+func TestTokenStorePostgresConformance(t *testing.T) {
+	db := openTestPostgres(t)
+	storetest.RunTokenStoreTests(t, func(t *testing.T) models.TokenStore {
+		resetPostgres(t, db)
+		seedUsers(t, db)
+		return models.NewTokenStorePostgres(db)
+	})
+}
+
+func TestEventStorePostgresConformance(t *testing.T) {
+	db := openTestPostgres(t)
+	storetest.RunEventStoreTests(t, func(t *testing.T) models.EventStore {
+		resetPostgres(t, db)
+		return models.NewEventStorePostgres(db)
+	})
+}
+
+func TestAuditedStorePostgres(t *testing.T) {
+	db := openTestPostgres(t)
+	storetest.RunAuditTests(t, func(t *testing.T) (models.ToDoStore, models.EventStore) {
+		resetPostgres(t, db)
+		seedUsers(t, db)
+		return models.NewStorePostgres(db), models.NewEventStorePostgres(db)
+	})
+}
+
+func TestUserStorePostgresConformance(t *testing.T) {
+	db := openTestPostgres(t)
+	storetest.RunUserStoreTests(t, func(t *testing.T) models.UserStore {
+		resetPostgres(t, db)
+		return models.NewUserStorePostgres(db)
+	})
+}
+
+// openTestPostgres connects to the database named by TODO_TEST_POSTGRES_DSN
+// and migrates it to the latest schema, skipping the test when it is unset.
+func openTestPostgres(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set; skipping Postgres conformance tests", postgresDSNEnv)
+	}
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", postgresDSNEnv, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := runner.Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// seedUsers creates the users the conformance suites own data as.
+func seedUsers(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	for _, u := range []string{storetest.Alice, storetest.Bob} {
+		if _, err := db.Exec(
+			`INSERT INTO users (username, password_hash) VALUES ($1, '')`, u,
+		); err != nil {
+			t.Fatalf("seed user %q: %v", u, err)
+		}
+	}
+}
+
+// resetPostgres empties every table so each subtest starts from scratch.
+func resetPostgres(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	if _, err := db.Exec(`TRUNCATE todos, users, todo_events RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("reset database: %v", err)
+	}
+}
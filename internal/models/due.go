@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidDue is returned by ParseDue for malformed dates, times or zones.
+var ErrInvalidDue = errors.New("invalid due date")
+
+// DueBucket groups active todos on the index page.
+type DueBucket int
+
+const (
+	DueNone DueBucket = iota
+	DueOverdue
+	DueToday
+	DueUpcoming
+)
+
+// ParseDue turns form/API input into the DueAt/DueHasTime/DueTZ triple.
+// date is "2006-01-02" and may be empty for no deadline; clock is an
+// optional "15:04"; tz is an IANA zone name, defaulting to UTC.
+func ParseDue(date, clock, tz string) (dueAt *time.Time, hasTime bool, zone string, err error) {
+	if date == "" {
+		if clock != "" {
+			return nil, false, "", fmt.Errorf("%w: a due time needs a due date", ErrInvalidDue)
+		}
+		return nil, false, "", nil
+	}
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, false, "", fmt.Errorf("%w: unknown time zone %q", ErrInvalidDue, tz)
+	}
+
+	layout, value := "2006-01-02", date
+	if clock != "" {
+		layout, value = "2006-01-02 15:04", date+" "+clock
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return nil, false, "", fmt.Errorf("%w: %q", ErrInvalidDue, value)
+	}
+	return &t, clock != "", tz, nil
+}
+
+// SetDue parses date, clock and tz with ParseDue and stores the result in
+// in. An empty date clears the deadline.
+func (in *ToDoInput) SetDue(date, clock, tz string) error {
+	dueAt, hasTime, zone, err := ParseDue(date, clock, tz)
+	if err != nil {
+		return err
+	}
+	in.DueAt, in.DueHasTime, in.DueTZ = dueAt, hasTime, zone
+	return nil
+}
+
+// DueLocal returns DueAt in the zone it was entered in, or the zero time
+// when there is no deadline.
+func (t *ToDo) DueLocal() time.Time {
+	if t.DueAt == nil {
+		return time.Time{}
+	}
+	loc, err := time.LoadLocation(t.DueTZ)
+	if err != nil {
+		loc = time.UTC
+	}
+	return t.DueAt.In(loc)
+}
+
+// DueDate returns the deadline's date as "2006-01-02", or "" when there is
+// none. Together with DueTime and DueTZ it round-trips through ParseDue.
+func (t *ToDo) DueDate() string {
+	if t.DueAt == nil {
+		return ""
+	}
+	return t.DueLocal().Format("2006-01-02")
+}
+
+// DueTime returns the deadline's time of day as "15:04", or "" for
+// date-only deadlines.
+func (t *ToDo) DueTime() string {
+	if t.DueAt == nil || !t.DueHasTime {
+		return ""
+	}
+	return t.DueLocal().Format("15:04")
+}
+
+// DueLabel is the short human form shown next to a task, e.g. "Mar 14" or
+// "Mar 14, 09:30 America/New_York".
+func (t *ToDo) DueLabel() string {
+	if t.DueAt == nil {
+		return ""
+	}
+	local := t.DueLocal()
+	label := local.Format("Jan 2")
+	if local.Year() != time.Now().In(local.Location()).Year() {
+		label = local.Format("Jan 2, 2006")
+	}
+	if t.DueHasTime {
+		label += ", " + local.Format("15:04") + " " + t.DueTZ
+	}
+	return label
+}
+
+// Bucket says whether the todo is overdue, due today or upcoming as seen at
+// now by someone in loc. Date-only deadlines are compared by calendar date;
+// timed ones become overdue the moment they pass.
+func (t *ToDo) Bucket(now time.Time, loc *time.Location) DueBucket {
+	if t.DueAt == nil {
+		return DueNone
+	}
+	now = now.In(loc)
+	today := civilDate(now)
+
+	var due time.Time
+	if t.DueHasTime {
+		if t.DueAt.Before(now) {
+			return DueOverdue
+		}
+		due = civilDate(t.DueAt.In(loc))
+	} else {
+		due = civilDate(t.DueLocal())
+	}
+
+	switch {
+	case due.Before(today):
+		return DueOverdue
+	case due.Equal(today):
+		return DueToday
+	default:
+		return DueUpcoming
+	}
+}
+
+// civilDate strips t down to its calendar date, as midnight UTC, so dates
+// from different zones compare by year/month/day alone.
+func civilDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
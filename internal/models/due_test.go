@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDue(t *testing.T) {
+	due, hasTime, tz, err := ParseDue("2030-03-14", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hasTime || tz != "UTC" || !due.Equal(time.Date(2030, 3, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date-only: got %v %v %q", due, hasTime, tz)
+	}
+
+	if due, _, _, _ = ParseDue("", "", "Europe/Paris"); due != nil {
+		t.Fatalf("empty date should mean no deadline, got %v", due)
+	}
+
+	for _, in := range [][3]string{
+		{"14/03/2030", "", ""},
+		{"2030-03-14", "9am", ""},
+		{"", "09:00", ""},
+		{"2030-03-14", "", "Nowhere/Special"},
+	} {
+		if _, _, _, err := ParseDue(in[0], in[1], in[2]); !errors.Is(err, ErrInvalidDue) {
+			t.Errorf("ParseDue%q: expected ErrInvalidDue, got %v", in, err)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// 23:30 UTC on March 14th is already March 15th in Tokyo.
+	now := time.Date(2030, 3, 14, 23, 30, 0, 0, time.UTC)
+	dateOnly := func(date string) *ToDo {
+		var in ToDoInput
+		if err := in.SetDue(date, "", "UTC"); err != nil {
+			t.Fatal(err)
+		}
+		return &ToDo{DueAt: in.DueAt, DueTZ: in.DueTZ}
+	}
+	timed := func(at time.Time) *ToDo {
+		return &ToDo{DueAt: &at, DueHasTime: true, DueTZ: "UTC"}
+	}
+
+	cases := []struct {
+		name string
+		todo *ToDo
+		loc  *time.Location
+		want DueBucket
+	}{
+		{"none", &ToDo{}, time.UTC, DueNone},
+		{"yesterday", dateOnly("2030-03-13"), time.UTC, DueOverdue},
+		{"today", dateOnly("2030-03-14"), time.UTC, DueToday},
+		{"tomorrow", dateOnly("2030-03-15"), time.UTC, DueUpcoming},
+		{"today is tomorrow in Tokyo", dateOnly("2030-03-15"), tokyo, DueToday},
+		{"timed, passed an hour ago", timed(now.Add(-time.Hour)), time.UTC, DueOverdue},
+		{"timed, later today", timed(now.Add(20 * time.Minute)), time.UTC, DueToday},
+		{"timed, next week", timed(now.Add(7 * 24 * time.Hour)), time.UTC, DueUpcoming},
+	}
+	for _, tc := range cases {
+		if got := tc.todo.Bucket(now, tc.loc); got != tc.want {
+			t.Errorf("%s: Bucket = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Action says what an Event did to a todo.
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionComplete Action = "complete"
+	ActionReopen   Action = "reopen"
+	ActionDelete   Action = "delete" // moved to the trash
+	ActionRestore  Action = "restore"
+	ActionPurge    Action = "purge" // deleted for good
+)
+
+// SystemActor is the actor of changes nobody asked for, such as purging
+// the trash once its retention runs out.
+const SystemActor = "system"
+
+// Event records one change to a todo. Events are immutable: EventStore
+// can only add and list them.
+type Event struct {
+	ID     int `db:"id" json:"id"`
+	ToDoID int `db:"todo_id" json:"todo_id"`
+	// Username owns the todo; Actor made the change. They only differ for
+	// changes made by the system.
+	Username string `db:"username" json:"user"`
+	Actor    string `db:"actor" json:"actor"`
+	Action   Action `db:"action" json:"action"`
+	// Changes maps the fields that changed, named as in the JSON API, to
+	// their old and new values.
+	Changes Changes `db:"changes" json:"changes,omitempty"`
+	// RequestID ties the event to the request that caused it (see
+	// handlers.RequestID); empty for background jobs.
+	RequestID string    `db:"request_id" json:"request_id,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Change is a field's value before and after an event, "" when unset.
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Changes is stored as a JSON object.
+type Changes map[string]Change
+
+// Value implements driver.Valuer.
+func (c Changes) Value() (driver.Value, error) {
+	if c == nil {
+		c = Changes{}
+	}
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (c *Changes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("Changes: cannot scan %T", src)
+	}
+	var out Changes
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("Changes: %w", err)
+	}
+	if len(out) == 0 {
+		out = nil
+	}
+	*c = out
+	return nil
+}
+
+// EventFilter narrows EventStore.Events. Zero fields don't filter.
+type EventFilter struct {
+	Username string
+	ToDoID   int
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+	// BeforeID resumes a listing after the last event of the previous
+	// page.
+	BeforeID int
+	// Limit caps the result at MaxEvents, which is also the default.
+	Limit int
+}
+
+// MaxEvents is the most events EventStore.Events returns at once.
+const MaxEvents = 500
+
+// limit returns f.Limit clamped to 1..MaxEvents.
+func (f EventFilter) limit() int {
+	if f.Limit <= 0 || f.Limit > MaxEvents {
+		return MaxEvents
+	}
+	return f.Limit
+}
+
+// matches reports whether e passes f, other than its limit.
+func (f EventFilter) matches(e *Event) bool {
+	return (f.Username == "" || e.Username == f.Username) &&
+		(f.ToDoID == 0 || e.ToDoID == f.ToDoID) &&
+		(f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.CreatedAt.Before(f.Until)) &&
+		(f.BeforeID == 0 || e.ID < f.BeforeID)
+}
+
+// EventStore keeps the audit log.
+type EventStore interface {
+	// Record appends e, filling in its ID and CreatedAt.
+	Record(e *Event) error
+	// Events lists the events passing f, newest first.
+	Events(f EventFilter) ([]*Event, error)
+}
+
+// fields returns t's audited fields by their JSON API names.
+func (t *ToDo) fields() map[string]string {
+	return map[string]string{
+		"list_id":    strconv.Itoa(t.ListID),
+		"title":      t.Title,
+		"completed":  strconv.FormatBool(t.Completed),
+		"priority":   t.Priority.String(),
+		"due_date":   t.DueDate(),
+		"due_time":   t.DueTime(),
+		"due_tz":     t.DueTZ,
+		"recurrence": t.Recurrence,
+		"notes":      t.Notes,
+		"tags":       t.Tags.String(),
+		"position":   strconv.FormatFloat(t.Position, 'g', -1, 64),
+	}
+}
+
+// diff returns the fields that differ between before and after. A nil
+// before is a new todo: the fields of after that aren't at their zero
+// value count as changed from "".
+func diff(before, after *ToDo) Changes {
+	created := before == nil
+	if created {
+		before = &ToDo{}
+	}
+	from, to := before.fields(), after.fields()
+	var c Changes
+	for name, v := range to {
+		if v == from[name] {
+			continue
+		}
+		if c == nil {
+			c = Changes{}
+		}
+		if created {
+			c[name] = Change{To: v}
+		} else {
+			c[name] = Change{From: from[name], To: v}
+		}
+	}
+	return c
+}
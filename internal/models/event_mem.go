@@ -0,0 +1,51 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// EventStoreMemory implements EventStore in memory.
+type EventStoreMemory struct {
+	mu     sync.RWMutex
+	events []Event // in ID order
+}
+
+// NewEventStoreMemory returns an empty in-memory EventStore.
+func NewEventStoreMemory() *EventStoreMemory {
+	return &EventStoreMemory{}
+}
+
+func (s *EventStoreMemory) Record(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = len(s.events) + 1
+	e.CreatedAt = time.Now().UTC()
+	stored := *e
+	stored.Changes = make(Changes, len(e.Changes))
+	for k, v := range e.Changes {
+		stored.Changes[k] = v
+	}
+	if len(stored.Changes) == 0 {
+		stored.Changes = nil
+	}
+	s.events = append(s.events, stored)
+	return nil
+}
+
+// Events hands out copies, so the log itself can't be changed through
+// them. Changes maps are shared but never written after Record.
+func (s *EventStoreMemory) Events(f EventFilter) ([]*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := []*Event{}
+	for i := len(s.events) - 1; i >= 0 && len(out) < f.limit(); i-- {
+		if f.matches(&s.events[i]) {
+			e := s.events[i]
+			out = append(out, &e)
+		}
+	}
+	return out, nil
+}
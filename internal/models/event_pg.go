@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EventStorePostgres implements EventStore for a PostgreSQL backend. The
+// todo_events table refuses updates and deletes (see migration 0013).
+type EventStorePostgres struct {
+	db *sqlx.DB
+}
+
+func NewEventStorePostgres(db *sqlx.DB) *EventStorePostgres {
+	return &EventStorePostgres{db: db}
+}
+
+const eventColumns = `id, todo_id, username, actor, action, changes, request_id, created_at`
+
+func (s *EventStorePostgres) Record(e *Event) error {
+	return s.db.QueryRowx(
+		`INSERT INTO todo_events (todo_id, username, actor, action, changes, request_id)
+             VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING id, created_at`,
+		e.ToDoID, e.Username, e.Actor, e.Action, e.Changes, e.RequestID,
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (s *EventStorePostgres) Events(f EventFilter) ([]*Event, error) {
+	where := `WHERE TRUE`
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where += fmt.Sprintf(` AND `+cond, len(args))
+	}
+	if f.Username != "" {
+		add(`username = $%d`, f.Username)
+	}
+	if f.ToDoID != 0 {
+		add(`todo_id = $%d`, f.ToDoID)
+	}
+	if !f.Since.IsZero() {
+		add(`created_at >= $%d`, f.Since)
+	}
+	if !f.Until.IsZero() {
+		add(`created_at < $%d`, f.Until)
+	}
+	if f.BeforeID != 0 {
+		add(`id < $%d`, f.BeforeID)
+	}
+	events := []*Event{}
+	err := s.db.Select(
+		&events,
+		`SELECT `+eventColumns+`
+           FROM todo_events
+          `+where+`
+          ORDER BY id DESC
+          LIMIT `+fmt.Sprint(f.limit()),
+		args...,
+	)
+	return events, err
+}
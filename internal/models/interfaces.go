@@ -1,40 +1,241 @@
 package models
 
 import (
-    "errors"
-    "time"
-)	
+	"errors"
+	"time"
+)
 
 // ToDo is your database model for a single task.
 type ToDo struct {
-	ID        int       `db:"id" json:"id"`
-	Title     string    `db:"title" json:"title"`
-	Completed bool      `db:"completed" json:"completed"`
+	ID        int      `db:"id" json:"id"`
+	ListID    int      `db:"list_id" json:"list_id"`
+	Title     string   `db:"title" json:"title"`
+	Completed bool     `db:"completed" json:"completed"`
+	Priority  Priority `db:"priority" json:"priority"`
+	// DueAt is when the task is due. For date-only deadlines
+	// (DueHasTime == false) it is midnight of that date in DueTZ.
+	DueAt      *time.Time `db:"due_at" json:"due_at,omitempty"`
+	DueHasTime bool       `db:"due_has_time" json:"due_has_time"`
+	// DueTZ is the IANA zone the deadline was entered in.
+	DueTZ string `db:"due_tz" json:"due_tz,omitempty"`
+	// Recurrence is a rule such as "FREQ=WEEKLY;BYDAY=MO" (see package
+	// recur); completing a recurring todo creates its next occurrence,
+	// which takes the rule over.
+	Recurrence string `db:"recurrence" json:"recurrence,omitempty"`
+	// Notes is free-form Markdown shown on the todo's detail page; see
+	// NormalizeNotes.
+	Notes string `db:"notes" json:"notes"`
+	// Tags are the todo's tag names, sorted. They are changed with
+	// ToDoStore.Tag and Untag rather than through ToDoInput.
+	Tags StringList `db:"tags" json:"tags"`
+	// ItemsDone and ItemsTotal count the todo's checklist items; see
+	// ChecklistItem.
+	ItemsDone  int `db:"items_done" json:"items_done"`
+	ItemsTotal int `db:"items_total" json:"items_total"`
+	// Position is the todo's place in its list's manual order (see
+	// SortManual); only its rank among the other positions matters.
+	Position  float64   `db:"position" json:"position"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// DeletedAt is when the todo was moved to the trash; nil for todos
+	// that aren't in it. See ToDoStore.Trash.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// Version starts at 1 and goes up with every change to the todo's
+	// editable fields or tags; see ToDoInput.Version.
+	Version int `db:"version" json:"version"`
+	// Spawned is the next occurrence that completing the todo created, as
+	// returned by the Update or Bulk that completed it; nil otherwise. It
+	// isn't stored.
+	Spawned *ToDo `db:"-" json:"-"`
+}
+
+// PurgedToDo names a to-do that ToDoStore.PurgeDeleted removed.
+type PurgedToDo struct {
+	ID       int    `db:"id"`
+	Username string `db:"username"`
+}
+
+// ToDoInput holds the user-editable fields of a ToDo, as passed to
+// ToDoStore.Create and ToDoStore.Update.
+type ToDoInput struct {
+	// ListID is the list the todo belongs to; 0 means the user's Inbox.
+	ListID     int
+	Title      string
+	Completed  bool
+	Priority   Priority
+	DueAt      *time.Time
+	DueHasTime bool
+	DueTZ      string
+	Recurrence string
+	Notes      string
+	// Version, unless 0, makes Update fail with ErrConflict when the todo
+	// is no longer at that version, i.e. someone else changed it since it
+	// was read. Input leaves it 0.
+	Version int
+}
+
+// Input returns the todo's current editable fields, ready to be modified
+// and passed back to Update.
+func (t *ToDo) Input() ToDoInput {
+	return ToDoInput{
+		ListID:     t.ListID,
+		Title:      t.Title,
+		Completed:  t.Completed,
+		Priority:   t.Priority,
+		DueAt:      t.DueAt,
+		DueHasTime: t.DueHasTime,
+		DueTZ:      t.DueTZ,
+		Recurrence: t.Recurrence,
+		Notes:      t.Notes,
+	}
 }
 
 // ErrNotFound is returned when a to-do item doesn’t exist.
 var ErrNotFound = errors.New("todo not found")
 
+// ErrConflict is returned by ToDoStore.Update when the todo has changed
+// since the version the update was based on.
+var ErrConflict = errors.New("todo was changed by someone else")
+
+// ErrUserExists is returned by UserStore.Create when the username is taken.
+var ErrUserExists = errors.New("user already exists")
+
+// ErrInvalidToken is returned when an API token is unknown, revoked or expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Token describes a personal API token. The secret itself is only ever
+// returned once, by TokenStore.Create; stores keep just its hash.
+type Token struct {
+	ID         int        `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+}
+
+// Expired reports whether the token's expiry has passed at now.
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
 // UserStore abstracts how we create/authenticate users.
 type UserStore interface {
 	Create(username, password string) error
 	Authenticate(username, password string) bool
 }
 
+// TokenStore manages personal API tokens for non-browser clients.
+type TokenStore interface {
+	// Create issues a new token; expiresAt may be nil for no expiry.
+	// The returned secret cannot be recovered later.
+	Create(username, name string, expiresAt *time.Time) (secret string, token *Token, err error)
+	// List a user's tokens, oldest first.
+	List(username string) ([]*Token, error)
+	// Revoke deletes one of the user's tokens.
+	Revoke(id int, username string) error
+	// Authenticate resolves a secret to its owner and records the use.
+	Authenticate(secret string) (username string, err error)
+}
+
 // ToDoStore abstracts how we CRUD todos for a given user.
 type ToDoStore interface {
-	// Fetch all to-dos for this user.
-	GetAll(username string) ([]*ToDo, error)
+	// Fetch all to-dos for this user, ordered as opts asks.
+	GetAll(username string, opts ListOptions) ([]*ToDo, error)
+	// GetPage returns up to limit (see DefaultPageSize and MaxPageSize)
+	// of the user's to-dos that pass opts' filters, in opts' order,
+	// resuming after cursor ("" for the first page). Page.Next continues
+	// the listing.
+	GetPage(username string, opts ListOptions, limit int, cursor string) (*Page, error)
+	// Search finds the user's to-dos, completed ones included, whose title
+	// or notes contain every word of query (as a word prefix), best matches
+	// first, at most SearchLimit of them. opts' list and tag filters apply;
+	// its sort order doesn't.
+	Search(username, query string, opts ListOptions) ([]*ToDo, error)
 	// Fetch one to-do by ID and user.
 	Get(id int, username string) (*ToDo, error)
 	// Create a new to-do.
-	Create(username, title string) (*ToDo, error)
-	// Update title/completed flag.
-	Update(id int, title string, completed bool, username string) (*ToDo, error)
-	// Delete one to-do.
+	Create(username string, in ToDoInput) (*ToDo, error)
+	// Update replaces every editable field with the values in in,
+	// provided the todo is still at in.Version (if set). Completing a
+	// todo checks all of its checklist items and, if it repeats, creates
+	// its next occurrence in the same go (see ToDo.Spawned).
+	Update(id int, in ToDoInput, username string) (*ToDo, error)
+	// Delete moves one to-do to the trash. Trashed to-dos are left out of
+	// every other method until restored.
 	Delete(id int, username string) error
-	// Remove all completed items.
-	ClearCompleted(username string) error
+	// Move puts a to-do in its list's manual order directly after the
+	// to-do afterID and/or directly before the to-do beforeID; 0 leaves
+	// that side open. The anchors must be other to-dos in the same list.
+	Move(id int, username string, beforeID, afterID int) (*ToDo, error)
+	// Move all completed items of one list, or of every list when listID
+	// is 0, to the trash.
+	ClearCompleted(username string, listID int) error
+	// Bulk makes one change to several of the user's to-dos at once, all
+	// or nothing: if any of ids is missing, none change. It returns the
+	// to-dos it changed, as they are now, by ID, and in before the same
+	// to-dos as they were just before; those already as asked are left
+	// alone. Completing spawns next occurrences as Update does.
+	Bulk(username string, ids []int, a BulkAction) (changed, before []*ToDo, err error)
+
+	// Trash lists the user's deleted to-dos, most recently deleted first.
+	Trash(username string) ([]*ToDo, error)
+	// Restore takes a to-do out of the trash, back where it was.
+	Restore(id int, username string) (*ToDo, error)
+	// Purge permanently deletes a to-do in the trash, with its tags and
+	// checklist items.
+	Purge(id int, username string) error
+	// PurgeDeleted permanently deletes every user's to-dos that were moved
+	// to the trash before cutoff and returns which they were.
+	PurgeDeleted(cutoff time.Time) ([]PurgedToDo, error)
+
+	// Tag adds tags to one of the user's to-dos, normalizing the names
+	// with NormalizeTags. Tags it already has are ignored.
+	Tag(id int, username string, tags ...string) error
+	// Untag removes tags from one of the user's to-dos.
+	Untag(id int, username string, tags ...string) error
+	// Tags lists the tags on the user's to-dos, by name, with how many
+	// to-dos carry each.
+	Tags(username string) ([]TagCount, error)
+
+	// Items lists a to-do's checklist items in order.
+	Items(todoID int, username string) ([]*ChecklistItem, error)
+	// AddItem appends an item to a to-do's checklist, reopening the to-do
+	// if it was completed.
+	AddItem(todoID int, username, title string) (*ChecklistItem, error)
+	// SetItemDone checks or unchecks an item. Unchecking an item of a
+	// completed to-do reopens it.
+	SetItemDone(todoID, itemID int, username string, done bool) (*ChecklistItem, error)
+	// DeleteItem removes an item from a to-do's checklist.
+	DeleteItem(todoID, itemID int, username string) error
+
+	// Lists returns all the user's lists, archived ones included: the
+	// Inbox first, then the rest by name.
+	Lists(username string) ([]*List, error)
+	// GetList fetches one of the user's lists.
+	GetList(id int, username string) (*List, error)
+	// Inbox returns the user's Inbox, creating it on first use.
+	Inbox(username string) (*List, error)
+	// CreateList adds a list; the name must be unique for the user.
+	CreateList(username, name string) (*List, error)
+	// RenameList renames one of the user's lists.
+	RenameList(id int, username, name string) (*List, error)
+	// ArchiveList archives (or, with archived false, restores) a list.
+	ArchiveList(id int, username string, archived bool) (*List, error)
+	// DeleteList deletes a list together with all of its to-dos.
+	DeleteList(id int, username string) error
 }
+
+// Compile-time checks that every backend satisfies the interfaces.
+var (
+	_ ToDoStore = (*StorePostgres)(nil)
+	_ ToDoStore = (*StoreMemory)(nil)
+	_ UserStore = (*UserStorePostgres)(nil)
+	_ UserStore = (*UserStoreMemory)(nil)
+
+	_ TokenStore = (*TokenStorePostgres)(nil)
+	_ TokenStore = (*TokenStoreMemory)(nil)
+
+	_ ToDoStore  = (*AuditedStore)(nil)
+	_ EventStore = (*EventStorePostgres)(nil)
+	_ EventStore = (*EventStoreMemory)(nil)
+)
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSort is returned by ParseSort for unknown sort orders.
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// SortOrder selects how GetAll orders a user's todos.
+type SortOrder string
+
+const (
+	// SortManual keeps the order the user arranged with ToDoStore.Move;
+	// new todos go last. It is the default.
+	SortManual SortOrder = "manual"
+	// SortPriority puts the most urgent first, then the earliest deadline
+	// (todos without one last), then the oldest.
+	SortPriority SortOrder = "priority"
+	// SortDue puts the earliest deadline first, then priority, then age.
+	SortDue SortOrder = "due"
+	// SortCreated lists todos oldest first.
+	SortCreated SortOrder = "created"
+)
+
+// ParseSort validates a sort order from a query string. An empty string
+// selects the default.
+func ParseSort(s string) (SortOrder, error) {
+	switch o := SortOrder(s); o {
+	case "":
+		return SortManual, nil
+	case SortManual, SortPriority, SortDue, SortCreated:
+		return o, nil
+	}
+	return "", fmt.Errorf("%w %q (want manual, priority, due or created)", ErrInvalidSort, s)
+}
+
+// ListOptions controls which todos GetAll returns and in what order.
+type ListOptions struct {
+	// ListID restricts the result to one list; 0 means every list.
+	ListID int
+	Sort   SortOrder
+	// Tags, when set, keeps only todos carrying any (or, with TagMatchAll,
+	// every one) of these normalized tag names.
+	Tags     []string
+	TagMatch TagMatch
+	// Status keeps only active or only completed todos.
+	Status Status
+}
+
+// sortOrder returns o.Sort, or the default when it is unset.
+func (o ListOptions) sortOrder() SortOrder {
+	if o.Sort == "" {
+		return SortManual
+	}
+	return o.Sort
+}
+
+// matches reports whether t passes o's list, status and tag filters.
+func (o ListOptions) matches(t *ToDo) bool {
+	if o.ListID != 0 && t.ListID != o.ListID {
+		return false
+	}
+	if o.Status != StatusAll && t.Completed != (o.Status == StatusCompleted) {
+		return false
+	}
+	return o.matchesTags(t)
+}
+
+// less reports whether a sorts before b under o. It mirrors the ORDER BY
+// clauses in todo_pg.go so every backend agrees.
+func (o ListOptions) less(a, b *ToDo) bool {
+	byPriority := func() (bool, bool) {
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority, true
+		}
+		return false, false
+	}
+	byDue := func() (bool, bool) {
+		switch {
+		case a.DueAt == nil && b.DueAt == nil:
+			return false, false
+		case a.DueAt == nil || b.DueAt == nil:
+			return b.DueAt == nil, true // no deadline sorts last
+		case !a.DueAt.Equal(*b.DueAt):
+			return a.DueAt.Before(*b.DueAt), true
+		}
+		return false, false
+	}
+
+	byPosition := func() (bool, bool) {
+		if a.Position != b.Position {
+			return a.Position < b.Position, true
+		}
+		return false, false
+	}
+
+	var keys []func() (bool, bool)
+	switch o.sortOrder() {
+	case SortManual:
+		keys = []func() (bool, bool){byPosition}
+	case SortPriority:
+		keys = []func() (bool, bool){byPriority, byDue}
+	case SortDue:
+		keys = []func() (bool, bool){byDue, byPriority}
+	}
+	for _, key := range keys {
+		if less, decided := key(); decided {
+			return less
+		}
+	}
+	return a.ID < b.ID
+}
+
+// orderBy returns the SQL ORDER BY clause for o.
+func (o ListOptions) orderBy() string {
+	switch o.sortOrder() {
+	case SortManual:
+		return `ORDER BY position, id`
+	case SortDue:
+		return `ORDER BY due_at ASC NULLS LAST, priority DESC, id`
+	case SortCreated:
+		return `ORDER BY id`
+	default: // SortPriority
+		return `ORDER BY priority DESC, due_at ASC NULLS LAST, id`
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrListNotFound is returned when a list doesn't exist or belongs to
+	// someone else.
+	ErrListNotFound = errors.New("list not found")
+	// ErrListExists is returned when a user already has a list by that name.
+	ErrListExists = errors.New("a list with that name already exists")
+	// ErrInvalidList is returned for bad list names and for trying to
+	// archive or delete the Inbox.
+	ErrInvalidList = errors.New("invalid list")
+)
+
+// InboxName is the name of the list every user starts with. New todos go
+// there unless another list is chosen.
+const InboxName = "Inbox"
+
+// MaxListNameLen is the longest list name, in characters.
+const MaxListNameLen = 64
+
+// List is a named group of todos (a project). Every todo belongs to
+// exactly one list.
+type List struct {
+	ID         int        `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Inbox      bool       `db:"is_inbox" json:"inbox"`
+	ArchivedAt *time.Time `db:"archived_at" json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	// Open is how many of the list's todos are not completed.
+	Open int `db:"open" json:"open"`
+}
+
+// Archived reports whether the list has been archived.
+func (l *List) Archived() bool { return l.ArchivedAt != nil }
+
+// NormalizeListName trims name and checks it is usable for a new or renamed
+// list. The Inbox name is reserved.
+func NormalizeListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", fmt.Errorf("%w: name cannot be empty", ErrInvalidList)
+	case utf8.RuneCountInString(name) > MaxListNameLen:
+		return "", fmt.Errorf("%w: name must be at most %d characters", ErrInvalidList, MaxListNameLen)
+	case strings.EqualFold(name, InboxName):
+		return "", fmt.Errorf("%w: %q is reserved", ErrInvalidList, InboxName)
+	}
+	return name, nil
+}
+
+// errInbox is returned when trying to archive or delete the Inbox.
+var errInbox = fmt.Errorf("%w: the %s cannot be archived or deleted", ErrInvalidList, InboxName)
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// memList pairs a stored List with the user that owns it. Open is computed
+// on the way out.
+type memList struct {
+	owner string
+	list  List
+}
+
+// resolveList maps ListID 0 to the user's Inbox and checks any other ID
+// belongs to them. Callers must hold mu for writing.
+func (s *StoreMemory) resolveList(username string, listID int) (int, error) {
+	if listID == 0 {
+		return s.inbox(username).list.ID, nil
+	}
+	if _, err := s.getList(listID, username); err != nil {
+		return 0, err
+	}
+	return listID, nil
+}
+
+// inbox returns the user's Inbox, creating it if needed. Callers must hold
+// mu for writing.
+func (s *StoreMemory) inbox(username string) *memList {
+	for _, l := range s.lists {
+		if l.owner == username && l.list.Inbox {
+			return l
+		}
+	}
+	return s.addList(username, InboxName, true)
+}
+
+// addList stores a new list. Callers must hold mu for writing.
+func (s *StoreMemory) addList(username, name string, inbox bool) *memList {
+	l := &memList{
+		owner: username,
+		list: List{
+			ID:        s.nextListID,
+			Name:      name,
+			Inbox:     inbox,
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+	s.lists[l.list.ID] = l
+	s.nextListID++
+	return l
+}
+
+// getList returns the entry for id if it belongs to username. Callers must
+// hold mu.
+func (s *StoreMemory) getList(id int, username string) (*memList, error) {
+	l, ok := s.lists[id]
+	if !ok || l.owner != username {
+		return nil, ErrListNotFound
+	}
+	return l, nil
+}
+
+// nameTaken reports whether username has a list called name, other than
+// the one with ID except. Callers must hold mu.
+func (s *StoreMemory) nameTaken(username, name string, except int) bool {
+	for id, l := range s.lists {
+		if id != except && l.owner == username && strings.EqualFold(l.list.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// listCopy returns a copy of l with Open filled in. Callers must hold mu.
+func (s *StoreMemory) listCopy(l *memList) *List {
+	out := l.list
+	for _, e := range s.todos {
+		if e.todo.ListID == out.ID && !e.todo.Completed {
+			out.Open++
+		}
+	}
+	return &out
+}
+
+func (s *StoreMemory) Lists(username string) ([]*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inbox(username)
+	lists := []*List{}
+	for _, l := range s.lists {
+		if l.owner == username {
+			lists = append(lists, s.listCopy(l))
+		}
+	}
+	sortLists(lists)
+	return lists, nil
+}
+
+func (s *StoreMemory) GetList(id int, username string) (*List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, err := s.getList(id, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.listCopy(l), nil
+}
+
+func (s *StoreMemory) Inbox(username string) (*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listCopy(s.inbox(username)), nil
+}
+
+func (s *StoreMemory) CreateList(username, name string) (*List, error) {
+	name, err := NormalizeListName(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inbox(username)
+	if s.nameTaken(username, name, 0) {
+		return nil, ErrListExists
+	}
+	return s.listCopy(s.addList(username, name, false)), nil
+}
+
+func (s *StoreMemory) RenameList(id int, username, name string) (*List, error) {
+	name, err := NormalizeListName(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.getList(id, username)
+	if err != nil {
+		return nil, err
+	}
+	if l.list.Inbox {
+		return nil, errInbox
+	}
+	if s.nameTaken(username, name, id) {
+		return nil, ErrListExists
+	}
+	l.list.Name = name
+	return s.listCopy(l), nil
+}
+
+func (s *StoreMemory) ArchiveList(id int, username string, archived bool) (*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.getList(id, username)
+	if err != nil {
+		return nil, err
+	}
+	if l.list.Inbox {
+		return nil, errInbox
+	}
+	switch {
+	case archived && l.list.ArchivedAt == nil:
+		now := time.Now().UTC()
+		l.list.ArchivedAt = &now
+	case !archived:
+		l.list.ArchivedAt = nil
+	}
+	return s.listCopy(l), nil
+}
+
+func (s *StoreMemory) DeleteList(id int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.getList(id, username)
+	if err != nil {
+		return err
+	}
+	if l.list.Inbox {
+		return errInbox
+	}
+	for _, todos := range []map[int]*memToDo{s.todos, s.trash} {
+		for todoID, e := range todos {
+			if e.todo.ListID == id {
+				s.drop(todoID)
+			}
+		}
+	}
+	delete(s.lists, id)
+	return nil
+}
+
+// sortLists orders lists the way Lists promises: Inbox first, then by name.
+func sortLists(lists []*List) {
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Inbox != lists[j].Inbox {
+			return lists[i].Inbox
+		}
+		return strings.ToLower(lists[i].Name) < strings.ToLower(lists[j].Name)
+	})
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// listColumns is the select list every query scans into a List.
+const listColumns = `l.id, l.name, l.is_inbox, l.archived_at, l.created_at,
+       (SELECT COUNT(*) FROM todos t WHERE t.list_id = l.id AND NOT t.completed AND t.deleted_at IS NULL) AS open`
+
+// listNotFound maps sql.ErrNoRows onto ErrListNotFound.
+func listNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrListNotFound
+	}
+	return err
+}
+
+// listExists maps a unique violation on the list name onto ErrListExists.
+func listExists(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return ErrListExists
+	}
+	return err
+}
+
+// resolveList maps ListID 0 to the user's Inbox and checks any other ID
+// belongs to them.
+func (s *StorePostgres) resolveList(username string, listID int) (int, error) {
+	if listID == 0 {
+		inbox, err := s.Inbox(username)
+		if err != nil {
+			return 0, err
+		}
+		return inbox.ID, nil
+	}
+	var id int
+	err := s.db.Get(&id, `SELECT id FROM lists WHERE id = $1 AND username = $2`, listID, username)
+	return id, listNotFound(err)
+}
+
+func (s *StorePostgres) Lists(username string) ([]*List, error) {
+	if _, err := s.Inbox(username); err != nil {
+		return nil, err
+	}
+	lists := []*List{}
+	err := s.db.Select(
+		&lists,
+		`SELECT `+listColumns+`
+           FROM lists l
+          WHERE l.username = $1
+          ORDER BY l.is_inbox DESC, lower(l.name)`,
+		username,
+	)
+	return lists, err
+}
+
+func (s *StorePostgres) GetList(id int, username string) (*List, error) {
+	var l List
+	err := s.db.Get(
+		&l,
+		`SELECT `+listColumns+`
+           FROM lists l
+          WHERE l.id = $1 AND l.username = $2`,
+		id, username,
+	)
+	if err != nil {
+		return nil, listNotFound(err)
+	}
+	return &l, nil
+}
+
+func (s *StorePostgres) Inbox(username string) (*List, error) {
+	l, err := s.inbox(username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return l, err
+	}
+	// First use. The partial unique index on (username) WHERE is_inbox
+	// makes a concurrent first request's insert a no-op, so read again
+	// rather than trust which one won.
+	if _, err := s.db.Exec(
+		`INSERT INTO lists (username, name, is_inbox)
+         VALUES ($1, $2, TRUE)
+             ON CONFLICT DO NOTHING`,
+		username, InboxName,
+	); err != nil {
+		return nil, err
+	}
+	return s.inbox(username)
+}
+
+// inbox reads username's Inbox, or returns sql.ErrNoRows if it hasn't been
+// created yet.
+func (s *StorePostgres) inbox(username string) (*List, error) {
+	var l List
+	err := s.db.Get(
+		&l,
+		`SELECT `+listColumns+`
+           FROM lists l
+          WHERE l.username = $1 AND l.is_inbox`,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *StorePostgres) CreateList(username, name string) (*List, error) {
+	name, err := NormalizeListName(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Inbox(username); err != nil {
+		return nil, err
+	}
+	var id int
+	err = s.db.Get(
+		&id,
+		`INSERT INTO lists (username, name) VALUES ($1, $2) RETURNING id`,
+		username, name,
+	)
+	if err != nil {
+		return nil, listExists(err)
+	}
+	return s.GetList(id, username)
+}
+
+// mutableList fetches a list that may be renamed, archived or deleted,
+// i.e. one of the user's lists other than the Inbox.
+func (s *StorePostgres) mutableList(id int, username string) error {
+	l, err := s.GetList(id, username)
+	if err != nil {
+		return err
+	}
+	if l.Inbox {
+		return errInbox
+	}
+	return nil
+}
+
+func (s *StorePostgres) RenameList(id int, username, name string) (*List, error) {
+	name, err := NormalizeListName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mutableList(id, username); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(
+		`UPDATE lists SET name = $1 WHERE id = $2 AND username = $3`,
+		name, id, username,
+	); err != nil {
+		return nil, listExists(err)
+	}
+	return s.GetList(id, username)
+}
+
+func (s *StorePostgres) ArchiveList(id int, username string, archived bool) (*List, error) {
+	if err := s.mutableList(id, username); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(
+		`UPDATE lists
+            SET archived_at = CASE WHEN $1 THEN COALESCE(archived_at, NOW()) END
+          WHERE id = $2 AND username = $3`,
+		archived, id, username,
+	); err != nil {
+		return nil, err
+	}
+	return s.GetList(id, username)
+}
+
+func (s *StorePostgres) DeleteList(id int, username string) error {
+	if err := s.mutableList(id, username); err != nil {
+		return err
+	}
+	// todos.list_id cascades, taking the list's todos (and their tags) along.
+	_, err := s.db.Exec(
+		`DELETE FROM lists WHERE id = $1 AND username = $2 AND NOT is_inbox`,
+		id, username,
+	)
+	return err
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidNotes is returned for notes longer than MaxNotesLen.
+var ErrInvalidNotes = errors.New("invalid notes")
+
+// MaxNotesLen is the longest a todo's notes may be, in characters.
+const MaxNotesLen = 20000
+
+// NormalizeNotes tidies notes as submitted from a form or the API: line
+// endings become "\n" and surrounding blank space is dropped. Notes are
+// Markdown; they are rendered by package markdown and stored as typed.
+func NormalizeNotes(notes string) (string, error) {
+	notes = strings.ReplaceAll(notes, "\r\n", "\n")
+	notes = strings.TrimSpace(notes)
+	if utf8.RuneCountInString(notes) > MaxNotesLen {
+		return "", fmt.Errorf("%w: notes must be at most %d characters", ErrInvalidNotes, MaxNotesLen)
+	}
+	return notes, nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidStatus is returned by ParseStatus for unknown filters.
+	ErrInvalidStatus = errors.New("invalid status")
+	// ErrInvalidCursor is returned by GetPage for cursors it didn't issue,
+	// or issued for a different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Status filters todos by whether they are completed.
+type Status string
+
+const (
+	StatusAll       Status = ""
+	StatusActive    Status = "active"
+	StatusCompleted Status = "completed"
+)
+
+// ParseStatus validates a status filter from a query string. An empty
+// string (or "all") keeps every todo.
+func ParseStatus(s string) (Status, error) {
+	switch st := Status(s); st {
+	case StatusAll, "all":
+		return StatusAll, nil
+	case StatusActive, StatusCompleted:
+		return st, nil
+	}
+	return "", fmt.Errorf("%w %q (want active, completed or all)", ErrInvalidStatus, s)
+}
+
+// DefaultPageSize and MaxPageSize bound GetPage's limit.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Page is one page of a GetPage listing.
+type Page struct {
+	Todos []*ToDo
+	// Next is the cursor for the following page, or "" on the last page.
+	Next string
+}
+
+// pageLimit applies GetPage's default and maximum to limit.
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultPageSize
+	case limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return limit
+}
+
+// farFuture stands in for a missing deadline in cursors and keyset
+// comparisons, so todos without one sort last as ListOptions.less has it.
+var farFuture = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// cursor is the sort key of the last todo on a page: listing resumes with
+// the todos that sort after it. Cursors are opaque to clients.
+type cursor struct {
+	Sort     SortOrder  `json:"s"`
+	Priority Priority   `json:"p,omitempty"`
+	Due      *time.Time `json:"d,omitempty"`
+	Position float64    `json:"o,omitempty"`
+	ID       int        `json:"i"`
+}
+
+// cursorAfter returns the cursor that resumes listing after t.
+func cursorAfter(t *ToDo, sort SortOrder) string {
+	b, _ := json.Marshal(cursor{Sort: sort, Priority: t.Priority, Due: t.DueAt, Position: t.Position, ID: t.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseCursor decodes s, which must have been issued for sort.
+func parseCursor(s string, sort SortOrder) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.ID <= 0 {
+		return c, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	if c.Sort != sort {
+		return c, fmt.Errorf("%w: issued for sort=%s, not %s", ErrInvalidCursor, c.Sort, sort)
+	}
+	return c, nil
+}
+
+// due is the cursor's deadline, with farFuture for none.
+func (c cursor) due() time.Time {
+	if c.Due == nil {
+		return farFuture
+	}
+	return *c.Due
+}
+
+// todo returns a ToDo carrying just the cursor's sort key, for comparing
+// with ListOptions.less.
+func (c cursor) todo() *ToDo {
+	return &ToDo{ID: c.ID, Priority: c.Priority, DueAt: c.Due, Position: c.Position}
+}
+
+// page trims todos, fetched with one extra row, to limit and fills in the
+// cursor for the next page.
+func page(todos []*ToDo, limit int, sort SortOrder) *Page {
+	p := &Page{Todos: todos}
+	if len(todos) > limit {
+		p.Todos = todos[:limit]
+		p.Next = cursorAfter(p.Todos[limit-1], sort)
+	}
+	return p
+}
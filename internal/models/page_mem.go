@@ -0,0 +1,28 @@
+package models
+
+func (s *StoreMemory) GetPage(username string, opts ListOptions, limit int, cur string) (*Page, error) {
+	var after *ToDo
+	if cur != "" {
+		c, err := parseCursor(cur, opts.sortOrder())
+		if err != nil {
+			return nil, err
+		}
+		after = c.todo()
+	}
+	all, err := s.GetAll(username, opts)
+	if err != nil {
+		return nil, err
+	}
+	limit = pageLimit(limit)
+	todos := []*ToDo{}
+	for _, t := range all {
+		if after != nil && !opts.less(after, t) {
+			continue
+		}
+		todos = append(todos, t)
+		if len(todos) > limit {
+			break
+		}
+	}
+	return page(todos, limit, opts.sortOrder()), nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import "fmt"
+
+// keysetAfter returns the condition selecting the rows that sort after c
+// under o, with its arguments numbered from $n+1. Each order is written as
+// an ascending row comparison matching orderBy.
+func (o ListOptions) keysetAfter(c cursor, n int) (string, []interface{}) {
+	switch o.sortOrder() {
+	case SortManual:
+		return fmt.Sprintf(`(position, id) > ($%d, $%d)`, n+1, n+2), []interface{}{c.Position, c.ID}
+	case SortCreated:
+		return fmt.Sprintf(`id > $%d`, n+1), []interface{}{c.ID}
+	case SortDue:
+		return fmt.Sprintf(`(COALESCE(due_at, $%d), -priority, id) > ($%d, $%d, $%d)`, n+1, n+2, n+3, n+4),
+			[]interface{}{farFuture, c.due(), -int(c.Priority), c.ID}
+	default: // SortPriority
+		return fmt.Sprintf(`(-priority, COALESCE(due_at, $%d), id) > ($%d, $%d, $%d)`, n+1, n+2, n+3, n+4),
+			[]interface{}{farFuture, -int(c.Priority), c.due(), c.ID}
+	}
+}
+
+func (s *StorePostgres) GetPage(username string, opts ListOptions, limit int, cur string) (*Page, error) {
+	where, args, err := listWhere(username, opts)
+	if err != nil {
+		return nil, err
+	}
+	if cur != "" {
+		c, err := parseCursor(cur, opts.sortOrder())
+		if err != nil {
+			return nil, err
+		}
+		cond, more := opts.keysetAfter(c, len(args))
+		where += ` AND ` + cond
+		args = append(args, more...)
+	}
+	limit = pageLimit(limit)
+	todos := []*ToDo{}
+	err = s.db.Select(
+		&todos,
+		`SELECT `+todoColumns+`
+           FROM todos
+          `+where+`
+          `+opts.orderBy()+`
+          LIMIT `+fmt.Sprint(limit+1),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return page(todos, limit, opts.sortOrder()), nil
+}
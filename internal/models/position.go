@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInvalidMove is returned by Move when it has no anchor, or an anchor is
+// the todo itself or sits in another list.
+var ErrInvalidMove = errors.New("invalid move")
+
+// Todos are kept in manual order by a fractional Position: a new todo goes
+// after the last one in its list, and Move drops a todo halfway between its new
+// neighbours, so a move only rewrites the moved row. When repeated moves
+// into the same gap exhaust float precision, the stores renumber the
+// user's todos 1, 2, 3… and try again.
+
+// between returns a position strictly between lo and hi, either of which
+// may be nil for an open end. It reports false when no float fits.
+func between(lo, hi *float64) (float64, bool) {
+	var p float64
+	switch {
+	case lo == nil && hi == nil:
+		return 0, false
+	case lo == nil:
+		p = *hi - 1
+	case hi == nil:
+		p = *lo + 1
+	default:
+		p = *lo + (*hi-*lo)/2
+	}
+	if (lo != nil && p <= *lo) || (hi != nil && p >= *hi) || math.IsInf(p, 0) {
+		return 0, false
+	}
+	return p, true
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// lastPosition returns the highest position among username's todos in
+// list listID, or 0. Callers must hold mu.
+func (s *StoreMemory) lastPosition(username string, listID int) float64 {
+	var last float64
+	for _, e := range s.todos {
+		if e.owner == username && e.todo.ListID == listID && e.todo.Position > last {
+			last = e.todo.Position
+		}
+	}
+	return last
+}
+
+// renumber spaces username's todos out to positions 1, 2, 3… keeping their
+// order. Callers must hold mu.
+func (s *StoreMemory) renumber(username string) {
+	var mine []*memToDo
+	for _, e := range s.todos {
+		if e.owner == username {
+			mine = append(mine, e)
+		}
+	}
+	sort.Slice(mine, func(i, j int) bool {
+		a, b := &mine[i].todo, &mine[j].todo
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.ID < b.ID
+	})
+	for i, e := range mine {
+		e.todo.Position = float64(i + 1)
+	}
+}
+
+// neighbours returns the positions Move must fit e between. With a single
+// anchor the other bound is that anchor's current neighbour in the list.
+// Callers must hold mu.
+func (s *StoreMemory) neighbours(e *memToDo, username string, beforeID, afterID int) (lo, hi *float64, err error) {
+	anchor := func(id int) (*float64, error) {
+		a, err := s.get(id, username)
+		if err != nil {
+			return nil, err
+		}
+		if a == e || a.todo.ListID != e.todo.ListID {
+			return nil, fmt.Errorf("%w: todo %d is not another todo in the same list", ErrInvalidMove, id)
+		}
+		p := a.todo.Position
+		return &p, nil
+	}
+	if afterID != 0 {
+		if lo, err = anchor(afterID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if beforeID != 0 {
+		if hi, err = anchor(beforeID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if lo != nil && hi != nil {
+		return lo, hi, nil
+	}
+	var other *float64
+	for _, o := range s.todos {
+		if o == e || o.owner != username || o.todo.ListID != e.todo.ListID {
+			continue
+		}
+		p := o.todo.Position
+		if hi == nil && p > *lo && (other == nil || p < *other) ||
+			lo == nil && p < *hi && (other == nil || p > *other) {
+			other = &p
+		}
+	}
+	if hi == nil {
+		hi = other
+	} else {
+		lo = other
+	}
+	return lo, hi, nil
+}
+
+func (s *StoreMemory) Move(id int, username string, beforeID, afterID int) (*ToDo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return nil, err
+	}
+	if beforeID == 0 && afterID == 0 {
+		return nil, fmt.Errorf("%w: give a todo to move before or after", ErrInvalidMove)
+	}
+	for renumbered := false; ; renumbered = true {
+		lo, hi, err := s.neighbours(e, username, beforeID, afterID)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := between(lo, hi); ok {
+			e.todo.Position = p
+			t := e.todo
+			return &t, nil
+		}
+		if renumbered {
+			return nil, fmt.Errorf("%w: todo %d does not come before todo %d", ErrInvalidMove, afterID, beforeID)
+		}
+		s.renumber(username)
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// pgNeighbours returns the positions Move must fit todo id (in list listID)
+// between. With a single anchor the other bound is that anchor's current
+// neighbour in the list.
+func pgNeighbours(q sqlx.Queryer, id, listID int, username string, beforeID, afterID int) (lo, hi *float64, err error) {
+	anchor := func(aid int) (*float64, error) {
+		var a struct {
+			ListID   int     `db:"list_id"`
+			Position float64 `db:"position"`
+		}
+		err := sqlx.Get(q, &a, `SELECT list_id, position FROM todos WHERE id = $1 AND username = $2 AND deleted_at IS NULL`, aid, username)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		if aid == id || a.ListID != listID {
+			return nil, fmt.Errorf("%w: todo %d is not another todo in the same list", ErrInvalidMove, aid)
+		}
+		return &a.Position, nil
+	}
+	if afterID != 0 {
+		if lo, err = anchor(afterID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if beforeID != 0 {
+		if hi, err = anchor(beforeID); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch {
+	case hi == nil:
+		err = sqlx.Get(q, &hi,
+			`SELECT MIN(position) FROM todos
+              WHERE username = $1 AND list_id = $2 AND id <> $3 AND position > $4 AND deleted_at IS NULL`,
+			username, listID, id, *lo)
+	case lo == nil:
+		err = sqlx.Get(q, &lo,
+			`SELECT MAX(position) FROM todos
+              WHERE username = $1 AND list_id = $2 AND id <> $3 AND position < $4 AND deleted_at IS NULL`,
+			username, listID, id, *hi)
+	}
+	return lo, hi, err // MIN and MAX give NULL, so nil, past the ends
+}
+
+func (s *StorePostgres) Move(id int, username string, beforeID, afterID int) (*ToDo, error) {
+	if beforeID == 0 && afterID == 0 {
+		return nil, fmt.Errorf("%w: give a todo to move before or after", ErrInvalidMove)
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var listID int
+	err = tx.Get(&listID, `SELECT list_id FROM todos WHERE id = $1 AND username = $2 AND deleted_at IS NULL FOR UPDATE`, id, username)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	for renumbered := false; ; renumbered = true {
+		lo, hi, err := pgNeighbours(tx, id, listID, username, beforeID, afterID)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := between(lo, hi); ok {
+			var t ToDo
+			err := tx.Get(&t, `UPDATE todos SET position = $1 WHERE id = $2 RETURNING `+todoColumns, p, id)
+			if err != nil {
+				return nil, err
+			}
+			return &t, tx.Commit()
+		}
+		if renumbered {
+			return nil, fmt.Errorf("%w: todo %d does not come before todo %d", ErrInvalidMove, afterID, beforeID)
+		}
+		// The gap is too narrow to split; space everything out and retry.
+		if _, err := tx.Exec(
+			`UPDATE todos t
+                SET position = r.n
+               FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS n
+                       FROM todos
+                      WHERE username = $1) r
+              WHERE t.id = r.id`,
+			username,
+		); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPriority is returned when a priority name or number is unknown.
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// Priority ranks how urgent a todo is. The zero value means no priority;
+// higher values sort first.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = [...]string{"none", "low", "medium", "high", "urgent"}
+
+// String returns the lower-case name used in forms and the API.
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return strconv.Itoa(int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority accepts a priority name ("high") or its number ("3"). An
+// empty string is PriorityNone.
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return PriorityNone, nil
+	}
+	for i, name := range priorityNames {
+		if s == name {
+			return Priority(i), nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= int(PriorityNone) && n <= int(PriorityUrgent) {
+		return Priority(n), nil
+	}
+	return PriorityNone, fmt.Errorf("%w %q (want none, low, medium, high or urgent)", ErrInvalidPriority, s)
+}
+
+// MarshalText encodes p by name, so the API speaks "high" rather than 3.
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText is the inverse of MarshalText.
+func (p *Priority) UnmarshalText(b []byte) error {
+	v, err := ParsePriority(string(b))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
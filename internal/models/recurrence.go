@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/recur"
+)
+
+// ErrInvalidRecurrence is returned for recurrence rules recur.Parse rejects.
+var ErrInvalidRecurrence = recur.ErrInvalid
+
+// SetRecurrence parses rule and stores it in in in canonical form. An
+// empty rule makes the todo one-off.
+func (in *ToDoInput) SetRecurrence(rule string) error {
+	if rule == "" {
+		in.Recurrence = ""
+		return nil
+	}
+	r, err := recur.Parse(rule)
+	if err != nil {
+		return err
+	}
+	in.Recurrence = r.String()
+	return nil
+}
+
+// RecurrenceRule returns the todo's parsed rule, or the zero Rule when it
+// doesn't repeat.
+func (t *ToDo) RecurrenceRule() recur.Rule {
+	r, err := recur.Parse(t.Recurrence)
+	if err != nil {
+		return recur.Rule{}
+	}
+	return r
+}
+
+// RecurrenceLabel describes the todo's schedule, e.g. "Weekly on Mon", or
+// returns "" for one-off todos.
+func (t *ToDo) RecurrenceLabel() string {
+	if t.Recurrence == "" {
+		return ""
+	}
+	return t.RecurrenceRule().Describe()
+}
+
+// NextOccurrence returns the todo that follows t, completed at now, under
+// t's recurrence rule. It reports false when t doesn't repeat.
+//
+// The next due date counts from t's due date, skipping occurrences that
+// are already in the past, or from now for FROM=COMPLETION rules and todos
+// without a due date. Timed deadlines keep their time of day; todos
+// without a zone use now's.
+func (t *ToDo) NextOccurrence(now time.Time) (ToDoInput, bool) {
+	if t.Recurrence == "" {
+		return ToDoInput{}, false
+	}
+	rule, err := recur.Parse(t.Recurrence)
+	if err != nil {
+		return ToDoInput{}, false
+	}
+	loc := now.Location()
+	if t.DueAt != nil {
+		loc = t.DueLocal().Location()
+	}
+	now = now.In(loc)
+	today := civilDate(now)
+
+	var next time.Time
+	if rule.FromCompletion || t.DueAt == nil {
+		base := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		if t.DueAt != nil && t.DueHasTime {
+			due := t.DueLocal()
+			base = time.Date(now.Year(), now.Month(), now.Day(), due.Hour(), due.Minute(), 0, 0, loc)
+		}
+		next = rule.Next(base)
+	} else {
+		next = rule.Next(t.DueLocal())
+		for civilDate(next).Before(today) {
+			next = rule.Next(next)
+		}
+	}
+
+	in := t.Input()
+	in.Completed = false
+	in.DueAt = &next
+	in.DueHasTime = t.DueAt != nil && t.DueHasTime
+	in.DueTZ = loc.String()
+	return in, true
+}
+
+// nextAfterCompletion returns the occurrence that follows t, which is being
+// completed now, or false when t doesn't repeat. However the todo is
+// completed, now is read in its deadline's zone, or in UTC when it has
+// none, so the web pages and the API agree on the next due date.
+func (t *ToDo) nextAfterCompletion() (ToDoInput, bool) {
+	loc := time.UTC
+	if t.DueAt != nil {
+		loc = t.DueLocal().Location()
+	}
+	return t.NextOccurrence(time.Now().In(loc))
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// spawn creates the next occurrence of e's todo, which has just been
+// completed, with its tags and its checklist unchecked, and moves the
+// recurrence rule over to it. It returns nil when the todo doesn't repeat.
+// Callers must hold mu for writing.
+func (s *StoreMemory) spawn(e *memToDo) *ToDo {
+	in, ok := e.todo.nextAfterCompletion()
+	if !ok {
+		return nil
+	}
+	e.todo.Recurrence = ""
+
+	now := time.Now().UTC()
+	next := &memToDo{
+		owner: e.owner,
+		todo: ToDo{
+			ID:        s.nextID,
+			Version:   1,
+			Tags:      append(StringList{}, e.todo.Tags...),
+			Position:  s.lastPosition(e.owner, in.ListID) + 1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+	next.todo.apply(in)
+	s.todos[next.todo.ID] = next
+	s.nextID++
+
+	var items []*ChecklistItem
+	for _, it := range s.items {
+		if it.ToDoID == e.todo.ID {
+			items = append(items, it)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	for _, it := range items {
+		c := &ChecklistItem{
+			ID:        s.nextItemID,
+			ToDoID:    next.todo.ID,
+			Title:     it.Title,
+			Position:  it.Position,
+			CreatedAt: now,
+		}
+		s.items[c.ID] = c
+		s.nextItemID++
+	}
+	s.countItems(next)
+
+	t := next.todo
+	return &t
+}
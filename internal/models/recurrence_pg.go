@@ -0,0 +1,37 @@
+package models
+
+import "github.com/jmoiron/sqlx"
+
+// spawn creates, in tx, the next occurrence of done, which has just been
+// completed there, with its tags and its checklist unchecked. done carries
+// the rule it was completed under; the caller has already taken the rule
+// off the stored todo. It returns nil when done doesn't repeat.
+func spawn(tx *sqlx.Tx, username string, done *ToDo) (*ToDo, error) {
+	in, ok := done.nextAfterCompletion()
+	if !ok {
+		return nil, nil
+	}
+	next, err := insertToDo(tx, username, in.ListID, in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO todo_tags (todo_id, tag_id)
+         SELECT $1, tag_id FROM todo_tags WHERE todo_id = $2`,
+		next.ID, done.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO checklist_items (todo_id, title, position)
+         SELECT $1, title, position FROM checklist_items WHERE todo_id = $2 ORDER BY id`,
+		next.ID, done.ID,
+	); err != nil {
+		return nil, err
+	}
+	var t ToDo
+	if err := tx.Get(&t, `SELECT `+todoColumns+` FROM todos WHERE id = $1`, next.ID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
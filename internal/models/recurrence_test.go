@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	at := func(s string) *time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	tests := []struct {
+		name    string
+		todo    ToDo
+		now     string
+		want    string
+		hasTime bool
+	}{
+		{
+			name: "from due date",
+			todo: ToDo{Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1", DueAt: at("2024-03-01 00:00"), DueTZ: "America/New_York"},
+			now:  "2024-02-28 10:00", want: "2024-04-01 00:00",
+		},
+		{
+			name: "skips missed occurrences",
+			todo: ToDo{Recurrence: "FREQ=WEEKLY;BYDAY=MO", DueAt: at("2024-01-01 09:30"), DueHasTime: true, DueTZ: "America/New_York"},
+			now:  "2024-01-17 12:00", want: "2024-01-22 09:30", hasTime: true,
+		},
+		{
+			name: "after completion keeps the time of day",
+			todo: ToDo{Recurrence: "FREQ=DAILY;INTERVAL=3;FROM=COMPLETION", DueAt: at("2024-01-01 18:00"), DueHasTime: true, DueTZ: "America/New_York"},
+			now:  "2024-01-10 08:00", want: "2024-01-13 18:00", hasTime: true,
+		},
+		{
+			name: "no due date counts from now",
+			todo: ToDo{Recurrence: "FREQ=WEEKLY"},
+			now:  "2024-01-10 08:00", want: "2024-01-17 00:00",
+		},
+	}
+	for _, tc := range tests {
+		in, ok := tc.todo.NextOccurrence(*at(tc.now))
+		if !ok {
+			t.Errorf("%s: no next occurrence", tc.name)
+			continue
+		}
+		if !in.DueAt.Equal(*at(tc.want)) || in.DueHasTime != tc.hasTime || in.DueTZ != "America/New_York" {
+			t.Errorf("%s: next due %s (time %v, %s), want %s", tc.name, in.DueAt.In(ny), in.DueHasTime, in.DueTZ, tc.want)
+		}
+		if in.Completed || in.Recurrence != tc.todo.Recurrence {
+			t.Errorf("%s: next occurrence %+v", tc.name, in)
+		}
+	}
+
+	if _, ok := (&ToDo{}).NextOccurrence(time.Now()); ok {
+		t.Error("a one-off todo has a next occurrence")
+	}
+}
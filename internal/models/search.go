@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchLimit caps how many todos ToDoStore.Search returns.
+const SearchLimit = 50
+
+// maxSearchTerms caps how many words of a query are used.
+const maxSearchTerms = 8
+
+// words splits s into lower-cased runs of letters and digits.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchTerms splits a search query into words, dropping repeats.
+// Punctuation separates words, so queries can't smuggle tsquery operators
+// into the Postgres store.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, w := range words(query) {
+		if seen[w] || len(terms) == maxSearchTerms {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// searchRank scores t against terms the way the Postgres store's weights
+// do: every term must prefix-match a word of the title or notes, and title
+// hits count double. It returns 0 when t doesn't match.
+func searchRank(t *ToDo, terms []string) int {
+	title, notes := words(t.Title), words(t.Notes)
+	rank := 0
+	for _, term := range terms {
+		hit := 0
+		for _, w := range title {
+			if strings.HasPrefix(w, term) {
+				hit += 2
+			}
+		}
+		for _, w := range notes {
+			if strings.HasPrefix(w, term) {
+				hit++
+			}
+		}
+		if hit == 0 {
+			return 0
+		}
+		rank += hit
+	}
+	return rank
+}
@@ -0,0 +1,37 @@
+package models
+
+import "sort"
+
+func (s *StoreMemory) Search(username, query string, opts ListOptions) ([]*ToDo, error) {
+	terms := searchTerms(query)
+	todos := []*ToDo{}
+	if len(terms) == 0 {
+		return todos, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ranks := map[int]int{}
+	for _, e := range s.todos {
+		if e.owner != username || !opts.matches(&e.todo) {
+			continue
+		}
+		if rank := searchRank(&e.todo, terms); rank > 0 {
+			t := e.todo
+			todos = append(todos, &t)
+			ranks[t.ID] = rank
+		}
+	}
+	manual := ListOptions{Sort: SortManual}
+	sort.Slice(todos, func(i, j int) bool {
+		if ri, rj := ranks[todos[i].ID], ranks[todos[j].ID]; ri != rj {
+			return ri > rj
+		}
+		return manual.less(todos[i], todos[j])
+	})
+	if len(todos) > SearchLimit {
+		todos = todos[:SearchLimit]
+	}
+	return todos, nil
+}
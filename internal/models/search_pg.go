@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tsQuery turns search terms into a tsquery matching todos that contain
+// every term, each as a word prefix so results show up while typing. It is
+// parsed with the 'simple' configuration, as the search column is built,
+// so words match as written the way searchRank matches them.
+// searchTerms only lets letters and digits through.
+func tsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (s *StorePostgres) Search(username, query string, opts ListOptions) ([]*ToDo, error) {
+	todos := []*ToDo{}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return todos, nil
+	}
+	where, args, err := listWhere(username, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, tsQuery(terms))
+	q := fmt.Sprintf(`to_tsquery('simple', $%d)`, len(args))
+	err = s.db.Select(
+		&todos,
+		`SELECT `+todoColumns+`
+           FROM todos
+          `+where+`
+            AND search @@ `+q+`
+          ORDER BY ts_rank(search, `+q+`) DESC, position, id
+          LIMIT `+fmt.Sprint(SearchLimit),
+		args...,
+	)
+	return todos, err
+}
@@ -0,0 +1,308 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// EventStoreFactory returns an empty EventStore.
+type EventStoreFactory func(t *testing.T) models.EventStore
+
+// AuditFactory returns an empty ToDoStore and an empty EventStore to audit
+// it in. Both must share a backend wherever the todo store needs one.
+type AuditFactory func(t *testing.T) (models.ToDoStore, models.EventStore)
+
+// RunEventStoreTests runs the EventStore contract against stores built by
+// newStore.
+func RunEventStoreTests(t *testing.T, newStore EventStoreFactory) {
+	t.Run("RecordAndList", func(t *testing.T) {
+		s := newStore(t)
+		e := &models.Event{
+			ToDoID:    7,
+			Username:  Alice,
+			Actor:     Alice,
+			Action:    models.ActionUpdate,
+			Changes:   models.Changes{"title": {From: "a", To: "b"}},
+			RequestID: "req-1",
+		}
+		if err := s.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		if e.ID <= 0 {
+			t.Fatalf("Record should set the ID, got %d", e.ID)
+		}
+		if d := time.Since(e.CreatedAt); d < -clockSlack || d > clockSlack {
+			t.Fatalf("CreatedAt %v is not now", e.CreatedAt)
+		}
+
+		got, err := s.Events(models.EventFilter{})
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("want 1 event, got %d", len(got))
+		}
+		g := got[0]
+		if g.ID != e.ID || g.ToDoID != 7 || g.Username != Alice || g.Actor != Alice ||
+			g.Action != models.ActionUpdate || g.RequestID != "req-1" ||
+			g.Changes["title"] != (models.Change{From: "a", To: "b"}) || len(g.Changes) != 1 {
+			t.Fatalf("event did not round-trip: %+v", g)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		s := newStore(t)
+		record := func(todoID int, user string) *models.Event {
+			t.Helper()
+			e := &models.Event{ToDoID: todoID, Username: user, Actor: user, Action: models.ActionCreate}
+			if err := s.Record(e); err != nil {
+				t.Fatalf("Record: %v", err)
+			}
+			return e
+		}
+		a1 := record(1, Alice)
+		b2 := record(2, Bob)
+		a3 := record(3, Alice)
+		a1again := record(1, Alice)
+
+		events := func(f models.EventFilter) []int {
+			t.Helper()
+			got, err := s.Events(f)
+			if err != nil {
+				t.Fatalf("Events(%+v): %v", f, err)
+			}
+			out := []int{}
+			for _, e := range got {
+				out = append(out, e.ID)
+			}
+			return out
+		}
+		cases := []struct {
+			name string
+			f    models.EventFilter
+			want []int
+		}{
+			{"all newest first", models.EventFilter{}, []int{a1again.ID, a3.ID, b2.ID, a1.ID}},
+			{"user", models.EventFilter{Username: Bob}, []int{b2.ID}},
+			{"todo", models.EventFilter{ToDoID: 1}, []int{a1again.ID, a1.ID}},
+			{"before", models.EventFilter{BeforeID: a3.ID}, []int{b2.ID, a1.ID}},
+			{"limit", models.EventFilter{Username: Alice, Limit: 2}, []int{a1again.ID, a3.ID}},
+			{"since future", models.EventFilter{Since: time.Now().Add(time.Hour)}, []int{}},
+			{"until past", models.EventFilter{Until: time.Now().Add(-time.Hour)}, []int{}},
+			{"window", models.EventFilter{
+				Username: Bob,
+				Since:    time.Now().Add(-time.Hour),
+				Until:    time.Now().Add(time.Hour),
+			}, []int{b2.ID}},
+		}
+		for _, c := range cases {
+			if got := events(c.f); !equalIDs(got, c.want) {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			}
+		}
+	})
+}
+
+// RunAuditTests checks that a models.AuditedStore over the stores built by
+// newStores records each kind of change.
+func RunAuditTests(t *testing.T, newStores AuditFactory) {
+	t.Run("Lifecycle", func(t *testing.T) {
+		inner, events := newStores(t)
+		s := models.NewAuditedStore(inner, events).As(Alice, "req-1")
+
+		td := mustCreate(t, s, Alice, "write audit log")
+		in := td.Input()
+		in.Title = "write the audit log"
+		if _, err := s.Update(td.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if _, err := s.Update(td.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		in.Completed = true
+		if _, err := s.Update(td.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		in.Completed = false
+		if _, err := s.Update(td.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := s.Tag(td.ID, Alice, "work"); err != nil {
+			t.Fatalf("Tag: %v", err)
+		}
+		if err := s.Delete(td.ID, Alice); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Restore(td.ID, Alice); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if err := s.Delete(td.ID, Alice); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.Purge(td.ID, Alice); err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+
+		got, err := events.Events(models.EventFilter{ToDoID: td.ID})
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		want := []models.Action{
+			models.ActionPurge, models.ActionDelete, models.ActionRestore, models.ActionDelete,
+			models.ActionUpdate, models.ActionReopen, models.ActionComplete, models.ActionUpdate,
+			models.ActionCreate,
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+		}
+		for i, e := range got {
+			if e.Action != want[i] || e.Actor != Alice || e.Username != Alice || e.RequestID != "req-1" {
+				t.Errorf("event %d = %+v, want %s by %s", i, e, want[i], Alice)
+			}
+		}
+		if c := got[8].Changes["title"]; c.From != "" || c.To != "write audit log" {
+			t.Errorf("create should record the title, got %+v", got[8].Changes)
+		}
+		if c := got[7].Changes; len(c) != 1 || c["title"] != (models.Change{From: "write audit log", To: "write the audit log"}) {
+			t.Errorf("update should record just the title, got %+v", c)
+		}
+		if c := got[6].Changes["completed"]; c != (models.Change{From: "false", To: "true"}) {
+			t.Errorf("complete should record completed, got %+v", got[6].Changes)
+		}
+		if c := got[4].Changes["tags"]; c.To != "work" {
+			t.Errorf("tagging should record the tags, got %+v", got[4].Changes)
+		}
+	})
+
+	t.Run("ClearAndPurge", func(t *testing.T) {
+		inner, events := newStores(t)
+		s := models.NewAuditedStore(inner, events)
+
+		done := mustCreate(t, s, Bob, "done")
+		open := mustCreate(t, s, Bob, "open")
+		in := done.Input()
+		in.Completed = true
+		if _, err := s.Update(done.ID, in, Bob); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := s.ClearCompleted(Bob, 0); err != nil {
+			t.Fatalf("ClearCompleted: %v", err)
+		}
+		if _, err := s.PurgeDeleted(time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("PurgeDeleted: %v", err)
+		}
+
+		got, err := events.Events(models.EventFilter{ToDoID: done.ID, Limit: 2})
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if len(got) != 2 ||
+			got[0].Action != models.ActionPurge || got[0].Actor != models.SystemActor || got[0].Username != Bob ||
+			got[1].Action != models.ActionDelete || got[1].Actor != Bob {
+			t.Fatalf("want a delete by %s then a purge by the system, got %+v", Bob, got)
+		}
+		got, err = events.Events(models.EventFilter{ToDoID: open.ID})
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if len(got) != 1 || got[0].Action != models.ActionCreate {
+			t.Fatalf("the open todo should only have been created, got %+v", got)
+		}
+	})
+
+	t.Run("ChecklistReopens", func(t *testing.T) {
+		inner, events := newStores(t)
+		s := models.NewAuditedStore(inner, events)
+
+		td := mustCreate(t, s, Alice, "pack")
+		in := td.Input()
+		in.Completed = true
+		if _, err := s.Update(td.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if _, err := s.AddItem(td.ID, Alice, "socks"); err != nil {
+			t.Fatalf("AddItem: %v", err)
+		}
+		if _, err := s.AddItem(td.ID, Alice, "shoes"); err != nil {
+			t.Fatalf("AddItem: %v", err)
+		}
+		got, err := events.Events(models.EventFilter{ToDoID: td.ID, Limit: 2})
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if len(got) != 2 || got[0].Action != models.ActionReopen || got[1].Action != models.ActionComplete {
+			t.Fatalf("adding an item should reopen once, got %+v", got)
+		}
+	})
+	t.Run("DeleteList", func(t *testing.T) {
+		inner, events := newStores(t)
+		s := models.NewAuditedStore(inner, events)
+
+		list, err := s.CreateList(Alice, "errands")
+		if err != nil {
+			t.Fatalf("CreateList: %v", err)
+		}
+		live, err := s.Create(Alice, models.ToDoInput{ListID: list.ID, Title: "milk"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		trashed, err := s.Create(Alice, models.ToDoInput{ListID: list.ID, Title: "eggs"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if err := s.Delete(trashed.ID, Alice); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.DeleteList(list.ID, Alice); err != nil {
+			t.Fatalf("DeleteList: %v", err)
+		}
+		for _, id := range []int{live.ID, trashed.ID} {
+			got, err := events.Events(models.EventFilter{ToDoID: id, Limit: 1})
+			if err != nil {
+				t.Fatalf("Events: %v", err)
+			}
+			if len(got) != 1 || got[0].Action != models.ActionPurge {
+				t.Errorf("todo %d should be purged with its list, got %+v", id, got)
+			}
+		}
+	})
+
+	t.Run("Bulk", func(t *testing.T) {
+		inner, events := newStores(t)
+		s := models.NewAuditedStore(inner, events).As(Alice, "req-2")
+
+		a := mustCreate(t, s, Alice, "a")
+		b := mustCreate(t, s, Alice, "b")
+		if _, _, err := s.Bulk(Alice, []int{a.ID, b.ID}, models.BulkAction{Op: models.BulkTag, Tags: []string{"work"}}); err != nil {
+			t.Fatalf("Bulk tag: %v", err)
+		}
+		if _, _, err := s.Bulk(Alice, []int{a.ID}, models.BulkAction{Op: models.BulkComplete}); err != nil {
+			t.Fatalf("Bulk complete: %v", err)
+		}
+		if _, _, err := s.Bulk(Alice, []int{a.ID, b.ID}, models.BulkAction{Op: models.BulkComplete}); err != nil {
+			t.Fatalf("Bulk complete: %v", err)
+		}
+		if _, _, err := s.Bulk(Alice, []int{a.ID, b.ID}, models.BulkAction{Op: models.BulkDelete}); err != nil {
+			t.Fatalf("Bulk delete: %v", err)
+		}
+
+		// The second complete only records b, which wasn't done yet.
+		want := []models.Action{models.ActionDelete, models.ActionComplete, models.ActionUpdate, models.ActionCreate}
+		for _, id := range []int{a.ID, b.ID} {
+			got, err := events.Events(models.EventFilter{ToDoID: id})
+			if err != nil {
+				t.Fatalf("Events: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("todo %d: got %d events, want %d: %+v", id, len(got), len(want), got)
+			}
+			for i, e := range got {
+				if e.Action != want[i] || e.RequestID != "req-2" {
+					t.Errorf("todo %d event %d = %+v, want %s", id, i, e, want[i])
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,1580 @@
+// Package storetest holds the conformance suite every models.ToDoStore and
+// models.UserStore backend must pass. Backends call RunToDoStoreTests and
+// RunUserStoreTests from their own _test.go files with a factory for fresh
+// stores.
+package storetest
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// Usernames the suite creates todos for. Backends with referential
+// integrity (e.g. Postgres) must make sure both users exist in every store
+// the factory returns.
+const (
+	Alice = "alice"
+	Bob   = "bob"
+)
+
+// ToDoStoreFactory returns an empty ToDoStore. It is called once per subtest.
+type ToDoStoreFactory func(t *testing.T) models.ToDoStore
+
+// UserStoreFactory returns a UserStore with no registered users.
+type UserStoreFactory func(t *testing.T) models.UserStore
+
+// clockSlack absorbs clock drift between the test process and a database
+// server when checking timestamps.
+const clockSlack = 5 * time.Second
+
+// RunToDoStoreTests runs the ToDoStore contract against stores built by
+// newStore.
+func RunToDoStoreTests(t *testing.T, newStore ToDoStoreFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s models.ToDoStore)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"GetAllEmpty", testGetAllEmpty},
+		{"GetAllOrdering", testGetAllOrdering},
+		{"Update", testUpdate},
+		{"Versions", testVersions},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"UserIsolation", testUserIsolation},
+		{"ClearCompleted", testClearCompleted},
+		{"Timestamps", testTimestamps},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"DueDates", testDueDates},
+		{"Priority", testPriority},
+		{"SortOrders", testSortOrders},
+		{"Tags", testTags},
+		{"TagFilter", testTagFilter},
+		{"Lists", testLists},
+		{"ListScoping", testListScoping},
+		{"ListIsolation", testListIsolation},
+		{"Checklist", testChecklist},
+		{"ChecklistCompletion", testChecklistCompletion},
+		{"ChecklistIsolation", testChecklistIsolation},
+		{"Recurrence", testRecurrence},
+		{"Notes", testNotes},
+		{"Move", testMove},
+		{"MoveErrors", testMoveErrors},
+		{"Search", testSearch},
+		{"Paging", testPaging},
+		{"Trash", testTrash},
+		{"PurgeDeleted", testPurgeDeleted},
+		{"Bulk", testBulk},
+		{"BulkErrors", testBulkErrors},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// RunUserStoreTests runs the UserStore contract against stores built by
+// newStore.
+func RunUserStoreTests(t *testing.T, newStore UserStoreFactory) {
+	t.Run("CreateAndAuthenticate", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(Alice, "hunter2"); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if !s.Authenticate(Alice, "hunter2") {
+			t.Fatal("Authenticate rejected the right password")
+		}
+		if s.Authenticate(Alice, "wrong") {
+			t.Fatal("Authenticate accepted a wrong password")
+		}
+		if s.Authenticate(Bob, "hunter2") {
+			t.Fatal("Authenticate accepted an unknown user")
+		}
+	})
+	t.Run("DuplicateUser", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(Alice, "one"); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if err := s.Create(Alice, "two"); !errors.Is(err, models.ErrUserExists) {
+			t.Fatalf("duplicate Create: want ErrUserExists, got %v", err)
+		}
+		if !s.Authenticate(Alice, "one") {
+			t.Fatal("duplicate Create replaced the original password")
+		}
+	})
+}
+
+// mustCreate creates a todo or fails the test.
+func mustCreate(t *testing.T, s models.ToDoStore, username, title string) *models.ToDo {
+	t.Helper()
+	todo, err := s.Create(username, models.ToDoInput{Title: title})
+	if err != nil {
+		t.Fatalf("Create(%q, %q): %v", username, title, err)
+	}
+	return todo
+}
+
+// mustGetAll lists a user's todos or fails the test.
+func mustGetAll(t *testing.T, s models.ToDoStore, username string) []*models.ToDo {
+	t.Helper()
+	todos, err := s.GetAll(username, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetAll(%q): %v", username, err)
+	}
+	return todos
+}
+
+// ids returns the IDs of todos in order.
+func ids(todos []*models.ToDo) []int {
+	out := make([]int, len(todos))
+	for i, t := range todos {
+		out[i] = t.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateAndGet(t *testing.T, s models.ToDoStore) {
+	created := mustCreate(t, s, Alice, "write tests")
+	if created.ID <= 0 {
+		t.Fatalf("expected a positive ID, got %d", created.ID)
+	}
+	if created.Title != "write tests" || created.Completed {
+		t.Fatalf("unexpected todo %+v", created)
+	}
+
+	got, err := s.Get(created.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.ID != created.ID || got.Title != created.Title || got.Completed != created.Completed {
+		t.Fatalf("Get returned %+v, want %+v", got, created)
+	}
+}
+
+func testGetAllEmpty(t *testing.T, s models.ToDoStore) {
+	if todos := mustGetAll(t, s, Alice); len(todos) != 0 {
+		t.Fatalf("expected no todos, got %d", len(todos))
+	}
+}
+
+func testGetAllOrdering(t *testing.T, s models.ToDoStore) {
+	var want []int
+	for _, title := range []string{"first", "second", "third"} {
+		want = append(want, mustCreate(t, s, Alice, title).ID)
+	}
+	for i := 1; i < len(want); i++ {
+		if want[i] <= want[i-1] {
+			t.Fatalf("IDs are not increasing: %v", want)
+		}
+	}
+	// Updating an older item must not move it.
+	if _, err := s.Update(want[0], models.ToDoInput{Title: "first, edited", Completed: true}, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, want) {
+		t.Fatalf("GetAll order = %v, want %v", got, want)
+	}
+}
+
+func testUpdate(t *testing.T, s models.ToDoStore) {
+	todo := mustCreate(t, s, Alice, "initial")
+	updated, err := s.Update(todo.ID, models.ToDoInput{Title: "changed", Completed: true}, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.ID != todo.ID || updated.Title != "changed" || !updated.Completed {
+		t.Fatalf("Update returned %+v", updated)
+	}
+	got, err := s.Get(todo.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get after Update: %v", err)
+	}
+	if got.Title != "changed" || !got.Completed {
+		t.Fatalf("Get after Update returned %+v", got)
+	}
+
+	// And back again.
+	if updated, err = s.Update(todo.ID, models.ToDoInput{Title: "changed"}, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Completed {
+		t.Fatalf("expected todo to be reopened, got %+v", updated)
+	}
+}
+
+func testVersions(t *testing.T, s models.ToDoStore) {
+	todo := mustCreate(t, s, Alice, "draft")
+	if todo.Version != 1 {
+		t.Fatalf("new todo at version %d, want 1", todo.Version)
+	}
+
+	// Two edits based on the same read: the second is refused.
+	in := todo.Input()
+	in.Title, in.Version = "mine", todo.Version
+	updated, err := s.Update(todo.ID, in, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("version after Update = %d, want 2", updated.Version)
+	}
+	in.Title = "theirs"
+	if _, err := s.Update(todo.ID, in, Alice); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("stale Update: want ErrConflict, got %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice); got.Title != "mine" || got.Version != 2 {
+		t.Fatalf("a refused Update changed the todo: %+v", got)
+	}
+
+	// Version 0 updates unconditionally.
+	in.Version = 0
+	if updated, err = s.Update(todo.ID, in, Alice); err != nil || updated.Version != 3 {
+		t.Fatalf("unconditional Update = %+v, %v", updated, err)
+	}
+
+	// Tags count as edits; tagging with what's already there doesn't.
+	if err := s.Tag(todo.ID, Alice, "work"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if err := s.Tag(todo.ID, Alice, "work"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if err := s.Untag(todo.ID, Alice, "home"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice); got.Version != 4 {
+		t.Fatalf("version after tagging = %d, want 4", got.Version)
+	}
+	if err := s.Untag(todo.ID, Alice, "work"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice); got.Version != 5 {
+		t.Fatalf("version after untagging = %d, want 5", got.Version)
+	}
+
+	// So does a checklist change reopening the todo.
+	in = mustGet(t, s, todo.ID, Alice).Input()
+	in.Completed = true
+	if _, err := s.Update(todo.ID, in, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := s.AddItem(todo.ID, Alice, "step"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice); got.Completed || got.Version != 7 {
+		t.Fatalf("after reopening by AddItem: %+v", got)
+	}
+
+	// A precondition doesn't turn a missing todo into a conflict.
+	in.Version = 1
+	if _, err := s.Update(987654, in, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("missing todo: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.Update(todo.ID, models.ToDoInput{Title: "x", Version: 1}, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("another user's todo: want ErrNotFound, got %v", err)
+	}
+}
+
+func testDelete(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	b := mustCreate(t, s, Alice, "b")
+	if err := s.Delete(a.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(a.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("Get after Delete: want ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(a.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("second Delete: want ErrNotFound, got %v", err)
+	}
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, []int{b.ID}) {
+		t.Fatalf("GetAll after Delete = %v, want [%d]", got, b.ID)
+	}
+}
+
+func testNotFound(t *testing.T, s models.ToDoStore) {
+	const missing = 987654
+	if _, err := s.Get(missing, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Get: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.Update(missing, models.ToDoInput{Title: "x"}, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Update: want ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(missing, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Delete: want ErrNotFound, got %v", err)
+	}
+}
+
+func testUserIsolation(t *testing.T, s models.ToDoStore) {
+	mine := mustCreate(t, s, Alice, "alice's")
+	theirs := mustCreate(t, s, Bob, "bob's")
+
+	if _, err := s.Get(mine.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Get as other user: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.Update(mine.ID, models.ToDoInput{Title: "hijacked", Completed: true}, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Update as other user: want ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(mine.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Delete as other user: want ErrNotFound, got %v", err)
+	}
+
+	got, err := s.Get(mine.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get as owner: %v", err)
+	}
+	if got.Title != "alice's" || got.Completed {
+		t.Fatalf("other user's calls modified the todo: %+v", got)
+	}
+	if all := ids(mustGetAll(t, s, Alice)); !equalIDs(all, []int{mine.ID}) {
+		t.Errorf("GetAll(alice) = %v, want [%d]", all, mine.ID)
+	}
+	if all := ids(mustGetAll(t, s, Bob)); !equalIDs(all, []int{theirs.ID}) {
+		t.Errorf("GetAll(bob) = %v, want [%d]", all, theirs.ID)
+	}
+}
+
+func testClearCompleted(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	b := mustCreate(t, s, Alice, "b")
+	c := mustCreate(t, s, Alice, "c")
+	other := mustCreate(t, s, Bob, "bob's done")
+	for _, todo := range []*models.ToDo{a, c} {
+		if _, err := s.Update(todo.ID, models.ToDoInput{Title: todo.Title, Completed: true}, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	if _, err := s.Update(other.ID, models.ToDoInput{Title: other.Title, Completed: true}, Bob); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if err := s.ClearCompleted(Alice, 0); err != nil {
+		t.Fatalf("ClearCompleted: %v", err)
+	}
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, []int{b.ID}) {
+		t.Fatalf("GetAll after ClearCompleted = %v, want [%d]", got, b.ID)
+	}
+	if got := ids(mustGetAll(t, s, Bob)); !equalIDs(got, []int{other.ID}) {
+		t.Fatalf("ClearCompleted touched another user's todos: %v", got)
+	}
+	// Clearing with nothing to clear is not an error.
+	if err := s.ClearCompleted(Alice, 0); err != nil {
+		t.Fatalf("second ClearCompleted: %v", err)
+	}
+}
+
+func testTimestamps(t *testing.T, s models.ToDoStore) {
+	before := time.Now()
+	todo := mustCreate(t, s, Alice, "timed")
+	if todo.CreatedAt.IsZero() || todo.UpdatedAt.IsZero() {
+		t.Fatalf("timestamps not set: %+v", todo)
+	}
+	if d := todo.CreatedAt.Sub(before); d < -clockSlack || d > clockSlack {
+		t.Fatalf("CreatedAt %v is not close to now (%v)", todo.CreatedAt, before)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	updated, err := s.Update(todo.ID, models.ToDoInput{Title: "retimed"}, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !updated.CreatedAt.Equal(todo.CreatedAt) {
+		t.Errorf("Update changed CreatedAt from %v to %v", todo.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(todo.UpdatedAt) {
+		t.Errorf("Update did not advance UpdatedAt (%v -> %v)", todo.UpdatedAt, updated.UpdatedAt)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, s models.ToDoStore) {
+	const n = 20
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int]bool)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			todo, err := s.Create(Alice, models.ToDoInput{Title: "parallel"})
+			if err != nil {
+				t.Errorf("Create: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[todo.ID] {
+				t.Errorf("duplicate ID %d", todo.ID)
+			}
+			seen[todo.ID] = true
+		}()
+	}
+	wg.Wait()
+	if got := len(mustGetAll(t, s, Alice)); got != n {
+		t.Fatalf("expected %d todos, got %d", n, got)
+	}
+}
+
+func testDueDates(t *testing.T, s models.ToDoStore) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	due := time.Date(2030, 3, 14, 9, 30, 0, 0, loc)
+	todo, err := s.Create(Alice, models.ToDoInput{
+		Title:      "timed",
+		DueAt:      &due,
+		DueHasTime: true,
+		DueTZ:      "America/New_York",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := s.Get(todo.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.DueAt == nil || !got.DueAt.Equal(due) || !got.DueHasTime || got.DueTZ != "America/New_York" {
+		t.Fatalf("due fields did not round-trip: %+v", got)
+	}
+	if l := got.DueLocal(); l.Hour() != 9 || l.Minute() != 30 {
+		t.Fatalf("DueLocal = %v, want 09:30 New York time", l)
+	}
+
+	// Switching to a date-only deadline drops the time flag.
+	in := got.Input()
+	in.DueHasTime = false
+	updated, err := s.Update(todo.ID, in, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.DueAt == nil || updated.DueHasTime {
+		t.Fatalf("Update to date-only returned %+v", updated)
+	}
+
+	// And clearing it removes the deadline entirely.
+	in = updated.Input()
+	in.DueAt, in.DueTZ = nil, ""
+	if updated, err = s.Update(todo.ID, in, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.DueAt != nil || updated.DueTZ != "" {
+		t.Fatalf("clearing the deadline returned %+v", updated)
+	}
+	if plain := mustCreate(t, s, Alice, "no deadline"); plain.DueAt != nil {
+		t.Fatalf("Create without a deadline set DueAt: %+v", plain)
+	}
+}
+
+func testPriority(t *testing.T, s models.ToDoStore) {
+	todo, err := s.Create(Alice, models.ToDoInput{Title: "urgent", Priority: models.PriorityUrgent})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if todo.Priority != models.PriorityUrgent {
+		t.Fatalf("Create returned priority %v", todo.Priority)
+	}
+	in := todo.Input()
+	in.Priority = models.PriorityLow
+	if _, err := s.Update(todo.ID, in, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := s.Get(todo.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Priority != models.PriorityLow {
+		t.Fatalf("Get after Update returned priority %v", got.Priority)
+	}
+}
+
+func testSortOrders(t *testing.T, s models.ToDoStore) {
+	day := func(n int) *time.Time {
+		d := time.Date(2030, 1, n, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	create := func(title string, p models.Priority, due *time.Time) int {
+		t.Helper()
+		todo, err := s.Create(Alice, models.ToDoInput{Title: title, Priority: p, DueAt: due, DueTZ: "UTC"})
+		if err != nil {
+			t.Fatalf("Create(%q): %v", title, err)
+		}
+		return todo.ID
+	}
+	plain := create("plain", models.PriorityNone, nil)
+	lowLate := create("low, due late", models.PriorityLow, day(20))
+	high := create("high, no date", models.PriorityHigh, nil)
+	lowSoon := create("low, due soon", models.PriorityLow, day(2))
+	noneSoon := create("none, due soon", models.PriorityNone, day(2))
+
+	for _, tc := range []struct {
+		sort models.SortOrder
+		want []int
+	}{
+		{"", []int{plain, lowLate, high, lowSoon, noneSoon}},
+		{models.SortManual, []int{plain, lowLate, high, lowSoon, noneSoon}},
+		{models.SortPriority, []int{high, lowSoon, lowLate, noneSoon, plain}},
+		{models.SortDue, []int{lowSoon, noneSoon, lowLate, high, plain}},
+		{models.SortCreated, []int{plain, lowLate, high, lowSoon, noneSoon}},
+	} {
+		todos, err := s.GetAll(Alice, models.ListOptions{Sort: tc.sort})
+		if err != nil {
+			t.Fatalf("GetAll(%q): %v", tc.sort, err)
+		}
+		if got := ids(todos); !equalIDs(got, tc.want) {
+			t.Errorf("GetAll sorted by %q = %v, want %v", tc.sort, got, tc.want)
+		}
+	}
+}
+
+func testTags(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	b := mustCreate(t, s, Alice, "b")
+	if len(a.Tags) != 0 {
+		t.Fatalf("new todo has tags %v", a.Tags)
+	}
+	if err := s.Tag(a.ID, Alice, "Ops", "#billing", "ops"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if err := s.Tag(b.ID, Alice, "ops"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	// Tagging again is a no-op.
+	if err := s.Tag(a.ID, Alice, "billing"); err != nil {
+		t.Fatalf("second Tag: %v", err)
+	}
+
+	got, err := s.Get(a.ID, Alice)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if want := []string{"billing", "ops"}; !equalStrings(got.Tags, want) {
+		t.Fatalf("Tags = %v, want %v", got.Tags, want)
+	}
+	counts, err := s.Tags(Alice)
+	if err != nil {
+		t.Fatalf("Tags: %v", err)
+	}
+	if want := []models.TagCount{{Name: "billing", Count: 1}, {Name: "ops", Count: 2}}; !equalCounts(counts, want) {
+		t.Fatalf("Tags(alice) = %v, want %v", counts, want)
+	}
+
+	if err := s.Untag(a.ID, Alice, "ops", "never-added"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if got, _ = s.Get(a.ID, Alice); !equalStrings(got.Tags, []string{"billing"}) {
+		t.Fatalf("Tags after Untag = %v", got.Tags)
+	}
+
+	// Deleting a todo drops it from the counts.
+	if err := s.Delete(b.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	counts, _ = s.Tags(Alice)
+	if want := []models.TagCount{{Name: "billing", Count: 1}}; !equalCounts(counts, want) {
+		t.Fatalf("Tags after Delete = %v, want %v", counts, want)
+	}
+
+	if err := s.Tag(a.ID, Bob, "mine"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Tag as other user: want ErrNotFound, got %v", err)
+	}
+	if err := s.Untag(a.ID, Bob, "billing"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Untag as other user: want ErrNotFound, got %v", err)
+	}
+	if counts, _ := s.Tags(Bob); len(counts) != 0 {
+		t.Errorf("Tags(bob) = %v, want none", counts)
+	}
+	if err := s.Tag(a.ID, Alice, "no spaces"); !errors.Is(err, models.ErrInvalidTag) {
+		t.Errorf("Tag with a bad name: want ErrInvalidTag, got %v", err)
+	}
+}
+
+func testTagFilter(t *testing.T, s models.ToDoStore) {
+	both := mustCreate(t, s, Alice, "both")
+	ops := mustCreate(t, s, Alice, "ops only")
+	mustCreate(t, s, Alice, "untagged")
+	for _, tc := range []struct {
+		id   int
+		tags []string
+	}{{both.ID, []string{"ops", "billing"}}, {ops.ID, []string{"ops"}}} {
+		if err := s.Tag(tc.id, Alice, tc.tags...); err != nil {
+			t.Fatalf("Tag: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		tags  []string
+		match models.TagMatch
+		want  []int
+	}{
+		{[]string{"ops"}, models.TagMatchAny, []int{both.ID, ops.ID}},
+		{[]string{"billing", "ops"}, models.TagMatchAny, []int{both.ID, ops.ID}},
+		{[]string{"billing", "ops"}, models.TagMatchAll, []int{both.ID}},
+		{[]string{"personal"}, models.TagMatchAny, []int{}},
+	} {
+		todos, err := s.GetAll(Alice, models.ListOptions{Sort: models.SortCreated, Tags: tc.tags, TagMatch: tc.match})
+		if err != nil {
+			t.Fatalf("GetAll(%v, %s): %v", tc.tags, tc.match, err)
+		}
+		if got := ids(todos); !equalIDs(got, tc.want) {
+			t.Errorf("GetAll with %s of %v = %v, want %v", tc.match, tc.tags, got, tc.want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalCounts(a, b []models.TagCount) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testLists(t *testing.T, s models.ToDoStore) {
+	inbox, err := s.Inbox(Alice)
+	if err != nil {
+		t.Fatalf("Inbox: %v", err)
+	}
+	if !inbox.Inbox || inbox.Name != models.InboxName {
+		t.Fatalf("Inbox returned %+v", inbox)
+	}
+	if again, _ := s.Inbox(Alice); again.ID != inbox.ID {
+		t.Fatalf("second Inbox call made a new list: %d != %d", again.ID, inbox.ID)
+	}
+	if todo := mustCreate(t, s, Alice, "unfiled"); todo.ListID != inbox.ID {
+		t.Fatalf("todo without a list went to %d, want the Inbox (%d)", todo.ListID, inbox.ID)
+	}
+
+	work, err := s.CreateList(Alice, "  Work ")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if work.Name != "Work" || work.Inbox || work.Archived() {
+		t.Fatalf("CreateList returned %+v", work)
+	}
+	if _, err := s.CreateList(Alice, "work"); !errors.Is(err, models.ErrListExists) {
+		t.Errorf("duplicate CreateList: want ErrListExists, got %v", err)
+	}
+	for _, name := range []string{"", "inbox"} {
+		if _, err := s.CreateList(Alice, name); !errors.Is(err, models.ErrInvalidList) {
+			t.Errorf("CreateList(%q): want ErrInvalidList, got %v", name, err)
+		}
+	}
+	home, err := s.CreateList(Alice, "Home")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+
+	if _, err := s.RenameList(home.ID, Alice, "Work"); !errors.Is(err, models.ErrListExists) {
+		t.Errorf("RenameList onto another list's name: want ErrListExists, got %v", err)
+	}
+	if home, err = s.RenameList(home.ID, Alice, "House"); err != nil || home.Name != "House" {
+		t.Fatalf("RenameList: %+v, %v", home, err)
+	}
+
+	lists, err := s.Lists(Alice)
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	var names []string
+	for _, l := range lists {
+		names = append(names, l.Name)
+	}
+	if want := []string{"Inbox", "House", "Work"}; !equalStrings(names, want) {
+		t.Fatalf("Lists = %v, want %v", names, want)
+	}
+	if lists[0].Open != 1 {
+		t.Errorf("Inbox Open = %d, want 1", lists[0].Open)
+	}
+
+	if work, err = s.ArchiveList(work.ID, Alice, true); err != nil || !work.Archived() {
+		t.Fatalf("ArchiveList: %+v, %v", work, err)
+	}
+	if work, err = s.ArchiveList(work.ID, Alice, false); err != nil || work.Archived() {
+		t.Fatalf("unarchive: %+v, %v", work, err)
+	}
+	if _, err := s.ArchiveList(inbox.ID, Alice, true); !errors.Is(err, models.ErrInvalidList) {
+		t.Errorf("archiving the Inbox: want ErrInvalidList, got %v", err)
+	}
+	if err := s.DeleteList(inbox.ID, Alice); !errors.Is(err, models.ErrInvalidList) {
+		t.Errorf("deleting the Inbox: want ErrInvalidList, got %v", err)
+	}
+
+	// Deleting a list takes its todos with it.
+	filed, err := s.Create(Alice, models.ToDoInput{ListID: work.ID, Title: "filed"})
+	if err != nil {
+		t.Fatalf("Create in list: %v", err)
+	}
+	if err := s.DeleteList(work.ID, Alice); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	if _, err := s.Get(filed.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("todo survived its list's deletion: %v", err)
+	}
+	if _, err := s.GetList(work.ID, Alice); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("GetList after DeleteList: want ErrListNotFound, got %v", err)
+	}
+}
+
+func testListScoping(t *testing.T, s models.ToDoStore) {
+	work, err := s.CreateList(Alice, "Work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	inboxTodo := mustCreate(t, s, Alice, "inbox item")
+	workTodo, err := s.Create(Alice, models.ToDoInput{ListID: work.ID, Title: "work item"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	todos, err := s.GetAll(Alice, models.ListOptions{ListID: work.ID})
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if got := ids(todos); !equalIDs(got, []int{workTodo.ID}) {
+		t.Fatalf("GetAll(Work) = %v, want [%d]", got, workTodo.ID)
+	}
+	if got := len(mustGetAll(t, s, Alice)); got != 2 {
+		t.Fatalf("GetAll across lists returned %d todos, want 2", got)
+	}
+
+	// Completed todos are cleared per list.
+	for _, todo := range []*models.ToDo{inboxTodo, workTodo} {
+		in := todo.Input()
+		in.Completed = true
+		if _, err := s.Update(todo.ID, in, Alice); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	if err := s.ClearCompleted(Alice, work.ID); err != nil {
+		t.Fatalf("ClearCompleted: %v", err)
+	}
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, []int{inboxTodo.ID}) {
+		t.Fatalf("after clearing Work, todos = %v, want [%d]", got, inboxTodo.ID)
+	}
+
+	// Moving a todo between lists.
+	in := inboxTodo.Input()
+	in.ListID = work.ID
+	moved, err := s.Update(inboxTodo.ID, in, Alice)
+	if err != nil || moved.ListID != work.ID {
+		t.Fatalf("moving to Work: %+v, %v", moved, err)
+	}
+}
+
+func testListIsolation(t *testing.T, s models.ToDoStore) {
+	mine, err := s.CreateList(Alice, "Private")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if _, err := s.GetList(mine.ID, Bob); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("GetList as other user: want ErrListNotFound, got %v", err)
+	}
+	if _, err := s.Create(Bob, models.ToDoInput{ListID: mine.ID, Title: "sneaky"}); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("Create in other user's list: want ErrListNotFound, got %v", err)
+	}
+	if _, err := s.RenameList(mine.ID, Bob, "Mine now"); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("RenameList as other user: want ErrListNotFound, got %v", err)
+	}
+	if err := s.DeleteList(mine.ID, Bob); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("DeleteList as other user: want ErrListNotFound, got %v", err)
+	}
+	// Both users can have a list with the same name.
+	if _, err := s.CreateList(Bob, "Private"); err != nil {
+		t.Errorf("CreateList with another user's list name: %v", err)
+	}
+}
+
+// mustGet fetches a todo or fails the test.
+func mustGet(t *testing.T, s models.ToDoStore, id int, username string) *models.ToDo {
+	t.Helper()
+	todo, err := s.Get(id, username)
+	if err != nil {
+		t.Fatalf("Get(%d, %q): %v", id, username, err)
+	}
+	return todo
+}
+
+func testChecklist(t *testing.T, s models.ToDoStore) {
+	todo := mustCreate(t, s, Alice, "release")
+	if items, err := s.Items(todo.ID, Alice); err != nil || len(items) != 0 {
+		t.Fatalf("Items on a new todo = %v, %v; want none", items, err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice).Progress(); got != "" {
+		t.Errorf("Progress without items = %q, want empty", got)
+	}
+
+	var added []*models.ChecklistItem
+	for _, title := range []string{"tag", " build ", "announce"} {
+		it, err := s.AddItem(todo.ID, Alice, title)
+		if err != nil {
+			t.Fatalf("AddItem(%q): %v", title, err)
+		}
+		added = append(added, it)
+	}
+	if added[1].Title != "build" || added[1].Done || added[1].ToDoID != todo.ID {
+		t.Errorf("AddItem returned %+v", added[1])
+	}
+	if _, err := s.AddItem(todo.ID, Alice, "   "); !errors.Is(err, models.ErrInvalidItem) {
+		t.Errorf("AddItem with a blank title: want ErrInvalidItem, got %v", err)
+	}
+
+	if _, err := s.SetItemDone(todo.ID, added[0].ID, Alice, true); err != nil {
+		t.Fatalf("SetItemDone: %v", err)
+	}
+	if err := s.DeleteItem(todo.ID, added[2].ID, Alice); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	items, err := s.Items(todo.ID, Alice)
+	if err != nil {
+		t.Fatalf("Items: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != added[0].ID || !items[0].Done || items[1].ID != added[1].ID || items[1].Done {
+		t.Fatalf("Items = %+v", items)
+	}
+	got := mustGet(t, s, todo.ID, Alice)
+	if got.ItemsDone != 1 || got.ItemsTotal != 2 || got.Progress() != "1/2" {
+		t.Errorf("counts = %d/%d, Progress %q; want 1/2", got.ItemsDone, got.ItemsTotal, got.Progress())
+	}
+	if all := mustGetAll(t, s, Alice); all[0].ItemsTotal != 2 {
+		t.Errorf("GetAll dropped the item counts: %+v", all[0])
+	}
+
+	if _, err := s.SetItemDone(todo.ID, added[2].ID, Alice, true); !errors.Is(err, models.ErrItemNotFound) {
+		t.Errorf("SetItemDone on a deleted item: want ErrItemNotFound, got %v", err)
+	}
+	other := mustCreate(t, s, Alice, "other")
+	if err := s.DeleteItem(other.ID, added[0].ID, Alice); !errors.Is(err, models.ErrItemNotFound) {
+		t.Errorf("DeleteItem via the wrong todo: want ErrItemNotFound, got %v", err)
+	}
+
+	// Deleting the todo takes its items along.
+	if err := s.Delete(todo.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Items(todo.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Items of a deleted todo: want ErrNotFound, got %v", err)
+	}
+}
+
+func testChecklistCompletion(t *testing.T, s models.ToDoStore) {
+	todo := mustCreate(t, s, Alice, "move house")
+	a, _ := s.AddItem(todo.ID, Alice, "pack")
+	b, _ := s.AddItem(todo.ID, Alice, "clean")
+
+	// Checking every item leaves the todo open.
+	s.SetItemDone(todo.ID, a.ID, Alice, true)
+	s.SetItemDone(todo.ID, b.ID, Alice, true)
+	if mustGet(t, s, todo.ID, Alice).Completed {
+		t.Fatal("checking every item completed the todo")
+	}
+	s.SetItemDone(todo.ID, b.ID, Alice, false)
+
+	// Completing the todo checks every item.
+	in := todo.Input()
+	in.Completed = true
+	done, err := s.Update(todo.ID, in, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if done.ItemsDone != 2 || done.ItemsTotal != 2 {
+		t.Errorf("after completing, counts = %d/%d, want 2/2", done.ItemsDone, done.ItemsTotal)
+	}
+	items, _ := s.Items(todo.ID, Alice)
+	for _, it := range items {
+		if !it.Done {
+			t.Errorf("item %q still open after completing its todo", it.Title)
+		}
+	}
+
+	// Unchecking an item reopens it.
+	if _, err := s.SetItemDone(todo.ID, a.ID, Alice, false); err != nil {
+		t.Fatalf("SetItemDone: %v", err)
+	}
+	if mustGet(t, s, todo.ID, Alice).Completed {
+		t.Error("unchecking an item left its todo completed")
+	}
+
+	// So does adding one.
+	s.Update(todo.ID, in, Alice)
+	if _, err := s.AddItem(todo.ID, Alice, "hand over keys"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice); got.Completed || got.Progress() != "2/3" {
+		t.Errorf("after adding an item: completed=%v progress=%q, want open 2/3", got.Completed, got.Progress())
+	}
+}
+
+func testChecklistIsolation(t *testing.T, s models.ToDoStore) {
+	todo := mustCreate(t, s, Alice, "secret")
+	it, err := s.AddItem(todo.ID, Alice, "step")
+	if err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if _, err := s.Items(todo.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Items as other user: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.AddItem(todo.ID, Bob, "sneaky"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("AddItem as other user: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.SetItemDone(todo.ID, it.ID, Bob, true); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("SetItemDone as other user: want ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteItem(todo.ID, it.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("DeleteItem as other user: want ErrNotFound, got %v", err)
+	}
+}
+
+func testRecurrence(t *testing.T, s models.ToDoStore) {
+	due := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC) // a Monday
+	in := models.ToDoInput{Title: "weekly report", DueAt: &due, DueTZ: "UTC"}
+	if err := in.SetRecurrence("FREQ=WEEKLY;BYDAY=MO"); err != nil {
+		t.Fatalf("SetRecurrence: %v", err)
+	}
+	todo, err := s.Create(Alice, in)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Fatalf("Recurrence = %q", todo.Recurrence)
+	}
+	s.Tag(todo.ID, Alice, "work")
+	s.AddItem(todo.ID, Alice, "collect numbers")
+
+	in = mustGet(t, s, todo.ID, Alice).Input()
+	in.Completed = true
+	done, err := s.Update(todo.ID, in, Alice)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	next := done.Spawned
+	if next == nil {
+		t.Fatalf("completing a recurring todo spawned nothing")
+	}
+	if next.Completed || next.Title != "weekly report" || next.Recurrence != todo.Recurrence {
+		t.Errorf("next occurrence %+v", next)
+	}
+	if next.DueAt == nil || !next.DueAt.Equal(due.AddDate(0, 0, 7)) {
+		t.Errorf("next due = %v, want %v", next.DueAt, due.AddDate(0, 0, 7))
+	}
+	if !equalStrings(next.Tags, []string{"work"}) || next.Progress() != "0/1" {
+		t.Errorf("next occurrence tags %v, checklist %q; want [work], 0/1", next.Tags, next.Progress())
+	}
+	if got := mustGet(t, s, next.ID, Alice); got.Version != next.Version || !equalStrings(got.Tags, next.Tags) {
+		t.Errorf("spawned %+v, stored %+v", next, got)
+	}
+
+	// The rule moved to the new todo in the same change, so what Update
+	// returned is current and completing the old one again spawns nothing.
+	old := mustGet(t, s, todo.ID, Alice)
+	if done.Recurrence != "" || old.Recurrence != "" || old.Version != done.Version {
+		t.Errorf("completed occurrence: returned %+v, stored %+v", done, old)
+	}
+	in = old.Input()
+	in.Completed = false
+	if _, err := s.Update(todo.ID, in, Alice); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	in.Completed = true
+	if again, err := s.Update(todo.ID, in, Alice); err != nil || again.Spawned != nil {
+		t.Errorf("completing again = %+v, %v; want nothing spawned", again, err)
+	}
+	if n := len(mustGetAll(t, s, Alice)); n != 2 {
+		t.Errorf("have %d todos, want 2", n)
+	}
+
+	// Bulk completion spawns the same way.
+	bulk, _, err := s.Bulk(Alice, []int{next.ID}, models.BulkAction{Op: models.BulkComplete})
+	if err != nil || len(bulk) != 1 {
+		t.Fatalf("Bulk complete = %v, %v", bulk, err)
+	}
+	third := bulk[0].Spawned
+	if bulk[0].Recurrence != "" || third == nil || third.Recurrence != todo.Recurrence {
+		t.Fatalf("bulk completion left %+v, spawned %+v", bulk[0], third)
+	}
+	if third.DueAt == nil || !third.DueAt.Equal(due.AddDate(0, 0, 14)) {
+		t.Errorf("bulk-spawned due = %v, want %v", third.DueAt, due.AddDate(0, 0, 14))
+	}
+	if got := mustGet(t, s, next.ID, Alice); got.Recurrence != "" {
+		t.Errorf("bulk-completed occurrence kept its rule %q", got.Recurrence)
+	}
+}
+
+func testNotes(t *testing.T, s models.ToDoStore) {
+	notes := "Call **Bob** first.\n\n- agenda\n- [minutes](https://example.com)"
+	todo, err := s.Create(Alice, models.ToDoInput{Title: "plan meeting", Notes: notes})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice).Notes; got != notes {
+		t.Errorf("Notes = %q, want %q", got, notes)
+	}
+
+	in := todo.Input()
+	in.Notes = ""
+	if _, err := s.Update(todo.ID, in, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := mustGet(t, s, todo.ID, Alice).Notes; got != "" {
+		t.Errorf("Notes after clearing = %q", got)
+	}
+}
+
+func testMove(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a").ID
+	b := mustCreate(t, s, Alice, "b").ID
+	c := mustCreate(t, s, Alice, "c").ID
+	d := mustCreate(t, s, Alice, "d").ID
+	move := func(id, before, after int, want ...int) {
+		t.Helper()
+		if _, err := s.Move(id, Alice, before, after); err != nil {
+			t.Fatalf("Move(%d, before %d, after %d): %v", id, before, after, err)
+		}
+		if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, want) {
+			t.Fatalf("after Move(%d, before %d, after %d) order = %v, want %v", id, before, after, got, want)
+		}
+	}
+	move(d, 0, a, a, d, b, c) // after a slots in ahead of b
+	move(b, a, 0, b, a, d, c) // before a becomes first
+	move(c, d, a, b, a, c, d) // between two neighbours
+	move(b, 0, d, a, c, d, b) // after the last becomes last
+
+	// A new todo goes last whatever the manual order.
+	e := mustCreate(t, s, Alice, "e").ID
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, []int{a, c, d, b, e}) {
+		t.Fatalf("after Create order = %v", got)
+	}
+
+	// Halving the same gap over and over runs out of float precision; the
+	// store must renumber rather than fail or scramble the order.
+	for i := 0; i < 100; i++ {
+		move(d, c, a, a, d, c, b, e)
+		move(c, d, a, a, c, d, b, e)
+	}
+
+	// Positions are per list: a new list starts again from 1.
+	work, err := s.CreateList(Alice, "Work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	for i, title := range []string{"Standup", "Review"} {
+		todo, err := s.Create(Alice, models.ToDoInput{ListID: work.ID, Title: title})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if want := float64(i + 1); todo.Position != want {
+			t.Errorf("%q in a new list at position %v, want %v", title, todo.Position, want)
+		}
+	}
+
+	// A todo moved to another list goes last there.
+	inList := func(listID int) []int {
+		t.Helper()
+		todos, err := s.GetAll(Alice, models.ListOptions{ListID: listID})
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		return ids(todos)
+	}
+	in := mustGet(t, s, a, Alice).Input()
+	in.ListID = work.ID
+	if _, err := s.Update(a, in, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := inList(work.ID); len(got) != 3 || got[2] != a {
+		t.Errorf("after moving %d to another list its order = %v", a, got)
+	}
+	inbox, err := s.Inbox(Alice)
+	if err != nil {
+		t.Fatalf("Inbox: %v", err)
+	}
+	if _, _, err := s.Bulk(Alice, []int{a}, models.BulkAction{Op: models.BulkMove}); err != nil {
+		t.Fatalf("Bulk move: %v", err)
+	}
+	if got := inList(inbox.ID); !equalIDs(got, []int{c, d, b, e, a}) {
+		t.Errorf("after moving %d back in bulk the Inbox order = %v", a, got)
+	}
+}
+
+func testMoveErrors(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a").ID
+	b := mustCreate(t, s, Alice, "b").ID
+	work, err := s.CreateList(Alice, "work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	other, err := s.Create(Alice, models.ToDoInput{ListID: work.ID, Title: "elsewhere"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	bobs := mustCreate(t, s, Bob, "bob's").ID
+
+	for _, tc := range []struct {
+		name              string
+		id, before, after int
+		user              string
+		want              error
+	}{
+		{"no anchor", a, 0, 0, Alice, models.ErrInvalidMove},
+		{"itself", a, 0, a, Alice, models.ErrInvalidMove},
+		{"other list", a, other.ID, 0, Alice, models.ErrInvalidMove},
+		{"anchors out of order", a, b, b, Alice, models.ErrInvalidMove},
+		{"missing anchor", a, 0, 999999, Alice, models.ErrNotFound},
+		{"other user's anchor", a, bobs, 0, Alice, models.ErrNotFound},
+		{"other user's todo", b, a, 0, Bob, models.ErrNotFound},
+	} {
+		if _, err := s.Move(tc.id, tc.user, tc.before, tc.after); !errors.Is(err, tc.want) {
+			t.Errorf("%s: want %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func testSearch(t *testing.T, s models.ToDoStore) {
+	create := func(username string, in models.ToDoInput) int {
+		t.Helper()
+		todo, err := s.Create(username, in)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", in.Title, err)
+		}
+		return todo.ID
+	}
+	mustCreate(t, s, Alice, "Buy milk")
+	plumber := create(Alice, models.ToDoInput{Title: "Call plumber", Notes: "About the kitchen sink leak."})
+	renovation := create(Alice, models.ToDoInput{Title: "Kitchen renovation"})
+	sink := create(Alice, models.ToDoInput{Title: "Fix sink", Notes: "Kitchen, under the window", Completed: true})
+	work, err := s.CreateList(Alice, "work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	tiles := create(Alice, models.ToDoInput{ListID: work.ID, Title: "Order kitchen tiles"})
+	create(Bob, models.ToDoInput{Title: "Bob's kitchen"})
+
+	search := func(query string, opts models.ListOptions) []int {
+		t.Helper()
+		todos, err := s.Search(Alice, query, opts)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		return ids(todos)
+	}
+	sameSet := func(got, want []int) bool {
+		return equalIDs(sortedIDs(got), sortedIDs(want))
+	}
+
+	// Title matches outrank notes matches; completed todos are included.
+	got := search("kitchen", models.ListOptions{})
+	if !sameSet(got, []int{plumber, renovation, sink, tiles}) {
+		t.Fatalf("Search(kitchen) = %v", got)
+	}
+	if got[0] != renovation && got[0] != tiles || got[3] != plumber && got[3] != sink {
+		t.Errorf("Search(kitchen) ranked %v; title matches should come first", got)
+	}
+	if got := search("KIT", models.ListOptions{}); !sameSet(got, []int{plumber, renovation, sink, tiles}) {
+		t.Errorf("prefix Search(KIT) = %v", got)
+	}
+	if got := search("sink, kitchen!", models.ListOptions{}); !equalIDs(got, []int{sink, plumber}) {
+		t.Errorf("Search(sink kitchen) = %v, want [%d %d]", got, sink, plumber)
+	}
+	// Words match as written: no stopwords, no stemming.
+	if got := search("the", models.ListOptions{}); !sameSet(got, []int{plumber, sink}) {
+		t.Errorf("Search(the) = %v, want [%d %d]", got, plumber, sink)
+	}
+	if got := search("renovating", models.ListOptions{}); len(got) != 0 {
+		t.Errorf("Search(renovating) = %v, want nothing", got)
+	}
+	if got := search("kitchen", models.ListOptions{ListID: work.ID}); !equalIDs(got, []int{tiles}) {
+		t.Errorf("Search in list = %v, want [%d]", got, tiles)
+	}
+	for _, q := range []string{"", "  ", "&|!:*", "submarine"} {
+		if got := search(q, models.ListOptions{}); len(got) != 0 {
+			t.Errorf("Search(%q) = %v, want nothing", q, got)
+		}
+	}
+}
+
+func sortedIDs(ids []int) []int {
+	out := append([]int(nil), ids...)
+	sort.Ints(out)
+	return out
+}
+
+func testPaging(t *testing.T, s models.ToDoStore) {
+	day := func(n int) *time.Time {
+		d := time.Date(2030, 1, n, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	for i, in := range []models.ToDoInput{
+		{Title: "a", Priority: models.PriorityHigh},
+		{Title: "b", DueAt: day(3)},
+		{Title: "c", Priority: models.PriorityLow, DueAt: day(3), Completed: true},
+		{Title: "d"},
+		{Title: "e", Priority: models.PriorityHigh, DueAt: day(1)},
+		{Title: "f", DueAt: day(2), Completed: true},
+		{Title: "g", Priority: models.PriorityLow},
+	} {
+		in.DueTZ = "UTC"
+		if _, err := s.Create(Alice, in); err != nil {
+			t.Fatalf("Create #%d: %v", i, err)
+		}
+	}
+	mustCreate(t, s, Bob, "bob's")
+
+	for _, sort := range []models.SortOrder{models.SortManual, models.SortPriority, models.SortDue, models.SortCreated} {
+		for _, status := range []models.Status{models.StatusAll, models.StatusActive, models.StatusCompleted} {
+			opts := models.ListOptions{Sort: sort, Status: status}
+			want, err := s.GetAll(Alice, opts)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			var got []*models.ToDo
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatalf("sort=%s status=%q: paging doesn't end", sort, status)
+				}
+				page, err := s.GetPage(Alice, opts, 3, cursor)
+				if err != nil {
+					t.Fatalf("sort=%s status=%q: GetPage: %v", sort, status, err)
+				}
+				if len(page.Todos) > 3 {
+					t.Fatalf("sort=%s: page of %d todos, limit 3", sort, len(page.Todos))
+				}
+				got = append(got, page.Todos...)
+				if page.Next == "" {
+					break
+				}
+				cursor = page.Next
+			}
+			if !equalIDs(ids(got), ids(want)) {
+				t.Errorf("sort=%s status=%q: pages = %v, want %v", sort, status, ids(got), ids(want))
+			}
+		}
+	}
+
+	active, err := s.GetAll(Alice, models.ListOptions{Status: models.StatusActive})
+	if err != nil || len(active) != 5 {
+		t.Fatalf("active = %d todos, %v; want 5", len(active), err)
+	}
+	for _, todo := range active {
+		if todo.Completed {
+			t.Errorf("status=active returned completed %q", todo.Title)
+		}
+	}
+
+	// A todo added after the first page was read shows up once, at the end.
+	first, err := s.GetPage(Alice, models.ListOptions{}, 4, "")
+	if err != nil || first.Next == "" {
+		t.Fatalf("first page: %+v, %v", first, err)
+	}
+	late := mustCreate(t, s, Alice, "late")
+	rest, err := s.GetPage(Alice, models.ListOptions{}, 0, first.Next)
+	if err != nil {
+		t.Fatalf("rest: %v", err)
+	}
+	if n := len(rest.Todos); n != 4 || rest.Todos[n-1].ID != late.ID || rest.Next != "" {
+		t.Errorf("rest after a create = %v, next %q", ids(rest.Todos), rest.Next)
+	}
+
+	for _, bad := range []string{"nonsense", "e30"} {
+		if _, err := s.GetPage(Alice, models.ListOptions{}, 3, bad); !errors.Is(err, models.ErrInvalidCursor) {
+			t.Errorf("cursor %q: want ErrInvalidCursor, got %v", bad, err)
+		}
+	}
+	if _, err := s.GetPage(Alice, models.ListOptions{Sort: models.SortDue}, 3, first.Next); !errors.Is(err, models.ErrInvalidCursor) {
+		t.Errorf("cursor from another sort: want ErrInvalidCursor, got %v", err)
+	}
+}
+
+func testTrash(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	b := mustCreate(t, s, Alice, "b")
+	c := mustCreate(t, s, Alice, "c")
+	if err := s.Tag(a.ID, Alice, "home"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if _, err := s.AddItem(a.ID, Alice, "step"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if _, err := s.Update(c.ID, models.ToDoInput{Title: "c", Completed: true}, Alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if err := s.Delete(a.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.ClearCompleted(Alice, 0); err != nil {
+		t.Fatalf("ClearCompleted: %v", err)
+	}
+	if got := ids(mustGetAll(t, s, Alice)); !equalIDs(got, []int{b.ID}) {
+		t.Fatalf("GetAll = %v, want [%d]", got, b.ID)
+	}
+	if hits, err := s.Search(Alice, "a", models.ListOptions{}); err != nil || len(hits) != 0 {
+		t.Errorf("Search found trashed todos: %v, %v", ids(hits), err)
+	}
+	if tags, err := s.Tags(Alice); err != nil || len(tags) != 0 {
+		t.Errorf("Tags counts trashed todos: %v, %v", tags, err)
+	}
+	if _, err := s.Items(a.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Items of a trashed todo: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.Update(a.ID, models.ToDoInput{Title: "x"}, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Update of a trashed todo: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.Move(b.ID, Alice, a.ID, 0); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Move next to a trashed todo: want ErrNotFound, got %v", err)
+	}
+
+	trash, err := s.Trash(Alice)
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if got := sortedIDs(ids(trash)); !equalIDs(got, []int{a.ID, c.ID}) {
+		t.Fatalf("Trash = %v, want [%d %d]", got, a.ID, c.ID)
+	}
+	for _, todo := range trash {
+		if todo.DeletedAt == nil || time.Since(*todo.DeletedAt) > clockSlack {
+			t.Errorf("trashed todo %d has DeletedAt %v", todo.ID, todo.DeletedAt)
+		}
+	}
+	if other, err := s.Trash(Bob); err != nil || len(other) != 0 {
+		t.Errorf("Trash(bob) = %v, %v", ids(other), err)
+	}
+
+	// Restoring brings everything back.
+	if _, err := s.Restore(a.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Restore as other user: want ErrNotFound, got %v", err)
+	}
+	got, err := s.Restore(a.ID, Alice)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got.DeletedAt != nil || !equalStrings(got.Tags, []string{"home"}) || got.ItemsTotal != 1 {
+		t.Errorf("restored todo = %+v", got)
+	}
+	if _, err := s.Restore(a.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Restore of a live todo: want ErrNotFound, got %v", err)
+	}
+	if all := ids(mustGetAll(t, s, Alice)); !equalIDs(all, []int{a.ID, b.ID}) {
+		t.Errorf("GetAll after Restore = %v, want [%d %d]", all, a.ID, b.ID)
+	}
+
+	// Purging only works on the trash, and is final.
+	if err := s.Purge(b.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Purge of a live todo: want ErrNotFound, got %v", err)
+	}
+	if err := s.Purge(c.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Purge as other user: want ErrNotFound, got %v", err)
+	}
+	if err := s.Purge(c.ID, Alice); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if _, err := s.Restore(c.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Restore after Purge: want ErrNotFound, got %v", err)
+	}
+	if trash, err := s.Trash(Alice); err != nil || len(trash) != 0 {
+		t.Errorf("Trash after Purge = %v, %v", ids(trash), err)
+	}
+}
+
+func testPurgeDeleted(t *testing.T, s models.ToDoStore) {
+	old := mustCreate(t, s, Alice, "old")
+	theirs := mustCreate(t, s, Bob, "bob's")
+	live := mustCreate(t, s, Alice, "live")
+	if err := s.Delete(old.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(theirs.ID, Bob); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if purged, err := s.PurgeDeleted(time.Now().Add(-time.Hour)); err != nil || len(purged) != 0 {
+		t.Fatalf("PurgeDeleted(an hour ago) = %v, %v; want none", purged, err)
+	}
+	purged, err := s.PurgeDeleted(time.Now().Add(clockSlack))
+	if err != nil {
+		t.Fatalf("PurgeDeleted(now): %v", err)
+	}
+	sort.Slice(purged, func(i, j int) bool { return purged[i].ID < purged[j].ID })
+	want := []models.PurgedToDo{{ID: old.ID, Username: Alice}, {ID: theirs.ID, Username: Bob}}
+	if len(purged) != 2 || purged[0] != want[0] || purged[1] != want[1] {
+		t.Fatalf("PurgeDeleted(now) = %v, want %v", purged, want)
+	}
+	for _, user := range []string{Alice, Bob} {
+		if trash, err := s.Trash(user); err != nil || len(trash) != 0 {
+			t.Errorf("Trash(%s) after PurgeDeleted = %v, %v", user, ids(trash), err)
+		}
+	}
+	if all := ids(mustGetAll(t, s, Alice)); !equalIDs(all, []int{live.ID}) {
+		t.Errorf("PurgeDeleted touched live todos: %v", all)
+	}
+}
+
+func testBulk(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	b := mustCreate(t, s, Alice, "b")
+	c := mustCreate(t, s, Alice, "c")
+	if _, err := s.AddItem(a.ID, Alice, "step"); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	bulk := func(ids []int, a models.BulkAction) []*models.ToDo {
+		t.Helper()
+		todos, _, err := s.Bulk(Alice, ids, a)
+		if err != nil {
+			t.Fatalf("Bulk(%v, %s): %v", ids, a.Op, err)
+		}
+		return todos
+	}
+
+	// Completing checks checklists; duplicates and todos already done are
+	// skipped.
+	if got := bulk([]int{b.ID, a.ID, b.ID}, models.BulkAction{Op: models.BulkComplete}); !equalIDs(ids(got), []int{a.ID, b.ID}) {
+		t.Fatalf("complete changed %v, want [%d %d]", ids(got), a.ID, b.ID)
+	}
+	if got := mustGet(t, s, a.ID, Alice); !got.Completed || got.ItemsDone != 1 || got.Version != 2 {
+		t.Errorf("after bulk complete: %+v", got)
+	}
+	if got := bulk([]int{a.ID, c.ID}, models.BulkAction{Op: models.BulkComplete}); !equalIDs(ids(got), []int{c.ID}) {
+		t.Errorf("second complete changed %v, want [%d]", ids(got), c.ID)
+	}
+	// Bulk also hands back what it changed as it was.
+	got, before, err := s.Bulk(Alice, []int{a.ID, b.ID}, models.BulkAction{Op: models.BulkReopen})
+	if err != nil || len(got) != 2 || got[0].Completed || got[1].Completed {
+		t.Fatalf("reopen returned %+v, %v", got, err)
+	}
+	if len(before) != 2 || before[0].ID != a.ID || !before[0].Completed || before[1].Version != got[1].Version-1 {
+		t.Errorf("reopen's before = %+v", before)
+	}
+
+	// Moving resolves 0 to the Inbox.
+	work, err := s.CreateList(Alice, "Work")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if got := bulk([]int{a.ID, b.ID}, models.BulkAction{Op: models.BulkMove, ListID: work.ID}); len(got) != 2 || got[0].ListID != work.ID {
+		t.Errorf("move returned %+v", got)
+	}
+	inbox, _ := s.Inbox(Alice)
+	if got := bulk([]int{a.ID, c.ID}, models.BulkAction{Op: models.BulkMove}); !equalIDs(ids(got), []int{a.ID}) || got[0].ListID != inbox.ID {
+		t.Errorf("move to the Inbox returned %+v", got)
+	}
+
+	// Tagging merges with what's there.
+	if err := s.Tag(a.ID, Alice, "home"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	got = bulk([]int{a.ID, b.ID}, models.BulkAction{Op: models.BulkTag, Tags: []string{"#Home", "urgent"}})
+	if len(got) != 2 || !equalStrings(got[0].Tags, []string{"home", "urgent"}) || !equalStrings(got[1].Tags, []string{"home", "urgent"}) {
+		t.Errorf("tag returned %+v", got)
+	}
+	if again := bulk([]int{a.ID}, models.BulkAction{Op: models.BulkTag, Tags: []string{"urgent"}}); len(again) != 0 {
+		t.Errorf("re-tagging changed %v", ids(again))
+	}
+
+	// Setting a due date, then clearing it.
+	var due models.BulkAction
+	due.Op = models.BulkSetDue
+	if err := due.SetDue("2030-05-01", "09:30", "Europe/Paris"); err != nil {
+		t.Fatalf("SetDue: %v", err)
+	}
+	got = bulk([]int{b.ID, c.ID}, due)
+	if len(got) != 2 || got[0].DueDate() != "2030-05-01" || got[0].DueTime() != "09:30" || got[1].DueTZ != "Europe/Paris" {
+		t.Errorf("set due returned %+v", got)
+	}
+	if again := bulk([]int{b.ID, c.ID}, due); len(again) != 0 {
+		t.Errorf("setting the same due date changed %v", ids(again))
+	}
+	got = bulk([]int{b.ID}, models.BulkAction{Op: models.BulkSetDue})
+	if len(got) != 1 || got[0].DueAt != nil || got[0].DueTZ != "" {
+		t.Errorf("clearing due returned %+v", got)
+	}
+
+	// Deleting moves to the trash.
+	if got := bulk([]int{a.ID, c.ID}, models.BulkAction{Op: models.BulkDelete}); len(got) != 2 || got[0].DeletedAt == nil {
+		t.Errorf("delete returned %+v", got)
+	}
+	if all := ids(mustGetAll(t, s, Alice)); !equalIDs(all, []int{b.ID}) {
+		t.Errorf("GetAll after bulk delete = %v, want [%d]", all, b.ID)
+	}
+	if trash, err := s.Trash(Alice); err != nil || len(trash) != 2 {
+		t.Errorf("Trash after bulk delete = %v, %v", ids(trash), err)
+	}
+}
+
+func testBulkErrors(t *testing.T, s models.ToDoStore) {
+	a := mustCreate(t, s, Alice, "a")
+	theirs := mustCreate(t, s, Bob, "theirs")
+	gone := mustCreate(t, s, Alice, "gone")
+	if err := s.Delete(gone.ID, Alice); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// One bad ID and nothing changes.
+	for _, id := range []int{theirs.ID, gone.ID, 987654} {
+		if _, _, err := s.Bulk(Alice, []int{a.ID, id}, models.BulkAction{Op: models.BulkComplete}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("Bulk with todo %d: want ErrNotFound, got %v", id, err)
+		}
+	}
+	if got := mustGet(t, s, a.ID, Alice); got.Completed || got.Version != 1 {
+		t.Errorf("a failed Bulk changed the todo: %+v", got)
+	}
+
+	if _, _, err := s.Bulk(Alice, []int{a.ID}, models.BulkAction{Op: models.BulkMove, ListID: 987654}); !errors.Is(err, models.ErrListNotFound) {
+		t.Errorf("move to a missing list: want ErrListNotFound, got %v", err)
+	}
+	tooMany := make([]int, models.MaxBulk+1)
+	for i := range tooMany {
+		tooMany[i] = i + 1
+	}
+	for name, tc := range map[string]struct {
+		ids []int
+		a   models.BulkAction
+	}{
+		"unknown op": {[]int{a.ID}, models.BulkAction{Op: "archive"}},
+		"no todos":   {nil, models.BulkAction{Op: models.BulkComplete}},
+		"too many":   {tooMany, models.BulkAction{Op: models.BulkComplete}},
+		"no tags":    {[]int{a.ID}, models.BulkAction{Op: models.BulkTag}},
+	} {
+		if _, _, err := s.Bulk(Alice, tc.ids, tc.a); !errors.Is(err, models.ErrInvalidBulk) {
+			t.Errorf("%s: want ErrInvalidBulk, got %v", name, err)
+		}
+	}
+	if _, _, err := s.Bulk(Alice, []int{a.ID}, models.BulkAction{Op: models.BulkTag, Tags: []string{"no spaces"}}); !errors.Is(err, models.ErrInvalidTag) {
+		t.Errorf("bad tag: want ErrInvalidTag, got %v", err)
+	}
+}
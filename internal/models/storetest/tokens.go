@@ -0,0 +1,101 @@
+package storetest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gjb1088/To-Do-list/internal/models"
+)
+
+// TokenStoreFactory returns a TokenStore with no tokens in which Alice and
+// Bob exist as users.
+type TokenStoreFactory func(t *testing.T) models.TokenStore
+
+// RunTokenStoreTests runs the TokenStore contract against stores built by
+// newStore.
+func RunTokenStoreTests(t *testing.T, newStore TokenStoreFactory) {
+	t.Run("CreateAndAuthenticate", func(t *testing.T) {
+		s := newStore(t)
+		secret, tok, err := s.Create(Alice, "ci", nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if tok.ID <= 0 || tok.Name != "ci" || tok.ExpiresAt != nil || tok.LastUsedAt != nil {
+			t.Fatalf("unexpected token %+v", tok)
+		}
+		if len(secret) < 32 || strings.Contains(secret, " ") {
+			t.Fatalf("weak-looking secret %q", secret)
+		}
+
+		user, err := s.Authenticate(secret)
+		if err != nil || user != Alice {
+			t.Fatalf("Authenticate = %q, %v; want %q", user, err, Alice)
+		}
+		if _, err := s.Authenticate(secret + "x"); !errors.Is(err, models.ErrInvalidToken) {
+			t.Fatalf("wrong secret: want ErrInvalidToken, got %v", err)
+		}
+
+		tokens, err := s.List(Alice)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+			t.Fatalf("List should show the token as used: %+v", tokens)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		s := newStore(t)
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		expired, _, err := s.Create(Alice, "old", &past)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		live, tok, err := s.Create(Alice, "new", &future)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if tok.ExpiresAt == nil || tok.ExpiresAt.Sub(future).Abs() > time.Second {
+			t.Fatalf("ExpiresAt = %v, want %v", tok.ExpiresAt, future)
+		}
+		if _, err := s.Authenticate(expired); !errors.Is(err, models.ErrInvalidToken) {
+			t.Fatalf("expired token: want ErrInvalidToken, got %v", err)
+		}
+		if user, err := s.Authenticate(live); err != nil || user != Alice {
+			t.Fatalf("live token: got %q, %v", user, err)
+		}
+	})
+
+	t.Run("RevokeAndIsolation", func(t *testing.T) {
+		s := newStore(t)
+		secret, tok, err := s.Create(Alice, "laptop", nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, _, err := s.Create(Bob, "bob's", nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		if tokens, _ := s.List(Bob); len(tokens) != 1 || tokens[0].Name != "bob's" {
+			t.Fatalf("List(bob) leaked other tokens: %+v", tokens)
+		}
+		if err := s.Revoke(tok.ID, Bob); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("Revoke as other user: want ErrNotFound, got %v", err)
+		}
+		if err := s.Revoke(tok.ID, Alice); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if _, err := s.Authenticate(secret); !errors.Is(err, models.ErrInvalidToken) {
+			t.Fatalf("revoked token: want ErrInvalidToken, got %v", err)
+		}
+		if err := s.Revoke(tok.ID, Alice); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("second Revoke: want ErrNotFound, got %v", err)
+		}
+		if tokens, _ := s.List(Alice); len(tokens) != 0 {
+			t.Fatalf("revoked token still listed: %+v", tokens)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidTag is returned for tag names that are empty, too long or
+// contain characters other than letters, digits, '-' and '_'.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// MaxTagLen is the longest tag name, in characters.
+const MaxTagLen = 32
+
+// TagCount is one of a user's tags and how many of their todos carry it.
+type TagCount struct {
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count"`
+}
+
+// TagMatch says whether a tag filter wants todos with any or all of the
+// listed tags.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
+
+// ParseTagMatch validates a match mode from a query string. An empty
+// string means TagMatchAny.
+func ParseTagMatch(s string) (TagMatch, error) {
+	switch m := TagMatch(s); m {
+	case "":
+		return TagMatchAny, nil
+	case TagMatchAny, TagMatchAll:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: match must be any or all, not %q", ErrInvalidTag, s)
+}
+
+// NormalizeTag lower-cases name and strips surrounding space and a leading
+// '#', then checks what is left is a valid tag.
+func NormalizeTag(name string) (string, error) {
+	tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLen {
+		return "", fmt.Errorf("%w %q: must be 1-%d characters", ErrInvalidTag, name, MaxTagLen)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", fmt.Errorf("%w %q: only letters, digits, '-' and '_' are allowed", ErrInvalidTag, name)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every name and returns them sorted, without
+// duplicates.
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	tags := []string{}
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// ParseTags splits free text such as "ops, billing #urgent" on commas and
+// whitespace and normalizes the pieces.
+func ParseTags(s string) ([]string, error) {
+	return NormalizeTags(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}))
+}
+
+// StringList is a list of strings that Postgres hands back as a JSON array
+// (see json_agg in todo_pg.go). It always encodes as an array, never null.
+type StringList []string
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = StringList{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("StringList: cannot scan %T", src)
+	}
+	var out []string
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("StringList: %w", err)
+	}
+	if out == nil {
+		out = []string{}
+	}
+	*l = out
+	return nil
+}
+
+// String joins the tags the way the add and edit forms accept them.
+func (l StringList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l StringList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.orEmpty())
+}
+
+func (l StringList) orEmpty() []string {
+	if l == nil {
+		return []string{}
+	}
+	return l
+}
+
+// HasTag reports whether the todo carries tag.
+func (t *ToDo) HasTag(tag string) bool {
+	for _, have := range t.Tags {
+		if have == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTags reports whether t passes o's tag filter.
+func (o ListOptions) matchesTags(t *ToDo) bool {
+	if len(o.Tags) == 0 {
+		return true
+	}
+	hits := 0
+	for _, tag := range o.Tags {
+		if t.HasTag(tag) {
+			hits++
+		}
+	}
+	if o.TagMatch == TagMatchAll {
+		return hits == len(o.Tags)
+	}
+	return hits > 0
+}
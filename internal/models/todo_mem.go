@@ -0,0 +1,264 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StoreMemory implements ToDoStore entirely in memory. It is safe for
+// concurrent use and is meant for tests and the `-store=memory` dev mode.
+type StoreMemory struct {
+	mu         sync.RWMutex
+	nextID     int
+	todos      map[int]*memToDo
+	trash      map[int]*memToDo // deleted todos, kept out of todos
+	nextListID int
+	lists      map[int]*memList
+	nextItemID int
+	items      map[int]*ChecklistItem
+}
+
+// memToDo pairs a stored ToDo with the user that owns it.
+type memToDo struct {
+	owner string
+	todo  ToDo
+}
+
+// NewStoreMemory returns an empty in-memory ToDoStore.
+func NewStoreMemory() *StoreMemory {
+	return &StoreMemory{
+		nextID:     1,
+		todos:      make(map[int]*memToDo),
+		trash:      make(map[int]*memToDo),
+		nextListID: 1,
+		lists:      make(map[int]*memList),
+		nextItemID: 1,
+		items:      make(map[int]*ChecklistItem),
+	}
+}
+
+// apply copies the editable fields from in onto t.
+func (t *ToDo) apply(in ToDoInput) {
+	t.ListID = in.ListID
+	t.Title = in.Title
+	t.Completed = in.Completed
+	t.Priority = in.Priority
+	t.DueAt = nil
+	if in.DueAt != nil {
+		due := in.DueAt.UTC()
+		t.DueAt = &due
+	}
+	t.DueHasTime = in.DueHasTime
+	t.DueTZ = in.DueTZ
+	t.Recurrence = in.Recurrence
+	t.Notes = in.Notes
+}
+
+// get returns the entry for id if it belongs to username. Callers must hold mu.
+func (s *StoreMemory) get(id int, username string) (*memToDo, error) {
+	e, ok := s.todos[id]
+	if !ok || e.owner != username {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *StoreMemory) GetAll(username string, opts ListOptions) ([]*ToDo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todos := []*ToDo{}
+	for _, e := range s.todos {
+		if e.owner != username || !opts.matches(&e.todo) {
+			continue
+		}
+		t := e.todo
+		todos = append(todos, &t)
+	}
+	sort.Slice(todos, func(i, j int) bool { return opts.less(todos[i], todos[j]) })
+	return todos, nil
+}
+
+func (s *StoreMemory) Get(id int, username string) (*ToDo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return nil, err
+	}
+	t := e.todo
+	return &t, nil
+}
+
+func (s *StoreMemory) Create(username string, in ToDoInput) (*ToDo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if in.ListID, err = s.resolveList(username, in.ListID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	e := &memToDo{
+		owner: username,
+		todo: ToDo{
+			ID:        s.nextID,
+			Version:   1,
+			Tags:      StringList{},
+			Position:  s.lastPosition(username, in.ListID) + 1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+	e.todo.apply(in)
+	s.todos[e.todo.ID] = e
+	s.nextID++
+
+	t := e.todo
+	return &t, nil
+}
+
+func (s *StoreMemory) Update(id int, in ToDoInput, username string) (*ToDo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return nil, err
+	}
+	if in.Version != 0 && in.Version != e.todo.Version {
+		return nil, ErrConflict
+	}
+	if in.ListID, err = s.resolveList(username, in.ListID); err != nil {
+		return nil, err
+	}
+	completing := in.Completed && !e.todo.Completed
+	if in.ListID != e.todo.ListID {
+		// it goes last in its new list
+		e.todo.Position = s.lastPosition(username, in.ListID) + 1
+	}
+	e.todo.apply(in)
+	e.todo.Version++
+	e.todo.UpdatedAt = time.Now().UTC()
+	if in.Completed {
+		for _, it := range s.items {
+			if it.ToDoID == id {
+				it.Done = true
+			}
+		}
+		s.countItems(e)
+	}
+	var spawned *ToDo
+	if completing {
+		spawned = s.spawn(e)
+	}
+
+	t := e.todo
+	t.Spawned = spawned
+	return &t, nil
+}
+
+func (s *StoreMemory) Delete(id int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return err
+	}
+	s.discard(e, time.Now().UTC())
+	return nil
+}
+
+func (s *StoreMemory) ClearCompleted(username string, listID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, e := range s.todos {
+		if listID != 0 && e.todo.ListID != listID {
+			continue
+		}
+		if e.owner == username && e.todo.Completed {
+			s.discard(e, now)
+		}
+	}
+	return nil
+}
+
+// Tag and Untag replace the Tags slice rather than editing it in place, so
+// copies handed out earlier never change under their holders.
+
+func (s *StoreMemory) Tag(id int, username string, tags ...string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return err
+	}
+	merged := append(append([]string{}, e.todo.Tags...), tags...)
+	merged, _ = NormalizeTags(merged)
+	if len(merged) != len(e.todo.Tags) {
+		e.todo.Tags = merged
+		e.todo.Version++
+	}
+	return nil
+}
+
+func (s *StoreMemory) Untag(id int, username string, tags ...string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(id, username)
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		drop[tag] = true
+	}
+	kept := StringList{}
+	for _, tag := range e.todo.Tags {
+		if !drop[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) != len(e.todo.Tags) {
+		e.todo.Tags = kept
+		e.todo.Version++
+	}
+	return nil
+}
+
+func (s *StoreMemory) Tags(username string) ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, e := range s.todos {
+		if e.owner != username {
+			continue
+		}
+		for _, tag := range e.todo.Tags {
+			counts[tag]++
+		}
+	}
+	out := []TagCount{}
+	for name, n := range counts {
+		out = append(out, TagCount{Name: name, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
@@ -1,98 +1,349 @@
 package models
 
 import (
-    "github.com/jmoiron/sqlx"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // StorePostgres implements ToDoStore for a PostgreSQL backend.
 type StorePostgres struct {
-    db *sqlx.DB
+	db *sqlx.DB
 }
 
 func NewStorePostgres(db *sqlx.DB) *StorePostgres {
-    return &StorePostgres{db: db}
+	return &StorePostgres{db: db}
+}
+
+// todoColumns is the select list every query scans into a ToDo. The tags
+// come back as a JSON array for StringList to decode.
+const todoColumns = `id, list_id, title, completed, priority, due_at, due_has_time, due_tz, recurrence, notes, position, created_at, updated_at, deleted_at, version,
+       COALESCE((SELECT json_agg(g.name ORDER BY g.name)::text
+                   FROM todo_tags tt
+                   JOIN tags g ON g.id = tt.tag_id
+                  WHERE tt.todo_id = todos.id), '[]') AS tags,
+       (SELECT COUNT(*) FILTER (WHERE c.done) FROM checklist_items c WHERE c.todo_id = todos.id) AS items_done,
+       (SELECT COUNT(*) FROM checklist_items c WHERE c.todo_id = todos.id) AS items_total`
+
+// listWhere builds the WHERE clause selecting username's todos, outside the
+// trash, that pass opts' filters, with its arguments.
+func listWhere(username string, opts ListOptions) (string, []interface{}, error) {
+	where := `WHERE username = $1 AND deleted_at IS NULL`
+	args := []interface{}{username}
+	if opts.ListID != 0 {
+		args = append(args, opts.ListID)
+		where += fmt.Sprintf(` AND list_id = $%d`, len(args))
+	}
+	if opts.Status != StatusAll {
+		args = append(args, opts.Status == StatusCompleted)
+		where += fmt.Sprintf(` AND completed = $%d`, len(args))
+	}
+	if len(opts.Tags) > 0 {
+		tags, err := NormalizeTags(opts.Tags)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, tags)
+		where += ` ` + tagFilter(opts, len(args))
+	}
+	return where, args, nil
+}
+
+// tagFilter returns the SQL condition for opts' tag filter. n is the
+// placeholder number the tag array is bound to.
+func tagFilter(opts ListOptions, n int) string {
+	matching := fmt.Sprintf(`SELECT COUNT(*)
+                   FROM todo_tags tt
+                   JOIN tags g ON g.id = tt.tag_id
+                  WHERE tt.todo_id = todos.id
+                    AND g.name = ANY($%d)`, n)
+	if opts.TagMatch == TagMatchAll {
+		return fmt.Sprintf(`AND (%s) = cardinality($%d)`, matching, n)
+	}
+	return fmt.Sprintf(`AND (%s) > 0`, matching)
+}
+
+// notFound maps sql.ErrNoRows onto ErrNotFound and passes other errors through.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
 }
 
-func (s *StorePostgres) GetAll(username string) ([]*ToDo, error) {
-    var todos []*ToDo
-    err := s.db.Select(
-        &todos,
-        `SELECT id, title, completed, created_at, updated_at
+// mustAffect returns ErrNotFound when an Exec touched no rows.
+func mustAffect(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *StorePostgres) GetAll(username string, opts ListOptions) ([]*ToDo, error) {
+	where, args, err := listWhere(username, opts)
+	if err != nil {
+		return nil, err
+	}
+	todos := []*ToDo{}
+	err = s.db.Select(
+		&todos,
+		`SELECT `+todoColumns+`
            FROM todos
-          WHERE username = $1
-          ORDER BY id`,
-        username,
-    )
-    return todos, err
+          `+where+`
+          `+opts.orderBy(),
+		args...,
+	)
+	return todos, err
 }
 
 func (s *StorePostgres) Get(id int, username string) (*ToDo, error) {
-    var todo ToDo
-    err := s.db.Get(
-        &todo,
-        `SELECT id, title, completed, created_at, updated_at
+	var todo ToDo
+	err := s.db.Get(
+		&todo,
+		`SELECT `+todoColumns+`
            FROM todos
-          WHERE id = $1 AND username = $2`,
-        id, username,
-    )
-    if err != nil {
-        return nil, err
-    }
-    return &todo, nil
-}
-
-func (s *StorePostgres) Create(username, title string) (*ToDo, error) {
-    var t ToDo
-    err := s.db.Get(
-        &t,
-        `INSERT INTO todos (username, title)
-             VALUES ($1, $2)
-         RETURNING id, title, completed, created_at, updated_at`,
-        username, title,
-    )
-    if err != nil {
-        return nil, err
-    }
-    return &t, nil
-}
-
-func (s *StorePostgres) Update(
-    id int, title string, completed bool, username string,
-) (*ToDo, error) {
-    var t ToDo
-    err := s.db.Get(
-        &t,
-        `UPDATE todos
-            SET title     = $1,
-                completed = $2,
-                updated_at = NOW()
-          WHERE id       = $3
-            AND username = $4
-      RETURNING id, title, completed, created_at, updated_at`,
-        title, completed, id, username,
-    )
-    if err != nil {
-        return nil, err
-    }
-    return &t, nil
+          WHERE id = $1 AND username = $2 AND deleted_at IS NULL`,
+		id, username,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &todo, nil
+}
+
+func (s *StorePostgres) Create(username string, in ToDoInput) (*ToDo, error) {
+	listID, err := s.resolveList(username, in.ListID)
+	if err != nil {
+		return nil, err
+	}
+	return insertToDo(s.db, username, listID, in)
+}
+
+// insertToDo adds a todo to list listID, which must be username's, last in
+// its manual order.
+func insertToDo(q sqlx.Queryer, username string, listID int, in ToDoInput) (*ToDo, error) {
+	var t ToDo
+	err := sqlx.Get(
+		q,
+		&t,
+		`INSERT INTO todos (username, list_id, title, completed, priority, due_at, due_has_time, due_tz, recurrence, notes, position)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+                     (SELECT COALESCE(MAX(position), 0) + 1 FROM todos
+                       WHERE username = $1 AND list_id = $2 AND deleted_at IS NULL))
+         RETURNING `+todoColumns,
+		username, listID, in.Title, in.Completed, int(in.Priority), in.DueAt, in.DueHasTime, in.DueTZ, in.Recurrence, in.Notes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *StorePostgres) Update(id int, in ToDoInput, username string) (*ToDo, error) {
+	listID, err := s.resolveList(username, in.ListID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Completing a todo checks its whole checklist.
+	if in.Completed {
+		if _, err := tx.Exec(
+			`UPDATE checklist_items c
+                SET done = TRUE
+               FROM todos t
+              WHERE c.todo_id  = t.id
+                AND t.id       = $1
+                AND t.username = $2
+                AND t.deleted_at IS NULL
+                AND NOT c.done`,
+			id, username,
+		); err != nil {
+			return nil, err
+		}
+	}
+	var t ToDo
+	err = tx.Get(
+		&t,
+		`UPDATE todos
+            SET list_id      = $1,
+                -- moving to another list puts it last there
+                position     = CASE WHEN list_id = $1 THEN position
+                                    ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM todos
+                                           WHERE username = $11 AND list_id = $1 AND deleted_at IS NULL)
+                               END,
+                title        = $2,
+                completed    = $3,
+                priority     = $4,
+                due_at       = $5,
+                due_has_time = $6,
+                due_tz       = $7,
+                -- completing hands the rule on to the next occurrence
+                recurrence   = CASE WHEN $3 AND NOT completed THEN '' ELSE $8 END,
+                notes        = $9,
+                version      = version + 1,
+                updated_at   = NOW()
+          WHERE id       = $10
+            AND username = $11
+            AND deleted_at IS NULL
+            AND ($12 = 0 OR version = $12)
+      RETURNING `+todoColumns,
+		listID, in.Title, in.Completed, int(in.Priority), in.DueAt, in.DueHasTime, in.DueTZ, in.Recurrence, in.Notes, id, username, in.Version,
+	)
+	if errors.Is(err, sql.ErrNoRows) && in.Version != 0 {
+		// missing, or there but at another version
+		if err := ownsToDo(tx, id, username); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if in.Completed && in.Recurrence != "" && t.Recurrence == "" {
+		done := t
+		done.Recurrence = in.Recurrence
+		if t.Spawned, err = spawn(tx, username, &done); err != nil {
+			return nil, err
+		}
+	}
+	return &t, tx.Commit()
 }
 
 func (s *StorePostgres) Delete(id int, username string) error {
-    _, err := s.db.Exec(
-        `DELETE FROM todos
+	return mustAffect(s.db.Exec(
+		`UPDATE todos
+            SET deleted_at = NOW()
           WHERE id       = $1
-            AND username = $2`,
-        id, username,
-    )
-    return err
+            AND username = $2
+            AND deleted_at IS NULL`,
+		id, username,
+	))
 }
 
-func (s *StorePostgres) ClearCompleted(username string) error {
-    _, err := s.db.Exec(
-        `DELETE FROM todos
+func (s *StorePostgres) ClearCompleted(username string, listID int) error {
+	_, err := s.db.Exec(
+		`UPDATE todos
+            SET deleted_at = NOW()
           WHERE completed = TRUE
-            AND username  = $1`,
-        username,
-    )
-    return err
+            AND username  = $1
+            AND ($2 = 0 OR list_id = $2)
+            AND deleted_at IS NULL`,
+		username, listID,
+	)
+	return err
+}
+
+// ownsToDo returns ErrNotFound unless todo id exists outside the trash and
+// belongs to username.
+func ownsToDo(q sqlx.Queryer, id int, username string) error {
+	var one int
+	err := sqlx.Get(q, &one, `SELECT 1 FROM todos WHERE id = $1 AND username = $2 AND deleted_at IS NULL`, id, username)
+	return notFound(err)
+}
+
+func (s *StorePostgres) Tag(id int, username string, tags ...string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ownsToDo(tx, id, username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO tags (username, name)
+         SELECT $1, unnest($2::text[])
+             ON CONFLICT (username, name) DO NOTHING`,
+		username, tags,
+	); err != nil {
+		return err
+	}
+	res, err := tx.Exec(
+		`INSERT INTO todo_tags (todo_id, tag_id)
+         SELECT $1, id FROM tags WHERE username = $2 AND name = ANY($3)
+             ON CONFLICT DO NOTHING`,
+		id, username, tags,
+	)
+	if err := bumpVersion(tx, id, res, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bumpVersion increments todo id's version if the Exec that returned res
+// and err changed any rows.
+func bumpVersion(tx *sqlx.Tx, id int, res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE todos SET version = version + 1 WHERE id = $1`, id)
+	return err
+}
+
+func (s *StorePostgres) Untag(id int, username string, tags ...string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ownsToDo(tx, id, username); err != nil {
+		return err
+	}
+	res, err := tx.Exec(
+		`DELETE FROM todo_tags tt
+          USING tags g
+          WHERE tt.tag_id  = g.id
+            AND tt.todo_id = $1
+            AND g.username = $2
+            AND g.name     = ANY($3)`,
+		id, username, tags,
+	)
+	if err := bumpVersion(tx, id, res, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *StorePostgres) Tags(username string) ([]TagCount, error) {
+	tags := []TagCount{}
+	err := s.db.Select(
+		&tags,
+		`SELECT g.name, COUNT(*) AS count
+           FROM tags g
+           JOIN todo_tags tt ON tt.tag_id = g.id
+           JOIN todos t ON t.id = tt.todo_id AND t.deleted_at IS NULL
+          WHERE g.username = $1
+          GROUP BY g.name
+          ORDER BY g.name`,
+		username,
+	)
+	return tags, err
 }
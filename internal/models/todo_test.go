@@ -1,71 +1,94 @@
 package models
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestStoreCreateAndGet(t *testing.T) {
-	s := NewStore()
-	todo := s.Create("write tests")
+	s := NewStoreMemory()
+	todo, err := s.Create("alice", ToDoInput{Title: "write tests"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if todo.ID != 1 {
 		t.Fatalf("expected ID 1 got %d", todo.ID)
 	}
 	if todo.Title != "write tests" || todo.Completed {
 		t.Fatalf("unexpected todo %+v", todo)
 	}
-	fetched, err := s.Get(todo.ID)
+	fetched, err := s.Get(todo.ID, "alice")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if fetched != todo {
+	if !reflect.DeepEqual(fetched, todo) {
 		t.Fatalf("fetched todo does not match")
 	}
-	all := s.GetAll()
-	if len(all) != 1 || all[0] != todo {
+	all, _ := s.GetAll("alice", ListOptions{})
+	if len(all) != 1 || !reflect.DeepEqual(all[0], todo) {
 		t.Fatalf("GetAll returned %#v", all)
 	}
 }
 
 func TestStoreUpdate(t *testing.T) {
-	s := NewStore()
-	todo := s.Create("initial")
-	updated, err := s.Update(todo.ID, "changed", true)
+	s := NewStoreMemory()
+	todo, _ := s.Create("alice", ToDoInput{Title: "initial"})
+	updated, err := s.Update(todo.ID, ToDoInput{Title: "changed", Completed: true}, "alice")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if updated.Title != "changed" || !updated.Completed {
 		t.Fatalf("update failed: %+v", updated)
 	}
-	fetched, _ := s.Get(todo.ID)
+	fetched, _ := s.Get(todo.ID, "alice")
 	if fetched.Title != "changed" || !fetched.Completed {
 		t.Fatalf("Get after update mismatch: %+v", fetched)
 	}
 }
 
 func TestStoreDelete(t *testing.T) {
-	s := NewStore()
-	a := s.Create("a")
-	b := s.Create("b")
-	if err := s.Delete(a.ID); err != nil {
+	s := NewStoreMemory()
+	a, _ := s.Create("alice", ToDoInput{Title: "a"})
+	b, _ := s.Create("alice", ToDoInput{Title: "b"})
+	if err := s.Delete(a.ID, "alice"); err != nil {
 		t.Fatalf("unexpected delete error: %v", err)
 	}
-	if _, err := s.Get(a.ID); err != ErrNotFound {
+	if _, err := s.Get(a.ID, "alice"); err != ErrNotFound {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
-	all := s.GetAll()
-	if len(all) != 1 || all[0] != b {
+	all, _ := s.GetAll("alice", ListOptions{})
+	if len(all) != 1 || all[0].ID != b.ID {
 		t.Fatalf("unexpected todos after delete: %#v", all)
 	}
 }
 
 func TestStoreClearCompleted(t *testing.T) {
-	s := NewStore()
-	a := s.Create("a")
-	b := s.Create("b")
-	c := s.Create("c")
-	s.Update(a.ID, a.Title, true)
-	s.Update(c.ID, c.Title, true)
-	s.ClearCompleted()
-	all := s.GetAll()
-	if len(all) != 1 || all[0] != b {
+	s := NewStoreMemory()
+	a, _ := s.Create("alice", ToDoInput{Title: "a"})
+	b, _ := s.Create("alice", ToDoInput{Title: "b"})
+	c, _ := s.Create("alice", ToDoInput{Title: "c"})
+	s.Update(a.ID, ToDoInput{Title: a.Title, Completed: true}, "alice")
+	s.Update(c.ID, ToDoInput{Title: c.Title, Completed: true}, "alice")
+	s.ClearCompleted("alice", 0)
+	all, _ := s.GetAll("alice", ListOptions{})
+	if len(all) != 1 || all[0].ID != b.ID {
 		t.Fatalf("expected only b remaining, got %#v", all)
 	}
 }
+
+func TestStoreUserIsolation(t *testing.T) {
+	s := NewStoreMemory()
+	a, _ := s.Create("alice", ToDoInput{Title: "a"})
+	if _, err := s.Get(a.ID, "bob"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for other user, got %v", err)
+	}
+	if _, err := s.Update(a.ID, ToDoInput{Title: "hijacked", Completed: true}, "bob"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound on foreign update, got %v", err)
+	}
+	if err := s.Delete(a.ID, "bob"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound on foreign delete, got %v", err)
+	}
+	if all, _ := s.GetAll("bob", ListOptions{}); len(all) != 0 {
+		t.Fatalf("bob should see no todos, got %#v", all)
+	}
+}
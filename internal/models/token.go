@@ -0,0 +1,32 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// tokenPrefix marks API token secrets so they are easy to recognise (and to
+// scan for in leaked logs or commits).
+const tokenPrefix = "tdl_"
+
+var tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTokenSecret returns a fresh random token secret and its hash.
+func newTokenSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = tokenPrefix + strings.ToLower(tokenEncoding.EncodeToString(b))
+	return secret, hashToken(secret), nil
+}
+
+// hashToken is how secrets are stored and looked up. Tokens are 256 bits of
+// randomness, so an unsalted SHA-256 is enough to make a leaked table useless.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenStoreMemory implements TokenStore in memory.
+type TokenStoreMemory struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[int]*memToken
+}
+
+type memToken struct {
+	owner string
+	hash  string
+	token Token
+}
+
+// NewTokenStoreMemory returns an empty in-memory TokenStore.
+func NewTokenStoreMemory() *TokenStoreMemory {
+	return &TokenStoreMemory{nextID: 1, tokens: make(map[int]*memToken)}
+}
+
+func (s *TokenStoreMemory) Create(username, name string, expiresAt *time.Time) (string, *Token, error) {
+	secret, hash, err := newTokenSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &memToken{
+		owner: username,
+		hash:  hash,
+		token: Token{
+			ID:        s.nextID,
+			Name:      name,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		},
+	}
+	s.tokens[e.token.ID] = e
+	s.nextID++
+
+	t := e.token
+	return secret, &t, nil
+}
+
+func (s *TokenStoreMemory) List(username string) ([]*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []*Token{}
+	for _, e := range s.tokens {
+		if e.owner == username {
+			t := e.token
+			tokens = append(tokens, &t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+func (s *TokenStoreMemory) Revoke(id int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[id]
+	if !ok || e.owner != username {
+		return ErrNotFound
+	}
+	delete(s.tokens, id)
+	return nil
+}
+
+func (s *TokenStoreMemory) Authenticate(secret string) (string, error) {
+	hash := hashToken(secret)
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.tokens {
+		if e.hash != hash {
+			continue
+		}
+		if e.token.Expired(now) {
+			return "", ErrInvalidToken
+		}
+		e.token.LastUsedAt = &now
+		return e.owner, nil
+	}
+	return "", ErrInvalidToken
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenStorePostgres implements TokenStore for a PostgreSQL backend.
+type TokenStorePostgres struct {
+	db *sqlx.DB
+}
+
+func NewTokenStorePostgres(db *sqlx.DB) *TokenStorePostgres {
+	return &TokenStorePostgres{db: db}
+}
+
+func (s *TokenStorePostgres) Create(username, name string, expiresAt *time.Time) (string, *Token, error) {
+	secret, hash, err := newTokenSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	var t Token
+	err = s.db.Get(
+		&t,
+		`INSERT INTO api_tokens (username, name, token_hash, expires_at)
+             VALUES ($1, $2, $3, $4)
+         RETURNING id, name, created_at, expires_at, last_used_at`,
+		username, name, hash, expiresAt,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, &t, nil
+}
+
+func (s *TokenStorePostgres) List(username string) ([]*Token, error) {
+	tokens := []*Token{}
+	err := s.db.Select(
+		&tokens,
+		`SELECT id, name, created_at, expires_at, last_used_at
+           FROM api_tokens
+          WHERE username = $1
+          ORDER BY id`,
+		username,
+	)
+	return tokens, err
+}
+
+func (s *TokenStorePostgres) Revoke(id int, username string) error {
+	return mustAffect(s.db.Exec(
+		`DELETE FROM api_tokens
+          WHERE id       = $1
+            AND username = $2`,
+		id, username,
+	))
+}
+
+func (s *TokenStorePostgres) Authenticate(secret string) (string, error) {
+	var username string
+	err := s.db.Get(
+		&username,
+		`UPDATE api_tokens
+            SET last_used_at = NOW()
+          WHERE token_hash = $1
+            AND (expires_at IS NULL OR expires_at > NOW())
+      RETURNING username`,
+		hashToken(secret),
+	)
+	if err != nil {
+		if notFound(err) == ErrNotFound {
+			return "", ErrInvalidToken
+		}
+		return "", err
+	}
+	return username, nil
+}
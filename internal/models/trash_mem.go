@@ -0,0 +1,84 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// discard moves e to the trash. Callers must hold mu for writing.
+func (s *StoreMemory) discard(e *memToDo, now time.Time) {
+	e.todo.DeletedAt = &now
+	delete(s.todos, e.todo.ID)
+	s.trash[e.todo.ID] = e
+}
+
+// trashed returns the trash entry for id if it belongs to username. Callers
+// must hold mu.
+func (s *StoreMemory) trashed(id int, username string) (*memToDo, error) {
+	e, ok := s.trash[id]
+	if !ok || e.owner != username {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *StoreMemory) Trash(username string) ([]*ToDo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todos := []*ToDo{}
+	for _, e := range s.trash {
+		if e.owner == username {
+			t := e.todo
+			todos = append(todos, &t)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if a, b := *todos[i].DeletedAt, *todos[j].DeletedAt; !a.Equal(b) {
+			return a.After(b)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+	return todos, nil
+}
+
+func (s *StoreMemory) Restore(id int, username string) (*ToDo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.trashed(id, username)
+	if err != nil {
+		return nil, err
+	}
+	e.todo.DeletedAt = nil
+	delete(s.trash, id)
+	s.todos[id] = e
+
+	t := e.todo
+	return &t, nil
+}
+
+func (s *StoreMemory) Purge(id int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.trashed(id, username); err != nil {
+		return err
+	}
+	s.drop(id)
+	return nil
+}
+
+func (s *StoreMemory) PurgeDeleted(cutoff time.Time) ([]PurgedToDo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := []PurgedToDo{}
+	for id, e := range s.trash {
+		if e.todo.DeletedAt.Before(cutoff) {
+			s.drop(id)
+			purged = append(purged, PurgedToDo{ID: id, Username: e.owner})
+		}
+	}
+	return purged, nil
+}
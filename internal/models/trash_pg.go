@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+func (s *StorePostgres) Trash(username string) ([]*ToDo, error) {
+	todos := []*ToDo{}
+	err := s.db.Select(
+		&todos,
+		`SELECT `+todoColumns+`
+           FROM todos
+          WHERE username = $1
+            AND deleted_at IS NOT NULL
+          ORDER BY deleted_at DESC, id DESC`,
+		username,
+	)
+	return todos, err
+}
+
+func (s *StorePostgres) Restore(id int, username string) (*ToDo, error) {
+	var t ToDo
+	err := s.db.Get(
+		&t,
+		`UPDATE todos
+            SET deleted_at = NULL
+          WHERE id       = $1
+            AND username = $2
+            AND deleted_at IS NOT NULL
+      RETURNING `+todoColumns,
+		id, username,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// Purge and PurgeDeleted rely on checklist_items and todo_tags cascading.
+
+func (s *StorePostgres) Purge(id int, username string) error {
+	return mustAffect(s.db.Exec(
+		`DELETE FROM todos
+          WHERE id       = $1
+            AND username = $2
+            AND deleted_at IS NOT NULL`,
+		id, username,
+	))
+}
+
+func (s *StorePostgres) PurgeDeleted(cutoff time.Time) ([]PurgedToDo, error) {
+	purged := []PurgedToDo{}
+	err := s.db.Select(&purged, `DELETE FROM todos WHERE deleted_at < $1 RETURNING id, username`, cutoff)
+	return purged, err
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserStoreMemory implements UserStore in memory, hashing passwords the same
+// way UserStorePostgres does.
+type UserStoreMemory struct {
+	mu     sync.RWMutex
+	hashes map[string][]byte
+}
+
+// NewUserStoreMemory returns an empty in-memory UserStore.
+func NewUserStoreMemory() *UserStoreMemory {
+	return &UserStoreMemory{hashes: make(map[string][]byte)}
+}
+
+func (s *UserStoreMemory) Create(username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hashes[username]; ok {
+		return ErrUserExists
+	}
+	s.hashes[username] = hash
+	return nil
+}
+
+func (s *UserStoreMemory) Authenticate(username, password string) bool {
+	s.mu.RLock()
+	hash, ok := s.hashes[username]
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,6 +25,10 @@ func (s *UserStorePostgres) Create(username, password string) error {
 		`INSERT INTO users(username,password_hash) VALUES($1,$2)`,
 		username, hash,
 	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return ErrUserExists
+	}
 	return err
 }
 
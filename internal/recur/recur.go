@@ -0,0 +1,322 @@
+// Package recur implements the small subset of iCalendar RRULEs that
+// recurring todos use: daily, weekly (optionally on given weekdays),
+// monthly (optionally on a given day) and yearly schedules, each with an
+// interval.
+//
+// Rules are written the RRULE way, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// One non-standard part, "FROM=COMPLETION", counts the interval from when
+// the task was done rather than from its due date, for chores like
+// "water the plants 3 days after the last time".
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned by Parse for rules it can't read.
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+// Freq is how often a rule repeats.
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+	Yearly  Freq = "YEARLY"
+)
+
+// LastDay is the ByMonthDay value for "the last day of the month".
+const LastDay = -1
+
+// MaxInterval caps INTERVAL.
+const MaxInterval = 999
+
+// Rule is a parsed recurrence rule. The zero Rule is invalid; build one
+// with Parse or fill in at least Freq.
+type Rule struct {
+	Freq Freq
+	// Interval is how many days, weeks, months or years apart the
+	// occurrences are; 0 means 1.
+	Interval int
+	// ByDay limits weekly rules to these weekdays.
+	ByDay []time.Weekday
+	// ByMonthDay pins monthly rules to a day of the month (1-31, or
+	// LastDay). Months without that day use their last day. 0 keeps the
+	// day the schedule started on.
+	ByMonthDay int
+	// FromCompletion counts from when the task was completed instead of
+	// from its due date.
+	FromCompletion bool
+}
+
+// dayCodes are the RRULE weekday codes, indexed by time.Weekday.
+var dayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseWeekday reads an RRULE weekday code such as "MO".
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for d, code := range dayCodes {
+		if s == code {
+			return time.Weekday(d), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown weekday %q", ErrInvalid, s)
+}
+
+// WeekdayCode is the RRULE code for d, e.g. "MO".
+func WeekdayCode(d time.Weekday) string { return dayCodes[d] }
+
+// Parse reads a rule such as "FREQ=MONTHLY;BYMONTHDAY=-1". An optional
+// "RRULE:" prefix is allowed.
+func Parse(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	var r Rule
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return Rule{}, fmt.Errorf("%w: %q is not KEY=VALUE", ErrInvalid, part)
+		}
+		if seen[key] {
+			return Rule{}, fmt.Errorf("%w: %s given twice", ErrInvalid, key)
+		}
+		seen[key] = true
+		switch key {
+		case "FREQ":
+			switch f := Freq(val); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				return Rule{}, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalid, val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > MaxInterval {
+				return Rule{}, fmt.Errorf("%w: INTERVAL must be 1-%d", ErrInvalid, MaxInterval)
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				d, err := ParseWeekday(code)
+				if err != nil {
+					return Rule{}, err
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(val)
+			if err != nil || n == 0 || n < LastDay || n > 31 {
+				return Rule{}, fmt.Errorf("%w: BYMONTHDAY must be 1-31 or -1", ErrInvalid)
+			}
+			r.ByMonthDay = n
+		case "FROM":
+			switch val {
+			case "DUE":
+			case "COMPLETION":
+				r.FromCompletion = true
+			default:
+				return Rule{}, fmt.Errorf("%w: FROM must be DUE or COMPLETION", ErrInvalid)
+			}
+		default:
+			return Rule{}, fmt.Errorf("%w: unsupported part %s", ErrInvalid, key)
+		}
+	}
+	return r, r.Validate()
+}
+
+// Validate checks the parts of r fit together.
+func (r Rule) Validate() error {
+	switch {
+	case r.Freq == "":
+		return fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	case len(r.ByDay) > 0 && r.Freq != Weekly:
+		return fmt.Errorf("%w: BYDAY needs FREQ=WEEKLY", ErrInvalid)
+	case r.ByMonthDay != 0 && r.Freq != Monthly:
+		return fmt.Errorf("%w: BYMONTHDAY needs FREQ=MONTHLY", ErrInvalid)
+	case r.FromCompletion && (len(r.ByDay) > 0 || r.ByMonthDay != 0):
+		return fmt.Errorf("%w: FROM=COMPLETION can't be combined with BYDAY or BYMONTHDAY", ErrInvalid)
+	}
+	return nil
+}
+
+// interval is r.Interval with 0 read as 1.
+func (r Rule) interval() int {
+	if r.Interval < 1 {
+		return 1
+	}
+	return r.Interval
+}
+
+// days returns r.ByDay sorted and de-duplicated.
+func (r Rule) days() []time.Weekday {
+	seen := make(map[time.Weekday]bool)
+	var out []time.Weekday
+	for _, d := range r.ByDay {
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// HasDay reports whether r's BYDAY includes the weekday with RRULE code
+// code, e.g. "MO".
+func (r Rule) HasDay(code string) bool {
+	d, err := ParseWeekday(code)
+	return err == nil && containsDay(r.ByDay, d)
+}
+
+// String returns the canonical form of r, e.g. "FREQ=WEEKLY;BYDAY=MO,FR".
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if n := r.interval(); n > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(n))
+	}
+	if days := r.days(); len(days) > 0 {
+		codes := make([]string, len(days))
+		for i, d := range days {
+			codes[i] = WeekdayCode(d)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.FromCompletion {
+		parts = append(parts, "FROM=COMPLETION")
+	}
+	return strings.Join(parts, ";")
+}
+
+// units names Freq's period, singular and plural.
+var units = map[Freq][2]string{
+	Daily:   {"day", "days"},
+	Weekly:  {"week", "weeks"},
+	Monthly: {"month", "months"},
+	Yearly:  {"year", "years"},
+}
+
+// adverbs are the one-word forms of an interval of 1.
+var adverbs = map[Freq]string{Daily: "Daily", Weekly: "Weekly", Monthly: "Monthly", Yearly: "Yearly"}
+
+// Describe renders r for people, e.g. "Every 2 weeks on Mon, Thu" or
+// "3 days after completion".
+func (r Rule) Describe() string {
+	n, unit := r.interval(), units[r.Freq]
+	if r.FromCompletion {
+		if n == 1 {
+			return "1 " + unit[0] + " after completion"
+		}
+		return strconv.Itoa(n) + " " + unit[1] + " after completion"
+	}
+	s := adverbs[r.Freq]
+	if n > 1 {
+		s = "Every " + strconv.Itoa(n) + " " + unit[1]
+	}
+	if days := r.days(); len(days) > 0 {
+		names := make([]string, len(days))
+		for i, d := range days {
+			names[i] = d.String()[:3]
+		}
+		s += " on " + strings.Join(names, ", ")
+	}
+	switch {
+	case r.ByMonthDay == LastDay:
+		s += " on the last day"
+	case r.ByMonthDay > 0:
+		s += " on day " + strconv.Itoa(r.ByMonthDay)
+	}
+	return s
+}
+
+// Next returns the first occurrence after from: a later calendar day in
+// from's location, at from's time of day. For FROM=COMPLETION rules from
+// should be the completion time, otherwise the previous due date.
+func (r Rule) Next(from time.Time) time.Time {
+	n := r.interval()
+	switch r.Freq {
+	case Daily:
+		return from.AddDate(0, 0, n)
+	case Weekly:
+		days := r.days()
+		if len(days) == 0 {
+			return from.AddDate(0, 0, 7*n)
+		}
+		// Weeks start on Monday; only every n-th week from from's counts.
+		start := from.AddDate(0, 0, -mondayOffset(from))
+		for i := 1; ; i++ {
+			d := from.AddDate(0, 0, i)
+			week := daysBetween(start, d) / 7
+			if week%n == 0 && containsDay(days, d.Weekday()) {
+				return d
+			}
+		}
+	case Monthly:
+		day := r.ByMonthDay
+		if day == 0 {
+			day = from.Day()
+		}
+		for k := 0; ; k += n {
+			d := monthDay(from, k, day)
+			if d.After(from) && !sameDate(d, from) {
+				return d
+			}
+		}
+	case Yearly:
+		return monthDay(from, 12*n, from.Day())
+	}
+	return from
+}
+
+// mondayOffset is how many days t is past the Monday of its week.
+func mondayOffset(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// daysBetween counts calendar days from a to b, ignoring DST shifts.
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	ua := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	ub := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+func containsDay(days []time.Weekday, d time.Weekday) bool {
+	for _, x := range days {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// monthDay returns day (or LastDay) of the month k months after t's, at t's
+// time of day, clamping to the month's last day.
+func monthDay(t time.Time, k, day int) time.Time {
+	y, m, _ := t.Date()
+	first := time.Date(y, m+time.Month(k), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if day == LastDay || day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
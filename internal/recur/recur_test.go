@@ -0,0 +1,97 @@
+package recur
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want, desc string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY", "Daily"},
+		{"rrule:freq=daily;interval=3", "FREQ=DAILY;INTERVAL=3", "Every 3 days"},
+		{"FREQ=WEEKLY;BYDAY=TH,MO,TH", "FREQ=WEEKLY;BYDAY=MO,TH", "Weekly on Mon, Thu"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", "Every 2 weeks on Fri"},
+		{"FREQ=MONTHLY;BYMONTHDAY=15", "FREQ=MONTHLY;BYMONTHDAY=15", "Monthly on day 15"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "FREQ=MONTHLY;BYMONTHDAY=-1", "Monthly on the last day"},
+		{"FREQ=YEARLY;INTERVAL=1", "FREQ=YEARLY", "Yearly"},
+		{"FREQ=DAILY;INTERVAL=3;FROM=COMPLETION", "FREQ=DAILY;INTERVAL=3;FROM=COMPLETION", "3 days after completion"},
+		{"FREQ=WEEKLY;FROM=DUE", "FREQ=WEEKLY", "Weekly"},
+	}
+	for _, tc := range tests {
+		r, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got := r.String(); got != tc.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tc.in, got, tc.want)
+		}
+		if got := r.Describe(); got != tc.desc {
+			t.Errorf("Parse(%q).Describe() = %q, want %q", tc.in, got, tc.desc)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYMONTHDAY=3",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=15;FROM=COMPLETION",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;COUNT=3",
+		"FREQ",
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q): want ErrInvalid, got %v", in, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		rule, from, want string
+	}{
+		{"FREQ=DAILY", "2024-03-09 09:00", "2024-03-10 09:00"}, // across the DST change
+		{"FREQ=DAILY;INTERVAL=3", "2024-01-30 09:00", "2024-02-02 09:00"},
+		{"FREQ=WEEKLY", "2024-01-03 09:00", "2024-01-10 09:00"},
+		// Wednesday → Thursday the same week, then Monday the next.
+		{"FREQ=WEEKLY;BYDAY=MO,TH", "2024-01-03 09:00", "2024-01-04 09:00"},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", "2024-01-04 09:00", "2024-01-08 09:00"},
+		// Every other week: Friday of this week, then Monday two weeks on.
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "2024-01-03 09:00", "2024-01-05 09:00"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "2024-01-05 09:00", "2024-01-15 09:00"},
+		{"FREQ=MONTHLY", "2024-01-31 09:00", "2024-02-29 09:00"},
+		{"FREQ=MONTHLY;BYMONTHDAY=15", "2024-01-10 09:00", "2024-01-15 09:00"},
+		{"FREQ=MONTHLY;BYMONTHDAY=15", "2024-01-15 09:00", "2024-02-15 09:00"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-31 09:00", "2024-02-29 09:00"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1", "2024-01-20 09:00", "2024-04-01 09:00"},
+		{"FREQ=YEARLY", "2024-02-29 09:00", "2025-02-28 09:00"},
+	}
+	for _, tc := range tests {
+		r, err := Parse(tc.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.rule, err)
+		}
+		if got := r.Next(date(tc.from)); !got.Equal(date(tc.want)) {
+			t.Errorf("%s after %s = %s, want %s", tc.rule, tc.from, got.Format("2006-01-02 15:04 Mon"), tc.want)
+		}
+	}
+}
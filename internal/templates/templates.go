@@ -0,0 +1,72 @@
+// Package templates embeds the HTML templates so the binary runs from any
+// working directory, and can re-read them from disk while iterating on markup.
+package templates
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FS holds the page layout, the partials, the auth pages and the other
+// full pages rendered through the layout.
+//
+//go:embed *.html partials/*.html auth/*.html pages/*.html
+var FS embed.FS
+
+// Source says where a Set reads its templates from.
+type Source struct {
+	FS     fs.FS // root containing layout.html, partials/ and auth/
+	Reload bool  // re-parse on every render instead of once at startup
+}
+
+// Embedded returns the templates compiled into the binary.
+func Embedded() Source { return Source{FS: FS} }
+
+// Dir returns templates read from dir on disk, re-parsed on every render so
+// edits show up on the next request.
+func Dir(dir string) Source { return Source{FS: os.DirFS(dir), Reload: true} }
+
+// Set is a group of templates parsed from one Source.
+type Set struct {
+	src      Source
+	patterns []string
+	tmpl     *template.Template // parsed once unless src.Reload
+}
+
+// Parse parses every file matching patterns (relative to the source root).
+// The templates are parsed up front even in reload mode so that syntax errors
+// surface at startup.
+func (src Source) Parse(patterns ...string) (*Set, error) {
+	s := &Set{src: src, patterns: patterns}
+	tmpl, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	s.tmpl = tmpl
+	return s, nil
+}
+
+func (s *Set) parse() (*template.Template, error) {
+	return template.ParseFS(s.src.FS, s.patterns...)
+}
+
+// Get returns the parsed templates, re-reading them first in reload mode.
+func (s *Set) Get() (*template.Template, error) {
+	if !s.src.Reload {
+		return s.tmpl, nil
+	}
+	return s.parse()
+}
+
+// ExecuteTemplate renders the named template (its base file name or a
+// {{ define }}d name) into w.
+func (s *Set) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	tmpl, err := s.Get()
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
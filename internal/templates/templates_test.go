@@ -0,0 +1,37 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedTemplatesParse(t *testing.T) {
+	for _, patterns := range [][]string{
+		{"*.html", "partials/*.html"},
+		{"layout.html", "pages/todo_detail.html", "partials/*.html"},
+		{"layout.html", "pages/trash.html"},
+		{"auth/*.html"},
+	} {
+		if _, err := Embedded().Parse(patterns...); err != nil {
+			t.Errorf("Parse(%v): %v", patterns, err)
+		}
+	}
+}
+
+func TestReloadPicksUpEdits(t *testing.T) {
+	fsys := fstest.MapFS{"page.html": {Data: []byte("v1")}}
+	set, err := Source{FS: fsys, Reload: true}.Parse("*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["page.html"] = &fstest.MapFile{Data: []byte("v2")}
+
+	var out strings.Builder
+	if err := set.ExecuteTemplate(&out, "page.html", nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "v2" {
+		t.Fatalf("expected reloaded template, got %q", out.String())
+	}
+}
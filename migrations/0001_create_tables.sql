@@ -1,5 +1,7 @@
 -- migrations/0001_create_tables.sql
 
+-- +migrate Up
+
 -- users table
 CREATE TABLE IF NOT EXISTS users (
   username      TEXT PRIMARY KEY,
@@ -15,3 +17,8 @@ CREATE TABLE IF NOT EXISTS todos (
   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,21 @@
+-- migrations/0002_api_tokens.sql
+
+-- +migrate Up
+
+-- Personal access tokens for scripts and other non-browser clients. Only a
+-- SHA-256 of each secret is stored.
+CREATE TABLE api_tokens (
+  id           SERIAL      PRIMARY KEY,
+  username     TEXT        NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+  name         TEXT        NOT NULL,
+  token_hash   TEXT        NOT NULL UNIQUE,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at   TIMESTAMPTZ,
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_tokens_username_idx ON api_tokens (username);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS api_tokens;
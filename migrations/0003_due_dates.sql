@@ -0,0 +1,20 @@
+-- migrations/0003_due_dates.sql
+
+-- +migrate Up
+
+-- Optional deadline. Date-only deadlines store midnight of that date in
+-- due_tz and leave due_has_time false.
+ALTER TABLE todos
+  ADD COLUMN due_at       TIMESTAMPTZ,
+  ADD COLUMN due_has_time BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN due_tz       TEXT    NOT NULL DEFAULT '';
+
+CREATE INDEX todos_username_due_at_idx ON todos (username, due_at);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_username_due_at_idx;
+ALTER TABLE todos
+  DROP COLUMN IF EXISTS due_tz,
+  DROP COLUMN IF EXISTS due_has_time,
+  DROP COLUMN IF EXISTS due_at;
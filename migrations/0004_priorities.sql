@@ -0,0 +1,16 @@
+-- migrations/0004_priorities.sql
+
+-- +migrate Up
+
+-- 0 = none, 1 = low, 2 = medium, 3 = high, 4 = urgent.
+ALTER TABLE todos
+  ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0
+    CONSTRAINT todos_priority_range CHECK (priority BETWEEN 0 AND 4);
+
+CREATE INDEX todos_username_priority_idx
+  ON todos (username, priority DESC, due_at ASC NULLS LAST, id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_username_priority_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,24 @@
+-- migrations/0005_tags.sql
+
+-- +migrate Up
+
+-- Each user has their own set of tag names; todos and tags are many-to-many.
+CREATE TABLE tags (
+  id       SERIAL PRIMARY KEY,
+  username TEXT   NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+  name     TEXT   NOT NULL,
+  UNIQUE (username, name)
+);
+
+CREATE TABLE todo_tags (
+  todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX todo_tags_tag_id_idx ON todo_tags (tag_id);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS todo_tags;
+DROP TABLE IF EXISTS tags;
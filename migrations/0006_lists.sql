@@ -0,0 +1,41 @@
+-- migrations/0006_lists.sql
+
+-- +migrate Up
+
+-- Named lists (projects). Every user has exactly one Inbox, which cannot be
+-- archived or deleted; deleting any other list deletes its todos.
+CREATE TABLE lists (
+  id          SERIAL      PRIMARY KEY,
+  username    TEXT        NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+  name        TEXT        NOT NULL,
+  is_inbox    BOOLEAN     NOT NULL DEFAULT FALSE,
+  archived_at TIMESTAMPTZ,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX lists_username_name_key ON lists (username, lower(name));
+CREATE UNIQUE INDEX lists_one_inbox_per_user ON lists (username) WHERE is_inbox;
+
+-- Give every existing user an Inbox and move their todos into it.
+INSERT INTO lists (username, name, is_inbox)
+SELECT username, 'Inbox', TRUE FROM users;
+
+ALTER TABLE todos
+  ADD COLUMN list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE;
+
+UPDATE todos t
+   SET list_id = l.id
+  FROM lists l
+ WHERE l.username = t.username
+   AND l.is_inbox;
+
+ALTER TABLE todos ALTER COLUMN list_id SET NOT NULL;
+
+CREATE INDEX todos_list_id_idx ON todos (list_id);
+
+-- +migrate Down
+
+-- Lists are flattened away; every todo stays with its owner.
+DROP INDEX IF EXISTS todos_list_id_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS list_id;
+DROP TABLE IF EXISTS lists;
@@ -0,0 +1,19 @@
+-- migrations/0007_checklist_items.sql
+
+-- +migrate Up
+
+-- Ordered checklist steps under a todo. They go when their todo does.
+CREATE TABLE checklist_items (
+  id         SERIAL      PRIMARY KEY,
+  todo_id    INTEGER     NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  title      TEXT        NOT NULL,
+  done       BOOLEAN     NOT NULL DEFAULT FALSE,
+  position   INTEGER     NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX checklist_items_todo_id_idx ON checklist_items (todo_id, position);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS checklist_items;
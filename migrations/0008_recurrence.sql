@@ -0,0 +1,12 @@
+-- migrations/0008_recurrence.sql
+
+-- +migrate Up
+
+-- A recurrence rule in the RRULE subset internal/recur reads, e.g.
+-- 'FREQ=WEEKLY;BYDAY=MO'; empty for one-off todos.
+ALTER TABLE todos
+  ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+
+ALTER TABLE todos DROP COLUMN IF EXISTS recurrence;
@@ -0,0 +1,11 @@
+-- migrations/0009_notes.sql
+
+-- +migrate Up
+
+-- Free-form Markdown shown on a todo's detail page; empty when unset.
+ALTER TABLE todos
+  ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+
+ALTER TABLE todos DROP COLUMN IF EXISTS notes;
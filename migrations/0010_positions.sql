@@ -0,0 +1,21 @@
+-- migrations/0010_positions.sql
+
+-- +migrate Up
+
+-- Manual order within a list. Positions are fractional so moving a todo
+-- between two others only rewrites that one row; existing todos keep the
+-- order they were created in.
+ALTER TABLE todos
+  ADD COLUMN position DOUBLE PRECISION;
+
+UPDATE todos SET position = id;
+
+ALTER TABLE todos
+  ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX todos_list_position_idx ON todos (username, list_id, position);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_list_position_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS position;
@@ -0,0 +1,18 @@
+-- migrations/0011_search.sql
+
+-- +migrate Up
+
+-- Full-text search over titles (weighted higher) and notes, kept up to
+-- date by Postgres itself.
+ALTER TABLE todos
+  ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', notes), 'B')
+  ) STORED;
+
+CREATE INDEX todos_search_idx ON todos USING GIN (search);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_search_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS search;
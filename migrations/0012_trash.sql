@@ -0,0 +1,16 @@
+-- migrations/0012_trash.sql
+
+-- +migrate Up
+
+-- Deleting a todo moves it to the trash. It is only removed for good when
+-- deleted from there or once it has sat in the trash past the retention
+-- period.
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_deleted_at_idx;
+DELETE FROM todos WHERE deleted_at IS NOT NULL;
+ALTER TABLE todos DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,37 @@
+-- migrations/0013_todo_events.sql
+
+-- +migrate Up
+
+-- The audit log: one row per change made to a todo. todo_id and username
+-- are deliberately not foreign keys, so the history outlives the todo.
+CREATE TABLE todo_events (
+  id         BIGSERIAL   PRIMARY KEY,
+  todo_id    INTEGER     NOT NULL,
+  username   TEXT        NOT NULL,
+  actor      TEXT        NOT NULL,
+  action     TEXT        NOT NULL,
+  changes    JSONB       NOT NULL DEFAULT '{}',
+  request_id TEXT        NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX todo_events_todo_idx ON todo_events (todo_id, id);
+CREATE INDEX todo_events_username_idx ON todo_events (username, id);
+CREATE INDEX todo_events_created_at_idx ON todo_events (created_at);
+
+-- Events are immutable: updates and deletes are refused outright, so no
+-- code path in the application can rewrite history.
+CREATE FUNCTION todo_events_immutable() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'todo_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_events_immutable
+  BEFORE UPDATE OR DELETE ON todo_events
+  FOR EACH ROW EXECUTE FUNCTION todo_events_immutable();
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS todo_events;
+DROP FUNCTION IF EXISTS todo_events_immutable();
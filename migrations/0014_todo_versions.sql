@@ -0,0 +1,11 @@
+-- migrations/0014_todo_versions.sql
+
+-- +migrate Up
+
+-- Every change to a todo's editable fields or tags bumps its version, so an
+-- edit based on an older read can be refused instead of overwriting.
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +migrate Down
+
+ALTER TABLE todos DROP COLUMN IF EXISTS version;
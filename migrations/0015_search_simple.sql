@@ -0,0 +1,27 @@
+-- migrations/0015_search_simple.sql
+
+-- +migrate Up
+
+-- Search words as written, without English stemming or stopwords, so the
+-- Postgres store finds exactly what the in-memory one does.
+DROP INDEX IF EXISTS todos_search_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS search;
+ALTER TABLE todos
+  ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', notes), 'B')
+  ) STORED;
+
+CREATE INDEX todos_search_idx ON todos USING GIN (search);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS todos_search_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS search;
+ALTER TABLE todos
+  ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', notes), 'B')
+  ) STORED;
+
+CREATE INDEX todos_search_idx ON todos USING GIN (search);
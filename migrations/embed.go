@@ -0,0 +1,13 @@
+// Package migrations embeds the numbered SQL schema migrations so the binary
+// can apply them itself (see internal/migrate).
+//
+// Files are named NNNN_description.sql. Each holds a "-- +migrate Up"
+// section and an optional "-- +migrate Down" section that reverts it.
+package migrations
+
+import "embed"
+
+// FS holds every *.sql migration in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
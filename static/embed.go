@@ -0,0 +1,9 @@
+// Package static embeds the files served under /static/.
+package static
+
+import "embed"
+
+// FS holds the stylesheets and scripts, rooted at this directory.
+//
+//go:embed css js
+var FS embed.FS
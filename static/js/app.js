@@ -0,0 +1,80 @@
+// Tell the server which time zone the browser is in, so "today" and
+// "overdue" are worked out from the user's point of view, and pre-fill the
+// due_tz field of any form that doesn't carry one yet.
+(function () {
+  var tz = Intl.DateTimeFormat().resolvedOptions().timeZone;
+  if (!tz) {
+    return;
+  }
+  document.cookie =
+    "tz=" + encodeURIComponent(tz) + "; path=/; max-age=31536000; SameSite=Lax";
+
+  function fillDueTZ(root) {
+    root.querySelectorAll('input[name="due_tz"]').forEach(function (el) {
+      if (!el.value) {
+        el.value = tz;
+      }
+    });
+  }
+  document.addEventListener("DOMContentLoaded", function () {
+    fillDueTZ(document);
+  });
+  document.addEventListener("htmx:load", function (evt) {
+    fillDueTZ(evt.target);
+  });
+})();
+
+// Let todos in a list shown in manual order be dragged into place. On drop
+// the server is told which todos the moved one now sits between; the page
+// already shows the new order, so the response isn't swapped in.
+(function () {
+  function todoID(li) {
+    return li && li.id.indexOf("todo-") === 0 ? li.id.slice("todo-".length) : "";
+  }
+
+  function initSortable(root) {
+    if (typeof Sortable === "undefined") {
+      return;
+    }
+    root.querySelectorAll(".sortable").forEach(function (el) {
+      if (Sortable.get(el)) {
+        return;
+      }
+      Sortable.create(el, {
+        handle: ".drag-handle",
+        animation: 150,
+        onEnd: function (evt) {
+          if (evt.oldIndex === evt.newIndex) {
+            return;
+          }
+          var li = evt.item;
+          htmx.ajax("POST", "/tasks/" + todoID(li) + "/move", {
+            source: li,
+            swap: "none",
+            values: {
+              after: todoID(li.previousElementSibling),
+              before: todoID(li.nextElementSibling),
+            },
+          });
+        },
+      });
+    });
+  }
+
+  document.addEventListener("htmx:load", function (evt) {
+    initSortable(evt.target);
+  });
+})();
+
+// Saving an edit that lost to a change made elsewhere answers 409 with the
+// form again, showing the newer version, and a lost checkbox toggle with
+// the list as it is now; swap them in like a success so the user can
+// re-apply their change. An undo refused for the same reason brings a
+// toast saying so.
+document.addEventListener("htmx:beforeSwap", function (evt) {
+  var path = evt.detail.pathInfo.requestPath;
+  if (evt.detail.xhr.status === 409 && /^\/(tasks\/\d+|undo\/\w+)$/.test(path)) {
+    evt.detail.shouldSwap = true;
+    evt.detail.isError = false;
+  }
+});